@@ -10,11 +10,13 @@ package ast // import "sevki.org/graphql/ast"
 
 import (
 	"fmt"
+	"math/big"
 
 	"sevki.org/graphql/token"
 )
 
 import (
+	"math"
 	"strconv"
 	"strings"
 )
@@ -25,11 +27,22 @@ type Document struct {
 	Definitions []Definition
 }
 
+// Position is the place in the source document a node starts at,
+// lines and columns are counted from 1.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Definition as defined in
 // http://facebook.github.io/graphql/#Definition
 type Definition interface {
 	isDefinition()
-	AddDirective(k string, args Arguments)
+	AddDirective(d *Directive)
 	AddSelection(s Selection)
 }
 
@@ -41,14 +54,12 @@ type Operation struct {
 	VariableDefinitions
 	Directives
 	SelectionSet
+	Pos Position
 }
 
 func (*Operation) isDefinition() {}
-func (o *Operation) AddDirective(k string, args Arguments) {
-	if o.Directives == nil {
-		o.Directives = make(Directives)
-	}
-	o.Directives[k] = args
+func (o *Operation) AddDirective(d *Directive) {
+	o.Directives = append(o.Directives, d)
 }
 func (o *Operation) AddSelection(s Selection) {
 	o.SelectionSet = append(o.SelectionSet, s)
@@ -56,7 +67,18 @@ func (o *Operation) AddSelection(s Selection) {
 
 // VariableDefinitions as defined in
 // http://facebook.github.io/graphql/#VariableDefinition
-type VariableDefinitions map[string]Variable
+type VariableDefinitions []*Variable
+
+// Get returns the definition of the variable named k, nil if there
+// is none.
+func (v VariableDefinitions) Get(k string) *Variable {
+	for _, d := range v {
+		if string(d.Name) == k {
+			return d
+		}
+	}
+	return nil
+}
 
 // OperationType as defined in
 // http://facebook.github.io/graphql/#OperationType
@@ -67,13 +89,14 @@ type OperationType int
 const (
 	Query OperationType = iota
 	Mutation
+	Subscription
 )
 
 // Selection as defined in
 // http://facebook.github.io/graphql/#Selection
 type Selection interface {
 	isSelection()
-	AddDirective(k string, args Arguments)
+	AddDirective(d *Directive)
 	AddSelection(s Selection)
 }
 
@@ -89,14 +112,12 @@ type Field struct {
 	Directives
 	SelectionSet
 	Parent Selection `json:"-"`
+	Pos    Position
 }
 
 func (*Field) isSelection() {}
-func (f *Field) AddDirective(k string, args Arguments) {
-	if f.Directives == nil {
-		f.Directives = make(Directives)
-	}
-	f.Directives[k] = args
+func (f *Field) AddDirective(d *Directive) {
+	f.Directives = append(f.Directives, d)
 }
 func (f *Field) AddSelection(s Selection) {
 	f.SelectionSet = append(f.SelectionSet, s)
@@ -112,15 +133,13 @@ type Fragment struct {
 	TypeCondition GraphQLName
 	SelectionSet  SelectionSet
 	Parent        Selection `json:"-"`
+	Pos           Position
 }
 
 func (*Fragment) isDefinition() {}
 func (*Fragment) isSelection()  {}
-func (f *Fragment) AddDirective(k string, args Arguments) {
-	if f.Directives == nil {
-		f.Directives = make(Directives)
-	}
-	f.Directives[k] = args
+func (f *Fragment) AddDirective(d *Directive) {
+	f.Directives = append(f.Directives, d)
 }
 func (f *Fragment) AddSelection(s Selection) {
 	f.SelectionSet = append(f.SelectionSet, s)
@@ -128,15 +147,83 @@ func (f *Fragment) AddSelection(s Selection) {
 
 // Arguments as defined in
 // http://facebook.github.io/graphql/#Arguments
-type Arguments map[string]Value
+type Arguments []*Argument
+
+// Get returns the value of the argument named k.
+func (a Arguments) Get(k string) (Value, bool) {
+	for _, arg := range a {
+		if string(arg.Name) == k {
+			return arg.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Argument as defined in
+// http://facebook.github.io/graphql/#Argument
+type Argument struct {
+	Name  GraphQLName
+	Value Value
+	Pos   Position
+}
 
 // Directives as defined in
 // http://facebook.github.io/graphql/#Directives
-type Directives map[string]Arguments
+type Directives []*Directive
+
+// Get returns the directive named k, nil if there is none.
+func (d Directives) Get(k string) *Directive {
+	for _, dir := range d {
+		if string(dir.Name) == k {
+			return dir
+		}
+	}
+	return nil
+}
+
+// Directive as defined in
+// http://facebook.github.io/graphql/#Directive
+type Directive struct {
+	Name GraphQLName
+	Arguments
+	Pos Position
+}
 
 // Type as defined in http://facebook.github.io/graphql/#Type
+//
+// Types are kept in their textual form, e.g. "[String!]!", the
+// methods below take them apart.
 type Type string
 
+// NonNull reports whether t is a non-null type.
+func (t Type) NonNull() bool {
+	return strings.HasSuffix(string(t), "!")
+}
+
+// Nullable returns t without its outermost non-null marker.
+func (t Type) Nullable() Type {
+	return Type(strings.TrimSuffix(string(t), "!"))
+}
+
+// List reports whether t, ignoring non-null, is a list type.
+func (t Type) List() bool {
+	return strings.HasPrefix(string(t), "[")
+}
+
+// Elem returns the item type of a list type.
+func (t Type) Elem() Type {
+	n := t.Nullable()
+	if !n.List() {
+		return ""
+	}
+	return Type(strings.TrimSpace(string(n[1 : len(n)-1])))
+}
+
+// Name returns the named type t is wrapping.
+func (t Type) Name() GraphQLName {
+	return GraphQLName(strings.Trim(string(t), "[]! "))
+}
+
 // Types as defined in
 // http://facebook.github.io/graphql/#sec-Syntax.Types
 type TypeName GraphQLName
@@ -145,8 +232,10 @@ type NonNullType Type
 
 // Variable as defined in http://facebook.github.io/graphql/#Variable
 type Variable struct {
+	Name         GraphQLName
 	Type         Type
 	DefaultValue Value
+	Pos          Position
 }
 
 // Value as defined in http://facebook.github.io/graphql/#sec-Values
@@ -172,13 +261,50 @@ type GraphQLInt int32
 func (GraphQLInt) isValue()  {}
 func (GraphQLInt) isScalar() {}
 
+// IntValue is an integer literal as it was written in a document, as
+// defined in http://facebook.github.io/graphql/#IntValue
+//
+// The digits are kept as they are so custom scalars can take values
+// that don't fit in 32 bits, the range of GraphQLInt is only checked
+// when the literal is coerced to Int.
+type IntValue string
+
+func (IntValue) isValue()  {}
+func (IntValue) isScalar() {}
+
+// Int returns the value of the literal.
+func (v IntValue) Int() *big.Int {
+	i, _ := new(big.Int).SetString(string(v), 0)
+	return i
+}
+
+// Int32 returns the value of the literal and whether it fits in a
+// GraphQLInt.
+func (v IntValue) Int32() (GraphQLInt, bool) {
+	i := v.Int()
+	if i == nil || !i.IsInt64() || i.Int64() < math.MinInt32 || i.Int64() > math.MaxInt32 {
+		return 0, false
+	}
+	return GraphQLInt(i.Int64()), true
+}
+
+// Float64 returns the literal as the nearest float64.
+func (v IntValue) Float64() float64 {
+	i := v.Int()
+	if i == nil {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return f
+}
+
 // GraphQLFloat  scalar type represents signed double‐precision
 // fractional values as specified by IEEE 754. Response formats that
 // support an appropriate double‐precision number type should use that
 // type to represent this scalar.
 // GraphQLFloat as defined in
 // http://facebook.github.io/graphql/#sec-Float
-type GraphQLFloat float32
+type GraphQLFloat float64
 
 func (GraphQLFloat) isValue()  {}
 func (GraphQLFloat) isScalar() {}
@@ -224,6 +350,51 @@ type ArrayValue []Value
 
 func (ArrayValue) isValue() {}
 
+// ObjectValue as defined in
+// http://facebook.github.io/graphql/#ObjectValue
+type ObjectValue []*ObjectField
+
+func (ObjectValue) isValue() {}
+
+// Get returns the value of the field named k.
+func (o ObjectValue) Get(k string) (Value, bool) {
+	for _, f := range o {
+		if string(f.Name) == k {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// ObjectField as defined in
+// http://facebook.github.io/graphql/#ObjectField
+type ObjectField struct {
+	Name  GraphQLName
+	Value Value
+	Pos   Position
+}
+
+// EnumValue as defined in
+// http://facebook.github.io/graphql/#EnumValue
+type EnumValue string
+
+func (EnumValue) isValue() {}
+
+// NullValue as defined in
+// http://facebook.github.io/graphql/#NullValue
+type NullValue struct{}
+
+func (NullValue) isValue() {}
+
+// VariableValue is a reference to a variable, $name, used as a
+// value. Name doesn't include the dollar sign.
+type VariableValue struct {
+	Name GraphQLName
+	Pos  Position
+}
+
+func (VariableValue) isValue() {}
+
 type GraphQLError string
 
 func (GraphQLError) isValue() {}
@@ -234,25 +405,32 @@ func GraphQLValue(t token.Token) Value {
 		return GraphQLBoolean(true)
 	case token.False:
 		return GraphQLBoolean(false)
-	case token.Number:
-		if i, err := strconv.Atoi(string(t.Text)); err !=
-			nil {
-			return GraphQLError(err.Error())
-		} else {
-			return GraphQLInt(i)
+	case token.Null:
+		return NullValue{}
+	case token.Number, token.Hex:
+		if IntValue(t.Text).Int() == nil {
+			return GraphQLError(fmt.Sprintf("%q is not an integer", t.Text))
 		}
-	case token.Hex:
-		var i int
-		if i, err := fmt.Sscanf(string(t.Text), "%X", &i); err !=
+		return IntValue(t.Text)
+	case token.Float:
+		if f, err := strconv.ParseFloat(string(t.Text), 64); err !=
 			nil {
 			return GraphQLError(err.Error())
 		} else {
-			return GraphQLInt(i)
+			return GraphQLFloat(f)
+		}
+	case token.Variable:
+		// the lexer drops the dollar sign.
+		return VariableValue{
+			Name: GraphQLName(t.Text),
+			Pos:  Position{Line: t.Line, Column: t.Start},
 		}
 	case token.String:
-		return GraphQLString(string(t.Text))
+		return EnumValue(t.Text)
 	case token.Quote:
-		return GraphQLString(strings.Trim(string(t.Text), "\""))
+		return GraphQLString(Unquote(string(t.Text)))
+	case token.BlockQuote:
+		return GraphQLString(BlockString(string(t.Text)))
 	default:
 		return GraphQLError(fmt.Sprintf("%s is not a GraphQL value", t.Type))
 	}
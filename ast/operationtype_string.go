@@ -4,9 +4,9 @@ package ast // import "sevki.org/graphql/ast"
 
 import "fmt"
 
-const _OperationType_name = "QueryMutation"
+const _OperationType_name = "QueryMutationSubscription"
 
-var _OperationType_index = [...]uint8{0, 5, 13}
+var _OperationType_index = [...]uint8{0, 5, 13, 25}
 
 func (i OperationType) String() string {
 	if i < 0 || i+1 >= OperationType(len(_OperationType_index)) {
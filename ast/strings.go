@@ -0,0 +1,166 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast // import "sevki.org/graphql/ast"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Unquote interprets s as a quoted GraphQL string and returns the
+// string value it represents, escape sequences as defined in
+// http://facebook.github.io/graphql/#EscapedCharacter are replaced.
+func Unquote(s string) string {
+	s = strings.TrimPrefix(s, "\"")
+	s = strings.TrimSuffix(s, "\"")
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			buf = append(buf, s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			if i+5 > len(s) {
+				buf = append(buf, s[i-1:]...)
+				i = len(s)
+				continue
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				buf = append(buf, s[i-1:i+5]...)
+			} else {
+				var e [utf8.UTFMax]byte
+				n := utf8.EncodeRune(e[:], rune(r))
+				buf = append(buf, e[:n]...)
+			}
+			i += 4
+		default:
+			// \", \\ and \/
+			buf = append(buf, s[i])
+		}
+	}
+	return string(buf)
+}
+
+// BlockString returns the value of a block string as described in
+// http://facebook.github.io/graphql/#BlockStringValue(), common
+// indentation and leading and trailing blank lines are removed.
+func BlockString(s string) string {
+	s = strings.TrimPrefix(s, `"""`)
+	s = strings.TrimSuffix(s, `"""`)
+	s = strings.Replace(s, `\"""`, `"""`, -1)
+	lines := strings.Split(s, "\n")
+
+	indent := -1
+	for _, line := range lines[1:] {
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if n < len(line) && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatValue returns v the way it would be written in a document.
+func FormatValue(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case NullValue:
+		return "null"
+	case GraphQLInt:
+		return strconv.FormatInt(int64(v), 10)
+	case IntValue:
+		return string(v)
+	case GraphQLFloat:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case GraphQLBoolean:
+		return strconv.FormatBool(bool(v))
+	case GraphQLString:
+		return Quote(string(v))
+	case GraphQLID:
+		return Quote(string(v))
+	case EnumValue:
+		return string(v)
+	case VariableValue:
+		return "$" + string(v.Name)
+	case ArrayValue:
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i] = FormatValue(e)
+		}
+		return "[" + strings.Join(s, ", ") + "]"
+	case ObjectValue:
+		s := make([]string, len(v))
+		for i, f := range v {
+			s[i] = string(f.Name) + ": " + FormatValue(f.Value)
+		}
+		return "{" + strings.Join(s, ", ") + "}"
+	case GraphQLError:
+		return string(v)
+	}
+	return ""
+}
+
+// Quote returns s as a quoted GraphQL string.
+func Quote(s string) string {
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, '"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buf = append(buf, '\\', byte(r))
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\b':
+			buf = append(buf, '\\', 'b')
+		case '\f':
+			buf = append(buf, '\\', 'f')
+		default:
+			if r < 0x20 {
+				buf = append(buf, fmt.Sprintf(`\u%04x`, r)...)
+				continue
+			}
+			buf = append(buf, string(r)...)
+		}
+	}
+	return string(append(buf, '"'))
+}
@@ -0,0 +1,199 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:generate stringer -type TypeKind
+
+package ast // import "sevki.org/graphql/ast"
+
+// SchemaDefinition as defined in
+// http://facebook.github.io/graphql/#SchemaDefinition
+type SchemaDefinition struct {
+	Description string
+	Directives
+	// OperationTypes maps the root operations to the names of the
+	// object types that serve them.
+	OperationTypes map[OperationType]GraphQLName
+	Extension      bool
+	Pos            Position
+}
+
+func (*SchemaDefinition) isDefinition() {}
+func (s *SchemaDefinition) AddDirective(d *Directive) {
+	s.Directives = append(s.Directives, d)
+}
+
+// AddSelection is a no-op, type system definitions have no
+// selection sets.
+func (*SchemaDefinition) AddSelection(s Selection) {}
+
+// TypeKind tells the different kinds of named types apart.
+type TypeKind int
+
+// TypeKinds as defined in
+// http://facebook.github.io/graphql/#TypeDefinition
+const (
+	ScalarKind TypeKind = iota
+	ObjectKind
+	InterfaceKind
+	UnionKind
+	EnumKind
+	InputObjectKind
+)
+
+// TypeDefinition as defined in
+// http://facebook.github.io/graphql/#TypeDefinition
+//
+// All six kinds of named types share this struct, the fields that
+// don't apply to a kind are left empty.
+type TypeDefinition struct {
+	Kind        TypeKind
+	Name        GraphQLName
+	Description string
+	// Interfaces an object or interface type implements.
+	Interfaces []GraphQLName
+	Directives
+	// Fields of an object, interface or input object type.
+	Fields []*FieldDefinition
+	// Types that are members of a union.
+	Types []GraphQLName
+	// EnumValues of an enum type.
+	EnumValues []*EnumValueDefinition
+	// Extension is set for type extensions, "extend type Foo".
+	Extension bool
+	Pos       Position
+}
+
+func (*TypeDefinition) isDefinition() {}
+func (t *TypeDefinition) AddDirective(d *Directive) {
+	t.Directives = append(t.Directives, d)
+}
+
+// AddSelection is a no-op, type system definitions have no
+// selection sets.
+func (*TypeDefinition) AddSelection(s Selection) {}
+
+// Field returns the field named n.
+func (t *TypeDefinition) Field(n GraphQLName) *FieldDefinition {
+	for _, f := range t.Fields {
+		if f.Name == n {
+			return f
+		}
+	}
+	return nil
+}
+
+// EnumValue returns the enum value named n.
+func (t *TypeDefinition) EnumValue(n GraphQLName) *EnumValueDefinition {
+	for _, v := range t.EnumValues {
+		if v.Name == n {
+			return v
+		}
+	}
+	return nil
+}
+
+// FieldDefinition as defined in
+// http://facebook.github.io/graphql/#FieldDefinition
+//
+// Input object fields are described by InputValueDefinition but are
+// kept here with no arguments, so all fields of a type can be walked
+// the same way.
+type FieldDefinition struct {
+	Name        GraphQLName
+	Description string
+	Arguments   []*InputValueDefinition
+	Type        Type
+	// DefaultValue is only used by input object fields.
+	DefaultValue Value
+	Directives
+	Pos Position
+}
+
+// Argument returns the argument named n.
+func (f *FieldDefinition) Argument(n GraphQLName) *InputValueDefinition {
+	return inputValue(f.Arguments, n)
+}
+
+// InputValueDefinition as defined in
+// http://facebook.github.io/graphql/#InputValueDefinition
+type InputValueDefinition struct {
+	Name         GraphQLName
+	Description  string
+	Type         Type
+	DefaultValue Value
+	Directives
+	Pos Position
+}
+
+func inputValue(defs []*InputValueDefinition, n GraphQLName) *InputValueDefinition {
+	for _, d := range defs {
+		if d.Name == n {
+			return d
+		}
+	}
+	return nil
+}
+
+// EnumValueDefinition as defined in
+// http://facebook.github.io/graphql/#EnumValueDefinition
+type EnumValueDefinition struct {
+	Name        GraphQLName
+	Description string
+	Directives
+	Pos Position
+}
+
+// DirectiveLocation as defined in
+// http://facebook.github.io/graphql/#DirectiveLocations
+type DirectiveLocation string
+
+// DirectiveLocations as defined in
+// http://facebook.github.io/graphql/#DirectiveLocation
+const (
+	LocationQuery                DirectiveLocation = "QUERY"
+	LocationMutation             DirectiveLocation = "MUTATION"
+	LocationSubscription         DirectiveLocation = "SUBSCRIPTION"
+	LocationField                DirectiveLocation = "FIELD"
+	LocationFragmentDefinition   DirectiveLocation = "FRAGMENT_DEFINITION"
+	LocationFragmentSpread       DirectiveLocation = "FRAGMENT_SPREAD"
+	LocationInlineFragment       DirectiveLocation = "INLINE_FRAGMENT"
+	LocationVariableDefinition   DirectiveLocation = "VARIABLE_DEFINITION"
+	LocationSchema               DirectiveLocation = "SCHEMA"
+	LocationScalar               DirectiveLocation = "SCALAR"
+	LocationObject               DirectiveLocation = "OBJECT"
+	LocationFieldDefinition      DirectiveLocation = "FIELD_DEFINITION"
+	LocationArgumentDefinition   DirectiveLocation = "ARGUMENT_DEFINITION"
+	LocationInterface            DirectiveLocation = "INTERFACE"
+	LocationUnion                DirectiveLocation = "UNION"
+	LocationEnum                 DirectiveLocation = "ENUM"
+	LocationEnumValue            DirectiveLocation = "ENUM_VALUE"
+	LocationInputObject          DirectiveLocation = "INPUT_OBJECT"
+	LocationInputFieldDefinition DirectiveLocation = "INPUT_FIELD_DEFINITION"
+)
+
+// DirectiveDefinition as defined in
+// http://facebook.github.io/graphql/#DirectiveDefinition
+type DirectiveDefinition struct {
+	Name        GraphQLName
+	Description string
+	Arguments   []*InputValueDefinition
+	Repeatable  bool
+	Locations   []DirectiveLocation
+	Pos         Position
+}
+
+func (*DirectiveDefinition) isDefinition() {}
+
+// AddDirective is a no-op, directive definitions can not have
+// directives.
+func (*DirectiveDefinition) AddDirective(d *Directive) {}
+
+// AddSelection is a no-op, type system definitions have no
+// selection sets.
+func (*DirectiveDefinition) AddSelection(s Selection) {}
+
+// Argument returns the argument named n.
+func (d *DirectiveDefinition) Argument(n GraphQLName) *InputValueDefinition {
+	return inputValue(d.Arguments, n)
+}
@@ -0,0 +1,362 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bind makes a schema written in SDL executable by binding Go
+// types to its object types.
+//
+// The fields of an object type are resolved by the exported methods
+// or fields of the Go type bound to it. Names match if they are equal
+// ignoring case, or if a struct field has a `graphql` tag with the
+// field's name. Methods may take a context.Context, a struct the
+// arguments of the field are decoded into, both in that order, or
+// nothing, and return a value, optionally followed by an error.
+//
+//	type User struct {
+//		ID   string
+//		Name string `graphql:"name"`
+//	}
+//
+//	func (u *User) Friends(ctx context.Context, args struct{ First int }) ([]*User, error)
+package bind // import "sevki.org/graphql/bind"
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/schema"
+)
+
+// Error lists everything that keeps Go types from being bound to a
+// schema.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "bind: " + strings.Join(e.Problems, "\n\t")
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// binder checks and binds the types.
+type binder struct {
+	s *schema.Schema
+	e *executor.Executor
+	// types maps object type names to the Go types bound to them,
+	// with pointers removed.
+	types    map[ast.GraphQLName]reflect.Type
+	problems []string
+}
+
+func (b *binder) problemf(format string, args ...interface{}) {
+	b.problems = append(b.problems, fmt.Sprintf(format, args...))
+}
+
+// Bind returns an executor for s that resolves the fields of every
+// object type with the Go type of the value types maps its name to,
+// e.g. {"Query": &Query{}, "User": (*User)(nil)}. Every object type of
+// s must be bound.
+//
+// It fails with an *Error that lists every field that has no method
+// or struct field to resolve it and every method or struct field
+// whose type doesn't match the field's.
+func Bind(s *schema.Schema, types map[string]interface{}) (*executor.Executor, error) {
+	b := &binder{
+		s:     s,
+		e:     executor.New(s),
+		types: make(map[ast.GraphQLName]reflect.Type),
+	}
+	for n, v := range types {
+		t, ok := s.Types[ast.GraphQLName(n)]
+		switch {
+		case !ok:
+			b.problemf("%s is not defined by the schema", n)
+		case t.Kind != ast.ObjectKind:
+			b.problemf("%s is a %s, only object types can be bound", n, schema.Kind(t.Kind))
+		case v == nil:
+			b.problemf("%s is bound to nil", n)
+		default:
+			b.types[t.Name] = indirect(reflect.TypeOf(v))
+		}
+	}
+	for _, t := range s.TypeList() {
+		if t.Kind != ast.ObjectKind || strings.HasPrefix(string(t.Name), "__") {
+			continue
+		}
+		rt, ok := b.types[t.Name]
+		if !ok {
+			b.problemf("%s is not bound to a Go type", t.Name)
+			continue
+		}
+		for _, f := range t.Fields {
+			b.field(t, f, rt)
+		}
+		b.e.IsTypeOf(string(t.Name), isTypeOf(rt))
+	}
+	if len(b.problems) > 0 {
+		sort.Strings(b.problems)
+		return nil, &Error{b.problems}
+	}
+	return b.e, nil
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isTypeOf(rt reflect.Type) executor.IsTypeOfFunc {
+	return func(ctx context.Context, v interface{}) bool {
+		return v != nil && indirect(reflect.TypeOf(v)) == rt
+	}
+}
+
+// field binds the field f of t to a method or a struct field of rt.
+func (b *binder) field(t *ast.TypeDefinition, f *ast.FieldDefinition, rt reflect.Type) {
+	name := string(t.Name) + "." + string(f.Name)
+	if m, ok := method(reflect.PtrTo(rt), string(f.Name)); ok {
+		b.method(name, f, m)
+		return
+	}
+	if rt.Kind() == reflect.Struct {
+		if sf, ok := structField(rt, string(f.Name)); ok {
+			if err := b.check(f.Type, sf.Type); err != nil {
+				b.problemf("%s: field %s.%s %s", name, rt.Name(), sf.Name, err)
+				return
+			}
+			for _, a := range f.Arguments {
+				if a.Type.NonNull() && a.DefaultValue == nil {
+					b.problemf("%s: field %s.%s can't take the required argument %s", name, rt.Name(), sf.Name, a.Name)
+					return
+				}
+			}
+			index := sf.Index
+			b.e.Resolve(name, func(p executor.Params) (interface{}, error) {
+				v := reflect.ValueOf(p.Source)
+				for v.Kind() == reflect.Ptr {
+					if v.IsNil() {
+						return nil, nil
+					}
+					v = v.Elem()
+				}
+				if fv, ok := fieldByIndex(v, index, false); ok {
+					return fv.Interface(), nil
+				}
+				return nil, nil
+			})
+			return
+		}
+	}
+	b.problemf("%s has no resolver, %s has no method or field %s", name, rt, f.Name)
+}
+
+// method binds f to the method m.
+func (b *binder) method(name string, f *ast.FieldDefinition, m reflect.Method) {
+	mt := m.Type
+	withContext, args, err := signature(mt, 1)
+	if err != nil {
+		b.problemf("%s: method %s %s", name, m.Name, err)
+		return
+	}
+	if err := b.check(f.Type, mt.Out(0)); err != nil {
+		b.problemf("%s: method %s %s", name, m.Name, err)
+	}
+	if args != nil {
+		b.arguments(name, f, m, args)
+	} else {
+		for _, a := range f.Arguments {
+			if a.Type.NonNull() && a.DefaultValue == nil {
+				b.problemf("%s: method %s takes no arguments, but %s is required", name, m.Name, a.Name)
+			}
+		}
+	}
+
+	b.e.Resolve(name, func(p executor.Params) (interface{}, error) {
+		recv := reflect.ValueOf(p.Source)
+		if !recv.IsValid() || recv.Kind() == reflect.Ptr && recv.IsNil() {
+			return nil, nil
+		}
+		if recv.Kind() != reflect.Ptr {
+			// methods are looked up on pointers, so they can have
+			// either kind of receiver.
+			ptr := reflect.New(recv.Type())
+			ptr.Elem().Set(recv)
+			recv = ptr
+		}
+		in := []reflect.Value{recv}
+		if withContext {
+			in = append(in, reflect.ValueOf(p.Context))
+		}
+		if args != nil {
+			a, err := decode(p.Args, args)
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, a)
+		}
+		out := m.Func.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	})
+}
+
+// signature checks that the method type mt, whose first in arguments
+// are receivers, can resolve a field and returns what it takes.
+func signature(mt reflect.Type, in int) (withContext bool, args reflect.Type, err error) {
+	withContext = in < mt.NumIn() && mt.In(in) == contextType
+	if withContext {
+		in++
+	}
+	if in < mt.NumIn() {
+		args = mt.In(in)
+		in++
+	}
+	switch {
+	case in != mt.NumIn():
+		return false, nil, fmt.Errorf("takes too many arguments")
+	case mt.NumOut() == 0 || mt.NumOut() > 2 || mt.NumOut() == 2 && mt.Out(1) != errorType:
+		return false, nil, fmt.Errorf("must return a value, optionally followed by an error")
+	}
+	return withContext, args, nil
+}
+
+// arguments checks that the struct args can hold the arguments of f.
+func (b *binder) arguments(name string, f *ast.FieldDefinition, m reflect.Method, args reflect.Type) {
+	st := indirect(args)
+	if st.Kind() != reflect.Struct {
+		b.problemf("%s: method %s must take its arguments as a struct, not %s", name, m.Name, args)
+		return
+	}
+	for _, a := range f.Arguments {
+		sf, ok := structField(st, string(a.Name))
+		if !ok {
+			b.problemf("%s: argument %s has no field in %s", name, a.Name, args)
+			continue
+		}
+		if err := b.checkInput(a.Type, sf.Type); err != nil {
+			b.problemf("%s: argument %s %s", name, a.Name, err)
+		}
+	}
+	eachField(st, func(sf reflect.StructField) {
+		if !isArgument(f, sf) {
+			b.problemf("%s: %s.%s is not an argument", name, args, sf.Name)
+		}
+	})
+}
+
+func isArgument(f *ast.FieldDefinition, sf reflect.StructField) bool {
+	for _, a := range f.Arguments {
+		if matches(sf, string(a.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// method returns the exported method of t called n, ignoring case.
+func method(t reflect.Type, n string) (reflect.Method, bool) {
+	for i := 0; i < t.NumMethod(); i++ {
+		if m := t.Method(i); strings.EqualFold(m.Name, n) {
+			return m, true
+		}
+	}
+	return reflect.Method{}, false
+}
+
+// structField returns the exported field of struct t that n names,
+// looking into the structs embedded in t like the Builder does. The
+// fields of t itself win over promoted ones.
+func structField(t reflect.Type, n string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); !embedded(sf) && sf.PkgPath == "" && matches(sf, n) {
+			return sf, true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !embedded(sf) {
+			continue
+		}
+		if f, ok := structField(indirect(sf.Type), n); ok {
+			f.Index = append(append([]int{}, sf.Index...), f.Index...)
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// eachField calls fn for the exported fields of struct t and of the
+// structs embedded in it.
+func eachField(t reflect.Type, fn func(sf reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		switch {
+		case embedded(sf):
+			eachField(indirect(sf.Type), fn)
+		case sf.PkgPath == "":
+			fn(sf)
+		}
+	}
+}
+
+// embedded reports whether the fields of sf are promoted to the struct
+// it is in.
+func embedded(sf reflect.StructField) bool {
+	return sf.Anonymous && sf.Tag.Get("graphql") == "" && indirect(sf.Type).Kind() == reflect.Struct
+}
+
+// fieldByIndex is v.FieldByIndex(index) for a struct v, nil embedded
+// pointers on the way are allocated if alloc is set, otherwise ok is
+// false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (f reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					if !alloc || !v.CanSet() {
+						return reflect.Value{}, false
+					}
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func matches(sf reflect.StructField, n string) bool {
+	if tag := fieldName(sf); tag != sf.Name {
+		return tag == n
+	}
+	return strings.EqualFold(sf.Name, n)
+}
+
+// fieldName returns the name in the graphql tag of sf, or its name.
+func fieldName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("graphql")
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return sf.Name
+	}
+	return tag
+}
@@ -0,0 +1,394 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bind // import "sevki.org/graphql/bind"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"sevki.org/graphql/parser"
+	"sevki.org/graphql/schema"
+)
+
+const sdl = `
+type Query {
+  user(id: ID!): User
+  users(filter: Filter, first: Int = 2): [User!]!
+  node(id: ID!): Node
+}
+interface Node { id: ID! }
+type User implements Node {
+  id: ID!
+  name: String
+  role: Role
+  friends(first: Int): [User]
+}
+enum Role { ADMIN GUEST }
+input Filter { role: Role, names: [String!] }
+`
+
+type query struct {
+	users []*user
+}
+
+func (q *query) User(ctx context.Context, args struct{ ID int }) (*user, error) {
+	if args.ID < 0 || args.ID >= len(q.users) {
+		return nil, errors.New("no such user")
+	}
+	return q.users[args.ID], nil
+}
+
+type filter struct {
+	Role  *string
+	Names []string
+}
+
+func (q *query) Users(args struct {
+	Filter *filter
+	First  int
+}) []*user {
+	var us []*user
+	for _, u := range q.users {
+		if len(us) == args.First {
+			break
+		}
+		if f := args.Filter; f == nil || f.Role == nil || *f.Role == u.Role {
+			us = append(us, u)
+		}
+	}
+	return us
+}
+
+func (q *query) Node(args struct{ ID int }) interface{} {
+	return q.users[args.ID]
+}
+
+type user struct {
+	ID      int
+	Name    string `graphql:"name"`
+	Role    string
+	friends []*user
+}
+
+func (u user) Friends(args struct{ First *int }) []*user {
+	if args.First != nil && *args.First < len(u.friends) {
+		return u.friends[:*args.First]
+	}
+	return u.friends
+}
+
+func TestBind(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Bind(s, map[string]interface{}{
+		"Query": (*query)(nil),
+		"User":  user{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann := &user{ID: 0, Name: "ann", Role: "ADMIN"}
+	bob := &user{ID: 1, Name: "bob", Role: "GUEST"}
+	cat := &user{ID: 2, Name: "cat", Role: "ADMIN"}
+	ann.friends = []*user{bob, cat}
+	root := &query{users: []*user{ann, bob, cat}}
+
+	for _, test := range []struct {
+		query, want string
+	}{
+		{
+			`{ user(id: 0) { id name friends(first: 1) { name } } }`,
+			`{"data":{"user":{"id":"0","name":"ann","friends":[{"name":"bob"}]}}}`,
+		},
+		{
+			`{ users(filter: {role: ADMIN}) { name role } }`,
+			`{"data":{"users":[{"name":"ann","role":"ADMIN"},{"name":"cat","role":"ADMIN"}]}}`,
+		},
+		{
+			`{ users(first: 1) { name } node(id: 2) { id ... on User { name } } }`,
+			`{"data":{"users":[{"name":"ann"}],"node":{"id":"2","name":"cat"}}}`,
+		},
+		{
+			`{ user(id: 9) { id } }`,
+			`{"data":{"user":null},"errors":[{"message":"no such user","locations":[{"line":1,"column":3}],"path":["user"]}]}`,
+		},
+	} {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(e.Execute(doc, "", nil, root))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.want {
+			t.Errorf("%s\ngot:  %s\nwant: %s", test.query, b, test.want)
+		}
+	}
+}
+
+type badQuery struct{}
+
+func (badQuery) User(id string) *user { return nil }
+func (badQuery) Users() []string      { return nil }
+
+type badUser struct {
+	ID   bool
+	Name string
+}
+
+func TestBindErrors(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Bind(s, map[string]interface{}{
+		"Query": badQuery{},
+		"User":  badUser{},
+		"Role":  "",
+		"Nope":  struct{}{},
+	})
+	be, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	want := []string{
+		"Nope is not defined by the schema",
+		"Query.node has no resolver, bind.badQuery has no method or field node",
+		"Query.user: method User must take its arguments as a struct, not string",
+		"Query.user: method User returns *bind.user, not bind.badUser which User is bound to",
+		"Query.users: method Users returns string, not bind.badUser which User is bound to in [User!]",
+		"Role is a enum, only object types can be bound",
+		"User.friends has no resolver, bind.badUser has no method or field friends",
+		"User.id: field badUser.ID returns bool, not ID",
+		"User.role has no resolver, bind.badUser has no method or field role",
+	}
+	if got := strings.Join(be.Problems, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+type node interface {
+	ID() ID
+}
+
+type role string
+
+type shelf struct {
+	Books []*book `description:"The books on the shelf."`
+	Label string  `graphql:",nullable" deprecated:"Use name."`
+}
+
+func (s *shelf) Name() string { return s.Label }
+
+type book struct {
+	Isbn   ID     `graphql:"-"`
+	Title  string `description:"The title of the book."`
+	Access role
+	Pages  *int
+	secret string
+}
+
+func (b *book) ID() ID { return b.Isbn }
+
+func (b *book) String() string { return b.Title }
+
+type bookFilter struct {
+	Access  *role
+	MinPage int `default:"0"`
+}
+
+type library struct {
+	shelves []*shelf
+}
+
+func (l *library) Shelf(args struct {
+	Index int `description:"Counted from 0."`
+}) *shelf {
+	return l.shelves[args.Index]
+}
+
+func (l *library) Books(ctx context.Context, args struct{ Filter *bookFilter }) ([]node, error) {
+	var ns []node
+	for _, s := range l.shelves {
+		for _, b := range s.Books {
+			if f := args.Filter; f == nil || f.Access == nil || *f.Access == b.Access {
+				ns = append(ns, b)
+			}
+		}
+	}
+	return ns, nil
+}
+
+const librarySDL = `"A small library."
+schema {
+  query: Query
+}
+
+type Query {
+  books(filter: BookFilter): [Node]
+  shelf("Counted from 0." index: Int!): Shelf
+}
+
+"Who may borrow a book."
+enum Role {
+  PUBLIC
+  STAFF @deprecated(reason: "Everyone is staff.")
+}
+
+"Anything with an ID."
+interface Node {
+  id: ID!
+}
+
+input BookFilter {
+  access: Role
+  minPage: Int! = 0
+}
+
+type Shelf {
+  "The books on the shelf."
+  books: [Book]
+  label: String @deprecated(reason: "Use name.")
+  name: String!
+}
+
+type Book implements Node {
+  "The title of the book."
+  title: String!
+  access: Role!
+  pages: Int
+  id: ID!
+}
+`
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder("A small library.", (*library)(nil))
+	b.Enum(role(""), "Who may borrow a book.",
+		EnumValue{Name: "PUBLIC"},
+		EnumValue{Name: "STAFF", Deprecated: "Everyone is staff."},
+	)
+	b.Interface((*node)(nil), "Anything with an ID.")
+	sdl, err := b.SDL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sdl != librarySDL {
+		t.Errorf("got:\n%s\nwant:\n%s", sdl, librarySDL)
+	}
+	e, err := b.Executor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := 120
+	root := &library{shelves: []*shelf{{
+		Label: "fiction",
+		Books: []*book{
+			{Isbn: "1", Title: "Dune", Access: "PUBLIC", Pages: &pages},
+			{Isbn: "2", Title: "Solaris", Access: "STAFF"},
+		},
+	}}}
+	doc, err := parser.NewQuery([]byte(`{
+  shelf(index: 0) { name books { title pages } }
+  books(filter: {access: STAFF}) { id ... on Book { access } }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(e.Execute(doc, "", nil, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"data":{"shelf":{"name":"fiction","books":[{"title":"Dune","pages":120},{"title":"Solaris","pages":null}]},"books":[{"id":"2","access":"STAFF"}]}}`
+	if string(got) != want {
+		t.Errorf("got:  %s\nwant: %s", got, want)
+	}
+}
+
+type badRoot struct {
+	Tags map[string]string
+}
+
+func (badRoot) Shelf(args struct{ In *shelf }) *shelf { return nil }
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := NewBuilder("", badRoot{}).SDL()
+	be, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	want := []string{
+		"Query.Shelf(In:): bind.shelf is an object type, it can't be an input",
+		"Query.Tags: map[string]string has no GraphQL type",
+	}
+	if got := strings.Join(be.Problems, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+type stamp struct {
+	Created string
+}
+
+type meta struct {
+	Note string
+}
+
+type article struct {
+	stamp
+	*meta
+	Title string
+}
+
+type since struct {
+	After string
+}
+
+type articleFilter struct {
+	since
+	Title *string
+}
+
+type archive struct {
+	articles []*article
+}
+
+func (a *archive) Articles(args struct{ Filter *articleFilter }) []*article {
+	var as []*article
+	for _, x := range a.articles {
+		if f := args.Filter; f == nil || x.Created > f.After {
+			as = append(as, x)
+		}
+	}
+	return as
+}
+
+func TestBuilderEmbedded(t *testing.T) {
+	e, err := NewBuilder("", (*archive)(nil)).Executor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &archive{articles: []*article{
+		{stamp: stamp{Created: "a"}, Title: "old"},
+		{stamp: stamp{Created: "c"}, meta: &meta{Note: "new"}, Title: "new"},
+	}}
+	doc, err := parser.NewQuery([]byte(`{ all: articles { title created note } articles(filter: {after: "b"}) { title note } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(e.Execute(doc, "", nil, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"data":{"all":[{"title":"old","created":"a","note":null},{"title":"new","created":"c","note":"new"}],"articles":[{"title":"new","note":"new"}]}}`
+	if string(got) != want {
+		t.Errorf("got:  %s\nwant: %s", got, want)
+	}
+}
@@ -0,0 +1,557 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bind // import "sevki.org/graphql/bind"
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/schema"
+)
+
+// ID is a string that is an ID rather than a String in schemas
+// derived from Go types.
+type ID string
+
+var idType = reflect.TypeOf(ID(""))
+
+// EnumValue describes a value of an enum type derived from Go types.
+type EnumValue struct {
+	Name        string
+	Description string
+	// Deprecated is the reason the value is deprecated, if it is.
+	Deprecated string
+}
+
+// Builder derives a schema from Go types, as an alternative to
+// writing it in SDL.
+//
+// Structs are object types, or input object types when they hold
+// arguments. Their fields, and their methods that could resolve a
+// field as described in the package documentation, are the fields of
+// the type. Struct fields are described with tags:
+//
+//	type User struct {
+//		ID    bind.ID
+//		Name  string `graphql:"fullName" description:"The name of the user."`
+//		Email string `graphql:",nullable" deprecated:"Use contact."`
+//		Age   *int
+//	}
+//
+// Names default to the Go name with a lower case initial, and "-"
+// leaves a field out. Pointers and slices are nullable, other
+// types are not, unless the nullable or nonnull options say
+// otherwise. The default tag of argument and input fields holds a
+// GraphQL value.
+//
+// Enums, interfaces and scalars have to be registered with the
+// builder, objects that are only reachable through an interface too.
+type Builder struct {
+	roots       map[ast.OperationType]reflect.Type
+	names       map[reflect.Type]string
+	defs        []*typeDef
+	byName      map[string]*typeDef
+	scalars     map[string]schema.Scalar
+	description string
+	problems    []string
+}
+
+// typeDef is a type being derived.
+type typeDef struct {
+	kind        ast.TypeKind
+	name        string
+	description string
+	rt          reflect.Type
+	interfaces  []string
+	fields      []*fieldDef
+	values      []EnumValue
+	// done is set once the fields were derived.
+	done bool
+}
+
+// fieldDef is a field, an argument or an input field being derived.
+type fieldDef struct {
+	name        string
+	description string
+	typ         ast.Type
+	deprecated  string
+	def         string
+	args        []*fieldDef
+}
+
+// NewBuilder returns a builder for a schema with the description
+// desc, whose query root type is the Go type of query.
+func NewBuilder(desc string, query interface{}) *Builder {
+	b := &Builder{
+		roots:       make(map[ast.OperationType]reflect.Type),
+		names:       make(map[reflect.Type]string),
+		byName:      make(map[string]*typeDef),
+		scalars:     make(map[string]schema.Scalar),
+		description: desc,
+	}
+	b.root(ast.Query, query)
+	return b
+}
+
+func (b *Builder) problemf(format string, args ...interface{}) {
+	b.problems = append(b.problems, fmt.Sprintf(format, args...))
+}
+
+// Mutation sets the Go type of the mutation root type.
+func (b *Builder) Mutation(v interface{}) {
+	b.root(ast.Mutation, v)
+}
+
+func (b *Builder) root(op ast.OperationType, v interface{}) {
+	rt := indirect(reflect.TypeOf(v))
+	b.roots[op] = rt
+	b.add(ast.ObjectKind, op.String(), "", rt)
+}
+
+// Object adds the object type derived from the Go type of v, named
+// after it.
+func (b *Builder) Object(v interface{}, desc string) {
+	rt := indirect(reflect.TypeOf(v))
+	b.add(ast.ObjectKind, capital(rt.Name()), desc, rt)
+}
+
+// Interface adds an interface type with the methods of the Go
+// interface v points to, e.g. (*Node)(nil), as fields. Object types
+// whose Go types implement it implement the interface type.
+func (b *Builder) Interface(v interface{}, desc string) {
+	rt := reflect.TypeOf(v)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Interface {
+		b.problemf("interface %s must be a pointer to a Go interface", rt)
+		return
+	}
+	b.add(ast.InterfaceKind, capital(rt.Elem().Name()), desc, rt.Elem())
+}
+
+// Enum adds an enum type for the Go string type of v.
+func (b *Builder) Enum(v interface{}, desc string, values ...EnumValue) {
+	rt := reflect.TypeOf(v)
+	if rt.Kind() != reflect.String {
+		b.problemf("enum %s must be a string type", rt)
+		return
+	}
+	if t := b.add(ast.EnumKind, capital(rt.Name()), desc, rt); t != nil {
+		t.values = values
+	}
+}
+
+// Scalar adds the scalar type n for the Go type of v, which sc
+// implements.
+func (b *Builder) Scalar(v interface{}, n, desc string, sc schema.Scalar) {
+	if b.add(ast.ScalarKind, n, desc, indirect(reflect.TypeOf(v))) != nil {
+		b.scalars[n] = sc
+	}
+}
+
+// add adds the type n derived from rt, unless it was added already.
+func (b *Builder) add(kind ast.TypeKind, n, desc string, rt reflect.Type) *typeDef {
+	if t, ok := b.byName[n]; ok {
+		if t.rt != rt {
+			b.problemf("%s and %s are both named %s", t.rt, rt, n)
+		} else if t.kind != kind {
+			b.problemf("%s is used as both an %s and an %s", rt, schema.Kind(t.kind), schema.Kind(kind))
+		}
+		return nil
+	}
+	if m, ok := b.names[rt]; ok {
+		// the root types keep their names.
+		b.problemf("%s is both %s and %s", rt, m, n)
+		return nil
+	}
+	t := &typeDef{kind: kind, name: n, description: desc, rt: rt}
+	b.names[rt] = n
+	b.byName[n] = t
+	b.defs = append(b.defs, t)
+	return t
+}
+
+// typeOf returns the GraphQL type of values of rt, an input type if
+// input is set.
+func (b *Builder) typeOf(rt reflect.Type, input bool) (ast.Type, error) {
+	nullable := false
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+		nullable = true
+	}
+	var t ast.Type
+	if n, ok := b.names[rt]; ok {
+		def := b.byName[n]
+		switch {
+		case input && (def.kind == ast.ObjectKind || def.kind == ast.InterfaceKind):
+			return "", fmt.Errorf("%s is an %s, it can't be an input", rt, schema.Kind(def.kind))
+		case !input && def.kind == ast.InputObjectKind:
+			return "", fmt.Errorf("%s is an input object, it can't be an output", rt)
+		}
+		t = ast.Type(n)
+		nullable = nullable || def.kind == ast.InterfaceKind
+	} else {
+		switch k := rt.Kind(); {
+		case rt == idType:
+			t = "ID"
+		case k == reflect.Bool:
+			t = "Boolean"
+		case isInt(k):
+			t = "Int"
+		case k == reflect.Float32 || k == reflect.Float64:
+			t = "Float"
+		case k == reflect.String:
+			t = "String"
+		case k == reflect.Slice || k == reflect.Array:
+			elem, err := b.typeOf(rt.Elem(), input)
+			if err != nil {
+				return "", err
+			}
+			t = "[" + elem + "]"
+			nullable = nullable || k == reflect.Slice
+		case k == reflect.Struct && rt.Name() != "":
+			kind := ast.ObjectKind
+			if input {
+				kind = ast.InputObjectKind
+			}
+			b.add(kind, capital(rt.Name()), "", rt)
+			t = ast.Type(capital(rt.Name()))
+		case k == reflect.Interface:
+			return "", fmt.Errorf("%s is not a registered interface", rt)
+		default:
+			return "", fmt.Errorf("%s has no GraphQL type", rt)
+		}
+	}
+	if !nullable {
+		t += "!"
+	}
+	return t, nil
+}
+
+// derive derives the fields of t and the types they refer to.
+func (b *Builder) derive(t *typeDef) {
+	t.done = true
+	switch t.kind {
+	case ast.ObjectKind:
+		b.structFields(t, false)
+		b.methods(t, reflect.PtrTo(t.rt), 1)
+		for _, d := range b.defs {
+			if d.kind == ast.InterfaceKind && reflect.PtrTo(t.rt).Implements(d.rt) {
+				t.interfaces = append(t.interfaces, d.name)
+			}
+		}
+	case ast.InterfaceKind:
+		b.methods(t, t.rt, 0)
+	case ast.InputObjectKind:
+		b.structFields(t, true)
+	}
+}
+
+// structFields adds the exported fields of the struct t was derived
+// from, and those of the structs embedded in it.
+func (b *Builder) structFields(t *typeDef, input bool) {
+	if t.rt.Kind() != reflect.Struct {
+		return
+	}
+	// fields of structs embedded by pointer are nullable, the
+	// pointer may be nil.
+	var add func(rt reflect.Type, nullable bool)
+	add = func(rt reflect.Type, nullable bool) {
+		for i := 0; i < rt.NumField(); i++ {
+			sf := rt.Field(i)
+			if embedded(sf) {
+				add(indirect(sf.Type), nullable || sf.Type.Kind() == reflect.Ptr)
+				continue
+			}
+			if sf.PkgPath != "" || sf.Tag.Get("graphql") == "-" {
+				continue
+			}
+			f, err := b.field(sf, input)
+			if err != nil {
+				b.problemf("%s.%s: %v", t.name, sf.Name, err)
+				continue
+			}
+			if nullable && !input {
+				f.typ = f.typ.Nullable()
+			}
+			t.fields = append(t.fields, f)
+		}
+	}
+	add(t.rt, false)
+}
+
+// field derives a field, or an argument or input field if input is
+// set, from a struct field.
+func (b *Builder) field(sf reflect.StructField, input bool) (*fieldDef, error) {
+	typ, err := b.typeOf(sf.Type, input)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case hasOption(sf, "nullable"):
+		typ = typ.Nullable()
+	case hasOption(sf, "nonnull") && !typ.NonNull():
+		typ += "!"
+	}
+	f := &fieldDef{
+		name:        lowerName(sf),
+		description: sf.Tag.Get("description"),
+		typ:         typ,
+		deprecated:  sf.Tag.Get("deprecated"),
+	}
+	if input {
+		f.def = sf.Tag.Get("default")
+	}
+	return f, nil
+}
+
+// skipped are methods that satisfy common Go interfaces rather than
+// resolve fields.
+var skipped = map[string]bool{
+	"Error":         true,
+	"GoString":      true,
+	"MarshalJSON":   true,
+	"MarshalText":   true,
+	"String":        true,
+	"UnmarshalJSON": true,
+	"UnmarshalText": true,
+}
+
+// methods adds the methods of rt that can resolve fields, rt's
+// methods take in receivers. Methods replace struct fields that have
+// the same name.
+func (b *Builder) methods(t *typeDef, rt reflect.Type, in int) {
+	for i := 0; i < rt.NumMethod(); i++ {
+		m := rt.Method(i)
+		if skipped[m.Name] {
+			continue
+		}
+		_, args, err := signature(m.Type, in)
+		if err != nil {
+			continue
+		}
+		typ, err := b.typeOf(m.Type.Out(0), false)
+		if err != nil {
+			b.problemf("%s.%s: %v", t.name, m.Name, err)
+			continue
+		}
+		f := &fieldDef{name: lower(m.Name), typ: typ}
+		if args != nil {
+			st := indirect(args)
+			if st.Kind() != reflect.Struct {
+				b.problemf("%s.%s: arguments must be a struct, not %s", t.name, m.Name, args)
+				continue
+			}
+			for j := 0; j < st.NumField(); j++ {
+				sf := st.Field(j)
+				if sf.PkgPath != "" || sf.Tag.Get("graphql") == "-" {
+					continue
+				}
+				a, err := b.field(sf, true)
+				if err != nil {
+					b.problemf("%s.%s(%s:): %v", t.name, m.Name, sf.Name, err)
+					continue
+				}
+				f.args = append(f.args, a)
+			}
+		}
+		replaced := false
+		for j, sf := range t.fields {
+			if strings.EqualFold(sf.name, f.name) {
+				f.description, f.deprecated = sf.description, sf.deprecated
+				t.fields[j], replaced = f, true
+			}
+		}
+		if !replaced {
+			t.fields = append(t.fields, f)
+		}
+	}
+}
+
+// SDL returns the schema in the schema definition language.
+func (b *Builder) SDL() (string, error) {
+	for i := 0; i < len(b.defs); i++ {
+		if t := b.defs[i]; !t.done {
+			b.derive(t)
+		}
+	}
+	if len(b.problems) > 0 {
+		sort.Strings(b.problems)
+		return "", &Error{b.problems}
+	}
+	var buf bytes.Buffer
+	writeDescription(&buf, "", b.description)
+	buf.WriteString("schema {\n")
+	for _, op := range []ast.OperationType{ast.Query, ast.Mutation} {
+		if _, ok := b.roots[op]; ok {
+			fmt.Fprintf(&buf, "  %s: %s\n", strings.ToLower(op.String()), op)
+		}
+	}
+	buf.WriteString("}\n")
+	for _, t := range b.defs {
+		buf.WriteByte('\n')
+		writeType(&buf, t)
+	}
+	return buf.String(), nil
+}
+
+func writeType(buf *bytes.Buffer, t *typeDef) {
+	writeDescription(buf, "", t.description)
+	switch t.kind {
+	case ast.ScalarKind:
+		fmt.Fprintf(buf, "scalar %s\n", t.name)
+		return
+	case ast.EnumKind:
+		fmt.Fprintf(buf, "enum %s {\n", t.name)
+		for _, v := range t.values {
+			writeDescription(buf, "  ", v.Description)
+			fmt.Fprintf(buf, "  %s%s\n", v.Name, deprecated(v.Deprecated))
+		}
+		buf.WriteString("}\n")
+		return
+	case ast.ObjectKind:
+		fmt.Fprintf(buf, "type %s", t.name)
+		if len(t.interfaces) > 0 {
+			fmt.Fprintf(buf, " implements %s", strings.Join(t.interfaces, " & "))
+		}
+	case ast.InterfaceKind:
+		fmt.Fprintf(buf, "interface %s", t.name)
+	case ast.InputObjectKind:
+		fmt.Fprintf(buf, "input %s", t.name)
+	}
+	buf.WriteString(" {\n")
+	for _, f := range t.fields {
+		writeDescription(buf, "  ", f.description)
+		fmt.Fprintf(buf, "  %s", f.name)
+		if len(f.args) > 0 {
+			buf.WriteByte('(')
+			for i, a := range f.args {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				if a.description != "" {
+					fmt.Fprintf(buf, "%s ", ast.Quote(a.description))
+				}
+				writeInputValue(buf, a)
+			}
+			buf.WriteByte(')')
+		}
+		if t.kind == ast.InputObjectKind {
+			buf.WriteString(": ")
+			buf.WriteString(string(f.typ))
+			if f.def != "" {
+				fmt.Fprintf(buf, " = %s", f.def)
+			}
+			fmt.Fprintf(buf, "%s\n", deprecated(f.deprecated))
+			continue
+		}
+		fmt.Fprintf(buf, ": %s%s\n", f.typ, deprecated(f.deprecated))
+	}
+	buf.WriteString("}\n")
+}
+
+func writeInputValue(buf *bytes.Buffer, a *fieldDef) {
+	fmt.Fprintf(buf, "%s: %s", a.name, a.typ)
+	if a.def != "" {
+		fmt.Fprintf(buf, " = %s", a.def)
+	}
+	buf.WriteString(deprecated(a.deprecated))
+}
+
+func writeDescription(buf *bytes.Buffer, indent, desc string) {
+	if desc != "" {
+		fmt.Fprintf(buf, "%s%s\n", indent, ast.Quote(desc))
+	}
+}
+
+func deprecated(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" @deprecated(reason: %s)", ast.Quote(reason))
+}
+
+// Schema parses the SDL of the schema and sets the implementations of
+// its scalars.
+func (b *Builder) Schema() (*schema.Schema, error) {
+	sdl, err := b.SDL()
+	if err != nil {
+		return nil, err
+	}
+	s, err := schema.Parse("builder", strings.NewReader(sdl))
+	if err != nil {
+		return nil, err
+	}
+	for n, sc := range b.scalars {
+		if err := s.SetScalar(ast.GraphQLName(n), sc); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Executor returns an executor for the schema, with the Go types it
+// was derived from bound to it.
+func (b *Builder) Executor() (*executor.Executor, error) {
+	s, err := b.Schema()
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]interface{})
+	for _, t := range b.defs {
+		if t.kind == ast.ObjectKind {
+			types[t.name] = reflect.Zero(reflect.PtrTo(t.rt)).Interface()
+		}
+	}
+	return Bind(s, types)
+}
+
+// lowerName returns the GraphQL name of the struct field sf.
+func lowerName(sf reflect.StructField) string {
+	if n := fieldName(sf); n != sf.Name {
+		return n
+	}
+	return lower(sf.Name)
+}
+
+// lower turns the leading upper case letters of a Go name to lower
+// case, leaving the last one of a run alone if a word follows it, so
+// ID is id and URLPath is urlPath.
+func lower(n string) string {
+	r := []rune(n)
+	for i := range r {
+		if !unicode.IsUpper(r[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+func capital(n string) string {
+	r := []rune(n)
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
+
+// hasOption reports whether the graphql tag of sf has the option o.
+func hasOption(sf reflect.StructField, o string) bool {
+	opts := strings.Split(sf.Tag.Get("graphql"), ",")
+	for _, opt := range opts[1:] {
+		if opt == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bind // import "sevki.org/graphql/bind"
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/schema"
+)
+
+// check reports whether values of the Go type rt can be the result of
+// a field of type t.
+//
+// Nullability isn't checked, the executor reports nil values of
+// non-null fields when it runs into them.
+func (b *binder) check(t ast.Type, rt reflect.Type) error {
+	if rt.Kind() == reflect.Interface && rt.NumMethod() == 0 {
+		return nil
+	}
+	t = t.Nullable()
+	if t.List() {
+		lt := indirect(rt)
+		if lt.Kind() != reflect.Slice && lt.Kind() != reflect.Array {
+			return fmt.Errorf("returns %s, not a list for %s", rt, t)
+		}
+		if err := b.check(t.Elem(), lt.Elem()); err != nil {
+			return fmt.Errorf("%v in %s", err, t)
+		}
+		return nil
+	}
+	def := b.s.Type(t)
+	if def == nil {
+		return fmt.Errorf("returns the unknown type %s", t)
+	}
+	switch def.Kind {
+	case ast.ScalarKind, ast.EnumKind:
+		if !leaf(def, indirect(rt)) {
+			return fmt.Errorf("returns %s, not %s", rt, def.Name)
+		}
+	case ast.ObjectKind:
+		if bt, ok := b.types[def.Name]; ok && rt.Kind() != reflect.Interface && indirect(rt) != bt {
+			return fmt.Errorf("returns %s, not %s which %s is bound to", rt, bt, def.Name)
+		}
+	case ast.InterfaceKind, ast.UnionKind:
+		if rt.Kind() == reflect.Interface {
+			return nil
+		}
+		for _, o := range b.s.PossibleTypes(def) {
+			if bt, ok := b.types[o.Name]; ok && indirect(rt) == bt {
+				return nil
+			}
+		}
+		return fmt.Errorf("returns %s, which is bound to none of the possible types of %s", rt, def.Name)
+	default:
+		return fmt.Errorf("returns the %s %s", schema.Kind(def.Kind), def.Name)
+	}
+	return nil
+}
+
+// checkInput reports whether arguments of type t can be decoded into
+// the Go type rt.
+func (b *binder) checkInput(t ast.Type, rt reflect.Type) error {
+	if rt.Kind() == reflect.Interface && rt.NumMethod() == 0 {
+		return nil
+	}
+	t = t.Nullable()
+	if t.List() {
+		lt := indirect(rt)
+		if lt.Kind() != reflect.Slice {
+			return fmt.Errorf("is a %s, it can't hold %s", rt, t)
+		}
+		if err := b.checkInput(t.Elem(), lt.Elem()); err != nil {
+			return fmt.Errorf("%v in %s", err, t)
+		}
+		return nil
+	}
+	def := b.s.Type(t)
+	if def == nil {
+		return fmt.Errorf("has the unknown type %s", t)
+	}
+	st := indirect(rt)
+	switch def.Kind {
+	case ast.ScalarKind, ast.EnumKind:
+		if !leaf(def, st) {
+			return fmt.Errorf("is a %s, it can't hold %s", rt, def.Name)
+		}
+	case ast.InputObjectKind:
+		if st.Kind() == reflect.Map && st.Key().Kind() == reflect.String {
+			return nil
+		}
+		if st.Kind() != reflect.Struct {
+			return fmt.Errorf("is a %s, it can't hold %s", rt, def.Name)
+		}
+		for _, f := range def.Fields {
+			sf, ok := structField(st, string(f.Name))
+			if !ok {
+				return fmt.Errorf("is a %s, it has no field for %s.%s", rt, def.Name, f.Name)
+			}
+			if err := b.checkInput(f.Type, sf.Type); err != nil {
+				return fmt.Errorf("field %s %v", sf.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("has the %s %s", schema.Kind(def.Kind), def.Name)
+	}
+	return nil
+}
+
+// leaf reports whether values of the scalar or enum type def and the
+// Go type rt can be converted to each other.
+func leaf(def *ast.TypeDefinition, rt reflect.Type) bool {
+	k := rt.Kind()
+	switch {
+	case def.Kind == ast.EnumKind:
+		return k == reflect.String
+	case !schema.IsBuiltinScalar(def.Name):
+		// custom scalars convert values themselves.
+		return true
+	}
+	switch def.Name {
+	case "Int":
+		return isInt(k)
+	case "Float":
+		return isInt(k) || k == reflect.Float32 || k == reflect.Float64
+	case "String":
+		return k == reflect.String
+	case "Boolean":
+		return k == reflect.Bool
+	case "ID":
+		return k == reflect.String || isInt(k)
+	}
+	return false
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Uint64
+}
+
+// Decode stores v, a value coerced by the executor like the arguments
+// of a field, in the value out points to. Struct fields are matched
+// with the names of input object fields the way bound resolvers'
+// argument structs are.
+func Decode(v interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bind: Decode needs a non-nil pointer, got %T", out)
+	}
+	d, err := decode(v, rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(d)
+	return nil
+}
+
+// decode converts a value coerced by the executor, see
+// executor.CoerceLiteral, to the Go type rt.
+func decode(v interface{}, rt reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(rt), nil
+	}
+	switch rt.Kind() {
+	case reflect.Ptr:
+		e, err := decode(v, rt.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(rt.Elem())
+		p.Elem().Set(e)
+		return p, nil
+	case reflect.Slice:
+		l, ok := v.([]interface{})
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(rt, len(l), len(l))
+		for i, item := range l {
+			e, err := decode(item, rt.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(e)
+		}
+		return s, nil
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		s := reflect.New(rt).Elem()
+		for k, item := range m {
+			sf, ok := structField(rt, k)
+			if !ok {
+				continue
+			}
+			e, err := decode(item, sf.Type)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if f, ok := fieldByIndex(s, sf.Index, true); ok {
+				f.Set(e)
+			}
+		}
+		return s, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Type().AssignableTo(rt):
+		r := reflect.New(rt).Elem()
+		r.Set(rv)
+		return r, nil
+	case rv.Kind() == reflect.String && isInt(rt.Kind()):
+		// IDs are coerced to strings.
+		i, err := strconv.ParseInt(rv.String(), 10, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("can't decode %q into %s", v, rt)
+		}
+		return reflect.ValueOf(i).Convert(rt), nil
+	case rv.Type().ConvertibleTo(rt) && (rv.Kind() == reflect.String) == (rt.Kind() == reflect.String):
+		return rv.Convert(rt), nil
+	}
+	return reflect.Value{}, fmt.Errorf("can't decode %T into %s", v, rt)
+}
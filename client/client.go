@@ -0,0 +1,129 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package client sends GraphQL operations to servers over HTTP, it is
+// what the code gqlgen-client generates runs on.
+package client // import "sevki.org/graphql/client"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// Client sends operations to the GraphQL endpoint at URL.
+type Client struct {
+	URL string
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// Header is added to every request.
+	Header http.Header
+}
+
+// New returns a client for the endpoint at url.
+func New(url string) *Client {
+	return &Client{URL: url, Header: make(http.Header)}
+}
+
+// Error is an error the server responded with, as defined in
+// http://facebook.github.io/graphql/#sec-Errors
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []ast.Position         `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	p := make([]string, len(e.Path))
+	for i, k := range e.Path {
+		p[i] = fmt.Sprint(k)
+	}
+	return strings.Join(p, ".") + ": " + e.Message
+}
+
+// Errors are all the errors of a response.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type request struct {
+	Query         string      `json:"query"`
+	OperationName string      `json:"operationName,omitempty"`
+	Variables     interface{} `json:"variables,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors"`
+}
+
+// Do sends the operation named operationName in query with variables
+// and decodes the data of the response into data.
+//
+// If the response has errors they are returned as Errors, data holds
+// whatever the server could resolve in spite of them.
+func (c *Client) Do(ctx context.Context, query, operationName string, variables, data interface{}) error {
+	body, err := json.Marshal(request{query, operationName, variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/graphql-response+json, application/json")
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("client: server responded %s", resp.Status)
+		}
+		return fmt.Errorf("client: decoding response: %v", err)
+	}
+	// drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	if len(r.Data) > 0 && string(r.Data) != "null" && data != nil {
+		if err := json.Unmarshal(r.Data, data); err != nil {
+			return fmt.Errorf("client: decoding data: %v", err)
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("client: server responded %s", resp.Status)
+	}
+	return nil
+}
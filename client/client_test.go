@@ -0,0 +1,62 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client // import "sevki.org/graphql/client"
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer x" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/graphql-response+json")
+		switch req.OperationName {
+		case "Me":
+			vars := req.Variables.(map[string]interface{})
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"me": map[string]interface{}{"name": vars["name"]}},
+			})
+		default:
+			w.Write([]byte(`{"data":{"me":null},"errors":[{"message":"boom","path":["me"]}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	var data struct {
+		Me *struct {
+			Name string `json:"name"`
+		} `json:"me"`
+	}
+	if err := c.Do(context.Background(), "query Me { me { name } }", "Me", map[string]string{"name": "ann"}, &data); err == nil {
+		t.Error("expected an unauthorized request to fail")
+	}
+	c.Header.Set("Authorization", "Bearer x")
+	if err := c.Do(context.Background(), "query Me { me { name } }", "Me", map[string]string{"name": "ann"}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Me == nil || data.Me.Name != "ann" {
+		t.Errorf("got %+v", data.Me)
+	}
+	err := c.Do(context.Background(), "query Fail { me { name } }", "Fail", nil, &data)
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 || errs.Error() != "me: boom" {
+		t.Errorf("got %v", err)
+	}
+	if data.Me != nil {
+		t.Errorf("me should be null, got %+v", data.Me)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command gqldiff lists the changes between two GraphQL schemas written
+in the schema definition language.
+
+Usage:
+
+	gqldiff [-dangerous] old.graphql new.graphql
+
+Every change is printed on its own line as
+
+	CRITICALITY	PATH	MESSAGE
+
+where criticality is one of BREAKING, DANGEROUS or SAFE. gqldiff
+exits with status 1 if any of the changes is breaking, or with
+-dangerous, dangerous, so it can be used to guard merges in CI. A
+status of 2 means one of the schemas couldn't be read.
+*/
+package main // import "sevki.org/graphql/cmd/gqldiff"
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sevki.org/graphql/schema"
+)
+
+var dangerous = flag.Bool("dangerous", false, "fail on dangerous changes too")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gqldiff [-dangerous] old.graphql new.graphql\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+	}
+	old, err := load(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gqldiff: %v\n", err)
+		os.Exit(2)
+	}
+	new, err := load(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gqldiff: %v\n", err)
+		os.Exit(2)
+	}
+
+	fail := false
+	for _, c := range schema.Diff(old, new) {
+		fmt.Printf("%s\t%s\t%s\n", strings.ToUpper(c.Criticality.String()), c.Path, c.Message)
+		if c.Criticality == schema.Breaking || (*dangerous && c.Criticality == schema.Dangerous) {
+			fail = true
+		}
+	}
+	if fail {
+		os.Exit(1)
+	}
+}
+
+func load(name string) (*schema.Schema, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := schema.Parse(name, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,335 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main // import "sevki.org/graphql/cmd/gqlgen-client"
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/internal/gen"
+	"sevki.org/graphql/schema"
+	"sevki.org/graphql/validation"
+)
+
+// generator writes the Go code for the operations of a document.
+type generator struct {
+	s         *schema.Schema
+	fragments map[ast.GraphQLName]*ast.Fragment
+	// decls holds the declarations of the types generated so far
+	// by name.
+	decls map[string]string
+	// named are the enums and input objects the operations use.
+	named map[ast.GraphQLName]bool
+	// raw is set if a custom scalar was decoded as json.RawMessage.
+	raw bool
+}
+
+// generate returns the Go source of package pkg with types and
+// functions for the operations in doc, which must be valid against s.
+func generate(s *schema.Schema, doc *ast.Document, pkg string) ([]byte, error) {
+	if errs := validation.ValidateWithSchema(s, doc); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+	g := &generator{
+		s:         s,
+		fragments: executor.Fragments(doc),
+		decls:     make(map[string]string),
+		named:     make(map[ast.GraphQLName]bool),
+	}
+	var ops bytes.Buffer
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.Operation)
+		if !ok {
+			continue
+		}
+		if err := g.operation(&ops, op); err != nil {
+			return nil, err
+		}
+	}
+	// input objects can use more enums and input objects.
+	done := make(map[ast.GraphQLName]bool)
+	for len(done) < len(g.named) {
+		for n := range g.named {
+			if done[n] {
+				continue
+			}
+			done[n] = true
+			if err := g.namedType(s.Types[n]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gqlgen-client. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	buf.WriteString("import (\n\t\"context\"\n")
+	if g.raw {
+		buf.WriteString("\t\"encoding/json\"\n")
+	}
+	buf.WriteString("\n\t\"sevki.org/graphql/client\"\n)\n")
+	buf.Write(ops.Bytes())
+	names := make([]string, 0, len(g.decls))
+	for n := range g.decls {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		buf.WriteString("\n" + g.decls[n])
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+// declare adds the declaration of the Go type n.
+func (g *generator) declare(n, decl string) error {
+	if _, ok := g.decls[n]; ok {
+		return fmt.Errorf("more than one type is named %s", n)
+	}
+	g.decls[n] = decl
+	return nil
+}
+
+// operation writes the document, the function that runs op and the
+// types of its variables and response.
+func (g *generator) operation(buf *bytes.Buffer, op *ast.Operation) error {
+	if op.Name == "" {
+		return fmt.Errorf("%s: operations must be named to generate code for them", op.Pos)
+	}
+	if op.OperationType == ast.Subscription {
+		return fmt.Errorf("%s: %s: subscriptions are not supported", op.Pos, op.Name)
+	}
+	name := gen.Exported(string(op.Name))
+	root := g.s.Root(op.OperationType)
+	kind := strings.ToLower(op.OperationType.String())
+
+	fmt.Fprintf(buf, "\n// %sDocument is the %s %s.\n", gen.Unexported(name), op.Name, kind)
+	fmt.Fprintf(buf, "const %sDocument = %q\n", gen.Unexported(name), printOperation(op, g.fragments))
+
+	resp := name + "Response"
+	if err := g.object(resp, root, op.SelectionSet); err != nil {
+		return err
+	}
+	vars := "nil"
+	params := ""
+	if len(op.VariableDefinitions) > 0 {
+		vt := name + "Variables"
+		var decl bytes.Buffer
+		fmt.Fprintf(&decl, "// %s are the variables of %s.\ntype %s struct {\n", vt, op.Name, vt)
+		for _, v := range op.VariableDefinitions {
+			fmt.Fprintf(&decl, "\t%s %s `json:\"%s%s\"`\n", gen.Exported(string(v.Name)), g.inputType(v.Type), v.Name, omitempty(v.Type))
+		}
+		decl.WriteString("}\n")
+		if err := g.declare(vt, decl.String()); err != nil {
+			return err
+		}
+		vars, params = "vars", ", vars *"+vt
+	}
+
+	fmt.Fprintf(buf, "\n// %s runs the %s %s.\n", name, op.Name, kind)
+	fmt.Fprintf(buf, "func %s(ctx context.Context, c *client.Client%s) (*%s, error) {\n", name, params, resp)
+	fmt.Fprintf(buf, "\tvar resp %s\n", resp)
+	fmt.Fprintf(buf, "\terr := c.Do(ctx, %sDocument, %q, %s, &resp)\n", gen.Unexported(name), op.Name, vars)
+	buf.WriteString("\treturn &resp, err\n}\n")
+	return nil
+}
+
+// field is a response key of a selection set with everything that is
+// selected under it.
+type field struct {
+	key string
+	def *ast.FieldDefinition
+	set ast.SelectionSet
+	// optional is set if the field may be missing from the response,
+	// because of the type condition of a fragment or a directive.
+	optional bool
+}
+
+// collect groups the fields of set, selected on t, by response key.
+func (g *generator) collect(t *ast.TypeDefinition, set ast.SelectionSet) []*field {
+	var fields []*field
+	byKey := make(map[string]*field)
+	var walk func(t *ast.TypeDefinition, set ast.SelectionSet, optional bool)
+	walk = func(t *ast.TypeDefinition, set ast.SelectionSet, optional bool) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				k := string(sel.Name)
+				if sel.Alias != "" {
+					k = string(sel.Alias)
+				}
+				opt := optional || conditional(sel.Directives)
+				f, ok := byKey[k]
+				if !ok {
+					f = &field{key: k, def: g.s.Field(t, sel.Name), optional: opt}
+					byKey[k] = f
+					fields = append(fields, f)
+				}
+				// a field is optional if every selection of it is.
+				f.optional = f.optional && opt
+				f.set = append(f.set, sel.SelectionSet...)
+			case *ast.Fragment:
+				cond, set := sel.TypeCondition, sel.SelectionSet
+				if isSpread(sel) {
+					frag, ok := g.fragments[sel.FragmentName]
+					if !ok {
+						continue
+					}
+					cond, set = frag.TypeCondition, frag.SelectionSet
+				}
+				ct := t
+				if cond != "" {
+					ct = g.s.Types[cond]
+				}
+				walk(ct, set, optional || conditional(sel.Directives) || !g.covers(ct, t))
+			}
+		}
+	}
+	walk(t, set, false)
+	return fields
+}
+
+// covers reports whether a fragment on cond applies to every value of
+// type t.
+func (g *generator) covers(cond, t *ast.TypeDefinition) bool {
+	if cond == t {
+		return true
+	}
+	return t.Kind == ast.ObjectKind && g.s.IsPossibleType(cond, t)
+}
+
+func conditional(dirs ast.Directives) bool {
+	return dirs.Get("skip") != nil || dirs.Get("include") != nil
+}
+
+// object declares the struct n for the selection set set on t.
+func (g *generator) object(n string, t *ast.TypeDefinition, set ast.SelectionSet) error {
+	var decl bytes.Buffer
+	fmt.Fprintf(&decl, "// %s is selected on %s.\ntype %s struct {\n", n, t.Name, n)
+	for _, f := range g.collect(t, set) {
+		if f.def == nil {
+			return fmt.Errorf("%s has no field %s", t.Name, f.key)
+		}
+		typ, err := g.outputType(n+gen.Exported(f.key), f.def.Type, f.set, f.optional)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&decl, "\t%s %s `json:\"%s\"`\n", gen.Exported(f.key), typ, f.key)
+	}
+	decl.WriteString("}\n")
+	return g.declare(n, decl.String())
+}
+
+// outputType returns the Go type of values of typ in responses,
+// declaring the struct n for its selection set set if it has one.
+func (g *generator) outputType(n string, typ ast.Type, set ast.SelectionSet, optional bool) (string, error) {
+	nullable := optional || !typ.NonNull()
+	typ = typ.Nullable()
+	var gt string
+	if typ.List() {
+		elem, err := g.outputType(n, typ.Elem(), set, false)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	}
+	def := g.s.Type(typ)
+	switch def.Kind {
+	case ast.ObjectKind, ast.InterfaceKind, ast.UnionKind:
+		if err := g.object(n, def, set); err != nil {
+			return "", err
+		}
+		gt = n
+	default:
+		gt = g.leafType(def)
+	}
+	if nullable && gt != "json.RawMessage" {
+		gt = "*" + gt
+	}
+	return gt, nil
+}
+
+// inputType returns the Go type of values of typ in variables.
+func (g *generator) inputType(typ ast.Type) string {
+	nullable := !typ.NonNull()
+	typ = typ.Nullable()
+	if typ.List() {
+		return "[]" + g.inputType(typ.Elem())
+	}
+	def := g.s.Type(typ)
+	gt := gen.Exported(string(def.Name))
+	if def.Kind == ast.InputObjectKind {
+		g.named[def.Name] = true
+	} else {
+		gt = g.leafType(def)
+	}
+	if nullable && gt != "json.RawMessage" {
+		gt = "*" + gt
+	}
+	return gt
+}
+
+// leafType returns the Go type of a scalar or enum.
+func (g *generator) leafType(def *ast.TypeDefinition) string {
+	switch def.Name {
+	case "Int":
+		return "int"
+	case "Float":
+		return "float64"
+	case "String", "ID":
+		return "string"
+	case "Boolean":
+		return "bool"
+	}
+	if def.Kind == ast.EnumKind {
+		g.named[def.Name] = true
+		return gen.Exported(string(def.Name))
+	}
+	g.raw = true
+	return "json.RawMessage"
+}
+
+// namedType declares the Go type of an enum or input object.
+func (g *generator) namedType(def *ast.TypeDefinition) error {
+	n := gen.Exported(string(def.Name))
+	var decl bytes.Buffer
+	if def.Description != "" {
+		fmt.Fprintf(&decl, "// %s: %s\n", n, gen.OneLine(def.Description))
+	} else {
+		fmt.Fprintf(&decl, "// %s is the %s %s.\n", n, schema.Kind(def.Kind), def.Name)
+	}
+	if def.Kind == ast.EnumKind {
+		fmt.Fprintf(&decl, "type %s string\n\nconst (\n", n)
+		for _, v := range def.EnumValues {
+			fmt.Fprintf(&decl, "\t%s%s %s = %q\n", n, gen.Exported(strings.ToLower(string(v.Name))), n, v.Name)
+		}
+		decl.WriteString(")\n")
+		return g.declare(n, decl.String())
+	}
+	fmt.Fprintf(&decl, "type %s struct {\n", n)
+	for _, f := range def.Fields {
+		fmt.Fprintf(&decl, "\t%s %s `json:\"%s%s\"`\n", gen.Exported(string(f.Name)), g.inputType(f.Type), f.Name, omitempty(f.Type))
+	}
+	decl.WriteString("}\n")
+	return g.declare(n, decl.String())
+}
+
+func omitempty(t ast.Type) string {
+	if t.NonNull() {
+		return ""
+	}
+	return ",omitempty"
+}
@@ -0,0 +1,136 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main // import "sevki.org/graphql/cmd/gqlgen-client"
+
+import (
+	"go/ast"
+	"go/importer"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"sevki.org/graphql/parser"
+)
+
+func generateString(t *testing.T, ops string) (string, error) {
+	s, err := loadSchema("../../tests/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parser.NewQuery([]byte(ops))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(s, doc, "gen")
+	return string(src), err
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := generateString(t, `
+query GetMe($first: Int = 2, $filter: ComplexType) {
+  me {
+    ...userFields
+    best: field2 { name }
+    friends(first: $first) { id }
+  }
+  search(text: "x") {
+    __typename
+    ... on Named { name }
+    ... on Picture { url(size: 10) }
+  }
+  node(id: ["1"]) @include(if: true) { id }
+}
+fragment userFields on User { id name somepoo(after: $filter) { id } }
+mutation Like($story: ID!) { like(story: $story) { story { likes } } }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func GetMe(ctx context.Context, c *client.Client, vars *GetMeVariables) (*GetMeResponse, error) {",
+		"err := c.Do(ctx, getMeDocument, \"GetMe\", vars, &resp)",
+		"func Like(ctx context.Context, c *client.Client, vars *LikeVariables) (*LikeResponse, error) {",
+		"fragment userFields on User {",
+		"Node   *GetMeResponseNode     `json:\"node\"`",
+		"ID      string                   `json:\"id\"`",
+		"Best    *GetMeResponseMeBest     `json:\"best\"`",
+		"Friends []GetMeResponseMeFriends `json:\"friends\"`",
+		"Typename string  `json:\"__typename\"`",
+		"URL      *string `json:\"url\"`",
+		"First  *int         `json:\"first,omitempty\"`",
+		"Site *Site    `json:\"site,omitempty\"`",
+		"Story LikeResponseLikeStory `json:\"story\"`",
+		"SiteMobile  Site = \"MOBILE\"",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code doesn't contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateDocument(t *testing.T) {
+	src, err := generateString(t, "query Find { search(text: \"`x`\") { __typename } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "const findDocument = \"query Find {\\n  search(text: \\\"`x`\\\") {\\n    __typename\\n  }\\n}\""
+	if !strings.Contains(src, want) {
+		t.Errorf("generated code doesn't contain %s:\n%s", want, src)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, test := range []struct {
+		ops, err string
+	}{
+		{`{ me { id } }`, "operations must be named"},
+		{`query A { me { nope } }`, `Cannot query field "nope" on type "User".`},
+		{`subscription S { storyLiked(story: "1") { id } }`, "subscriptions are not supported"},
+		{`query A { me { id } } query a { me { id } }`, "more than one type is named AResponse"},
+	} {
+		_, err := generateString(t, test.ops)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, expected %q", test.ops, err, test.err)
+		}
+	}
+}
+
+// typecheck makes sure src compiles, the packages it imports are read
+// from source.
+func typecheck(t *testing.T, src []byte) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("gen", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("generated code doesn't compile: %v\n%s", err, src)
+	}
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	src, err := generateString(t, `
+query GetMe($first: Int = 2, $filter: ComplexType) {
+  me {
+    ...userFields
+    friends(first: $first) { id name }
+  }
+  search(text: "x") {
+    __typename
+    ... on Named { name }
+    ... on Picture { url(size: 10) }
+  }
+}
+fragment userFields on User { id name somepoo(after: $filter) { id } }
+mutation Like($story: ID!) { like(story: $story) { story { likes } } }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typecheck(t, []byte(src))
+}
@@ -0,0 +1,118 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command gqlgen-client generates typed Go code for the GraphQL
+operations a service sends to another one.
+
+Usage:
+
+	gqlgen-client -schema schema.graphql [-package name] [-o file] operations.graphql...
+
+The operations are checked against the schema. For every named query
+or mutation it generates
+
+	const getUserDocument = "query GetUser($id: ID!) { ... }"
+	type GetUserVariables struct { ... }
+	type GetUserResponse struct { ... }
+	func GetUser(ctx context.Context, c *client.Client, vars *GetUserVariables) (*GetUserResponse, error)
+
+and a struct for every selection set in the response, named after the
+path of response keys that leads to it, so aliases are respected.
+Fragments are merged into the structs they are spread in, fields
+that may be missing from a response, because of a type condition,
+@skip or @include, are pointers like those of nullable fields. Enums
+and input objects get Go types of their own, custom scalars are left
+as json.RawMessage.
+
+The generated code sends operations with package
+sevki.org/graphql/client. Without -o it is written to standard
+output.
+*/
+package main // import "sevki.org/graphql/cmd/gqlgen-client"
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/parser"
+	"sevki.org/graphql/schema"
+)
+
+var (
+	schemaFile = flag.String("schema", "", "the schema the operations are sent to")
+	pkg        = flag.String("package", "client", "the package of the generated code")
+	out        = flag.String("o", "", "the file to write the generated code to")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gqlgen-client -schema schema.graphql [-package name] [-o file] operations.graphql...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *schemaFile == "" || flag.NArg() == 0 {
+		usage()
+	}
+	s, err := loadSchema(*schemaFile)
+	if err != nil {
+		fatal(err)
+	}
+	doc := &ast.Document{}
+	for _, name := range flag.Args() {
+		d, err := loadOperations(name)
+		if err != nil {
+			fatal(err)
+		}
+		doc.Definitions = append(doc.Definitions, d.Definitions...)
+	}
+	src, err := generate(s, doc, *pkg)
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gqlgen-client: %v\n", err)
+	os.Exit(1)
+}
+
+func loadSchema(name string) (*schema.Schema, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := schema.Parse(name, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
+
+func loadOperations(name string) (*ast.Document, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var doc ast.Document
+	if err := parser.New(name, f).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &doc, nil
+}
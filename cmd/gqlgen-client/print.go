@@ -0,0 +1,117 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main // import "sevki.org/graphql/cmd/gqlgen-client"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// printOperation writes op and the fragment definitions it uses back
+// out as a document.
+func printOperation(op *ast.Operation, fragments map[ast.GraphQLName]*ast.Fragment) string {
+	var buf bytes.Buffer
+	buf.WriteString(strings.ToLower(op.OperationType.String()))
+	fmt.Fprintf(&buf, " %s", op.Name)
+	if len(op.VariableDefinitions) > 0 {
+		vars := make([]string, len(op.VariableDefinitions))
+		for i, v := range op.VariableDefinitions {
+			vars[i] = fmt.Sprintf("$%s: %s", v.Name, v.Type)
+			if v.DefaultValue != nil {
+				vars[i] += " = " + ast.FormatValue(v.DefaultValue)
+			}
+		}
+		fmt.Fprintf(&buf, "(%s)", strings.Join(vars, ", "))
+	}
+	printDirectives(&buf, op.Directives)
+	printSelectionSet(&buf, op.SelectionSet, "")
+	used := make(map[ast.GraphQLName]bool)
+	var order []ast.GraphQLName
+	var spreads func(set ast.SelectionSet)
+	spreads = func(set ast.SelectionSet) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				spreads(sel.SelectionSet)
+			case *ast.Fragment:
+				if isSpread(sel) {
+					if f, ok := fragments[sel.FragmentName]; ok && !used[sel.FragmentName] {
+						used[sel.FragmentName] = true
+						order = append(order, sel.FragmentName)
+						spreads(f.SelectionSet)
+					}
+					continue
+				}
+				spreads(sel.SelectionSet)
+			}
+		}
+	}
+	spreads(op.SelectionSet)
+	for _, n := range order {
+		f := fragments[n]
+		fmt.Fprintf(&buf, "\nfragment %s on %s", f.FragmentName, f.TypeCondition)
+		printDirectives(&buf, f.Directives)
+		printSelectionSet(&buf, f.SelectionSet, "")
+	}
+	return buf.String()
+}
+
+func printSelectionSet(buf *bytes.Buffer, set ast.SelectionSet, indent string) {
+	buf.WriteString(" {\n")
+	for _, sel := range set {
+		buf.WriteString(indent + "  ")
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if sel.Alias != "" {
+				fmt.Fprintf(buf, "%s: ", sel.Alias)
+			}
+			buf.WriteString(string(sel.Name))
+			printArguments(buf, sel.Arguments)
+			printDirectives(buf, sel.Directives)
+			if len(sel.SelectionSet) > 0 {
+				printSelectionSet(buf, sel.SelectionSet, indent+"  ")
+			}
+		case *ast.Fragment:
+			if isSpread(sel) {
+				fmt.Fprintf(buf, "...%s", sel.FragmentName)
+				printDirectives(buf, sel.Directives)
+				break
+			}
+			buf.WriteString("...")
+			if sel.TypeCondition != "" {
+				fmt.Fprintf(buf, " on %s", sel.TypeCondition)
+			}
+			printDirectives(buf, sel.Directives)
+			printSelectionSet(buf, sel.SelectionSet, indent+"  ")
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(indent + "}")
+}
+
+func printArguments(buf *bytes.Buffer, args ast.Arguments) {
+	if len(args) == 0 {
+		return
+	}
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = fmt.Sprintf("%s: %s", a.Name, ast.FormatValue(a.Value))
+	}
+	fmt.Fprintf(buf, "(%s)", strings.Join(s, ", "))
+}
+
+func printDirectives(buf *bytes.Buffer, dirs ast.Directives) {
+	for _, d := range dirs {
+		fmt.Fprintf(buf, " @%s", d.Name)
+		printArguments(buf, d.Arguments)
+	}
+}
+
+func isSpread(f *ast.Fragment) bool {
+	return f.FragmentName != "" && f.TypeCondition == ""
+}
@@ -0,0 +1,262 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main // import "sevki.org/graphql/cmd/gqlgen-server"
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/internal/gen"
+	"sevki.org/graphql/schema"
+)
+
+// generator writes the Go code for the types of a schema.
+type generator struct {
+	s   *schema.Schema
+	buf bytes.Buffer
+	// names are the Go names declared so far.
+	names map[string]bool
+}
+
+// generate returns the Go source of package pkg with resolver
+// interfaces for the object types of s, types for its enums and input
+// objects and the code that registers resolvers with an executor.
+func generate(s *schema.Schema, pkg string) ([]byte, error) {
+	g := &generator{s: s, names: make(map[string]bool)}
+	fmt.Fprintf(&g.buf, "// Code generated by gqlgen-server. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	g.buf.WriteString("import (\n\t\"context\"\n\n\t\"sevki.org/graphql/bind\"\n\t\"sevki.org/graphql/executor\"\n)\n")
+
+	var objects []*ast.TypeDefinition
+	for _, t := range s.TypeList() {
+		if strings.HasPrefix(string(t.Name), "__") || schema.IsBuiltinScalar(t.Name) {
+			continue
+		}
+		var err error
+		switch t.Kind {
+		case ast.ObjectKind:
+			objects = append(objects, t)
+			err = g.object(t)
+		case ast.InterfaceKind, ast.UnionKind:
+			err = g.abstract(t)
+		case ast.EnumKind:
+			err = g.enum(t)
+		case ast.InputObjectKind:
+			err = g.input(t)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := g.register(objects); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, g.buf.Bytes())
+	}
+	return src, nil
+}
+
+// declare reserves the Go name n.
+func (g *generator) declare(n string) error {
+	if g.names[n] {
+		return fmt.Errorf("more than one type is named %s", n)
+	}
+	g.names[n] = true
+	return nil
+}
+
+// comment writes the doc comment of a Go declaration, the sentence
+// about the declaration followed by the description of the GraphQL
+// definition and the reason it is deprecated, if it has them.
+func (g *generator) comment(indent, sentence, desc string, dirs ast.Directives) {
+	fmt.Fprintf(&g.buf, "%s// %s\n", indent, sentence)
+	if desc != "" {
+		fmt.Fprintf(&g.buf, "%s//\n", indent)
+		for _, l := range strings.Split(desc, "\n") {
+			fmt.Fprintf(&g.buf, "%s// %s\n", indent, strings.TrimSpace(l))
+		}
+	}
+	if r, ok := schema.Deprecated(dirs); ok {
+		fmt.Fprintf(&g.buf, "%s//\n%s// Deprecated: %s\n", indent, indent, gen.OneLine(r))
+	}
+}
+
+// object writes the value type of t, the interface of its resolvers
+// and the structs their arguments are decoded into.
+func (g *generator) object(t *ast.TypeDefinition) error {
+	n := gen.Exported(string(t.Name))
+	for _, d := range []string{n, n + "Resolver"} {
+		if err := g.declare(d); err != nil {
+			return err
+		}
+	}
+	g.buf.WriteByte('\n')
+	g.comment("", fmt.Sprintf("%s is a value of the object type %s.", n, t.Name), t.Description, nil)
+	fmt.Fprintf(&g.buf, "type %s interface{}\n", n)
+
+	fmt.Fprintf(&g.buf, "\n// %sResolver resolves the fields of %s.\ntype %sResolver interface {\n", n, t.Name, n)
+	for _, f := range t.Fields {
+		g.comment("\t", fmt.Sprintf("%s resolves %s.%s.", gen.Exported(string(f.Name)), t.Name, f.Name), f.Description, f.Directives)
+		fmt.Fprintf(&g.buf, "\t%s(ctx context.Context, obj %s", gen.Exported(string(f.Name)), n)
+		if len(f.Arguments) > 0 {
+			fmt.Fprintf(&g.buf, ", args %s", argsType(t, f))
+		}
+		fmt.Fprintf(&g.buf, ") (%s, error)\n", g.goType(f.Type))
+	}
+	g.buf.WriteString("}\n")
+
+	for _, f := range t.Fields {
+		if len(f.Arguments) == 0 {
+			continue
+		}
+		at := argsType(t, f)
+		if err := g.declare(at); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.buf, "\n// %s are the arguments of %s.%s.\ntype %s struct {\n", at, t.Name, f.Name, at)
+		for _, a := range f.Arguments {
+			g.inputField(a.Name, a.Description, a.Type)
+		}
+		g.buf.WriteString("}\n")
+	}
+	return nil
+}
+
+func argsType(t *ast.TypeDefinition, f *ast.FieldDefinition) string {
+	return gen.Exported(string(t.Name)) + gen.Exported(string(f.Name)) + "Args"
+}
+
+// abstract writes the value type of an interface or union.
+func (g *generator) abstract(t *ast.TypeDefinition) error {
+	n := gen.Exported(string(t.Name))
+	if err := g.declare(n); err != nil {
+		return err
+	}
+	g.buf.WriteByte('\n')
+	g.comment("", fmt.Sprintf("%s is a value of the %s %s.", n, schema.Kind(t.Kind), t.Name), t.Description, nil)
+	fmt.Fprintf(&g.buf, "type %s interface{}\n", n)
+	return nil
+}
+
+func (g *generator) enum(t *ast.TypeDefinition) error {
+	n := gen.Exported(string(t.Name))
+	if err := g.declare(n); err != nil {
+		return err
+	}
+	g.buf.WriteByte('\n')
+	g.comment("", fmt.Sprintf("%s is the enum %s.", n, t.Name), t.Description, nil)
+	fmt.Fprintf(&g.buf, "type %s string\n\n// Values of %s.\nconst (\n", n, t.Name)
+	for _, v := range t.EnumValues {
+		c := n + gen.Exported(strings.ToLower(string(v.Name)))
+		if _, ok := schema.Deprecated(v.Directives); ok || v.Description != "" {
+			g.comment("\t", fmt.Sprintf("%s is %s.", c, v.Name), v.Description, v.Directives)
+		}
+		fmt.Fprintf(&g.buf, "\t%s %s = %q\n", c, n, v.Name)
+	}
+	g.buf.WriteString(")\n")
+	return nil
+}
+
+func (g *generator) input(t *ast.TypeDefinition) error {
+	n := gen.Exported(string(t.Name))
+	if err := g.declare(n); err != nil {
+		return err
+	}
+	g.buf.WriteByte('\n')
+	g.comment("", fmt.Sprintf("%s is the input object %s.", n, t.Name), t.Description, nil)
+	fmt.Fprintf(&g.buf, "type %s struct {\n", n)
+	for _, f := range t.Fields {
+		g.inputField(f.Name, f.Description, f.Type)
+	}
+	g.buf.WriteString("}\n")
+	return nil
+}
+
+// inputField writes a field of an arguments struct or input object.
+func (g *generator) inputField(n ast.GraphQLName, desc string, typ ast.Type) {
+	if desc != "" {
+		g.comment("\t", fmt.Sprintf("%s is %s.", gen.Exported(string(n)), n), desc, nil)
+	}
+	fmt.Fprintf(&g.buf, "\t%s %s `graphql:\"%s\"`\n", gen.Exported(string(n)), g.goType(typ), n)
+}
+
+// goType returns the Go type of values of typ. Nullable scalars,
+// enums and input objects are pointers, composite types are the
+// empty interfaces declared for them.
+func (g *generator) goType(typ ast.Type) string {
+	nullable := !typ.NonNull()
+	typ = typ.Nullable()
+	if typ.List() {
+		return "[]" + g.goType(typ.Elem())
+	}
+	def := g.s.Type(typ)
+	var gt string
+	switch def.Kind {
+	case ast.ObjectKind, ast.InterfaceKind, ast.UnionKind:
+		return gen.Exported(string(def.Name))
+	case ast.EnumKind, ast.InputObjectKind:
+		gt = gen.Exported(string(def.Name))
+	default:
+		switch def.Name {
+		case "Int":
+			gt = "int"
+		case "Float":
+			gt = "float64"
+		case "String", "ID":
+			gt = "string"
+		case "Boolean":
+			gt = "bool"
+		default:
+			// custom scalars are serialized by their implementations.
+			return "interface{}"
+		}
+	}
+	if nullable {
+		gt = "*" + gt
+	}
+	return gt
+}
+
+// register writes the Resolvers struct and the function that
+// registers them.
+func (g *generator) register(objects []*ast.TypeDefinition) error {
+	for _, n := range []string{"Resolvers", "Register"} {
+		if err := g.declare(n); err != nil {
+			return err
+		}
+	}
+	g.buf.WriteString("\n// Resolvers holds the resolvers of every object type. The fields of\n")
+	g.buf.WriteString("// object types whose resolver is nil are resolved by\n// executor.DefaultResolver.\n")
+	g.buf.WriteString("type Resolvers struct {\n")
+	for _, t := range objects {
+		n := gen.Exported(string(t.Name))
+		fmt.Fprintf(&g.buf, "\t%s %sResolver\n", n, n)
+	}
+	g.buf.WriteString("}\n")
+
+	g.buf.WriteString("\n// Register registers the resolvers in r with e.\nfunc Register(e *executor.Executor, r *Resolvers) {\n")
+	for _, t := range objects {
+		n := gen.Exported(string(t.Name))
+		fmt.Fprintf(&g.buf, "\tif r.%s != nil {\n", n)
+		for _, f := range t.Fields {
+			fmt.Fprintf(&g.buf, "\t\te.Resolve(%q, func(p executor.Params) (interface{}, error) {\n", string(t.Name)+"."+string(f.Name))
+			if len(f.Arguments) == 0 {
+				fmt.Fprintf(&g.buf, "\t\t\treturn r.%s.%s(p.Context, p.Source)\n", n, gen.Exported(string(f.Name)))
+			} else {
+				fmt.Fprintf(&g.buf, "\t\t\tvar args %s\n", argsType(t, f))
+				g.buf.WriteString("\t\t\tif err := bind.Decode(p.Args, &args); err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n")
+				fmt.Fprintf(&g.buf, "\t\t\treturn r.%s.%s(p.Context, p.Source, args)\n", n, gen.Exported(string(f.Name)))
+			}
+			g.buf.WriteString("\t\t})\n")
+		}
+		g.buf.WriteString("\t}\n")
+	}
+	g.buf.WriteString("}\n")
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main // import "sevki.org/graphql/cmd/gqlgen-server"
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"sevki.org/graphql/schema"
+)
+
+func TestGenerate(t *testing.T) {
+	s, err := load("../../tests/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := generate(s, "graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(b)
+	for _, want := range []string{
+		"type User interface{}",
+		"type UserResolver interface {",
+		"Friends(ctx context.Context, obj User, args UserFriendsArgs) ([]Friend, error)",
+		"Search(ctx context.Context, obj Query, args QuerySearchArgs) ([]SearchResult, error)",
+		"CreatedAt(ctx context.Context, obj User) (interface{}, error)",
+		"Likes(ctx context.Context, obj Story) (int, error)",
+		"First *int    `graphql:\"first\"`",
+		"Obj  *ComplexType `graphql:\"obj\"`",
+		"Tags []string `graphql:\"tags\"`",
+		"// Deprecated: Nobody uses this anymore.",
+		"SiteWap Site = \"WAP\"",
+		"type SearchResult interface{}",
+		"\tUser         UserResolver\n",
+		"e.Resolve(\"User.friends\", func(p executor.Params) (interface{}, error) {",
+		"if err := bind.Decode(p.Args, &args); err != nil {",
+		"return r.User.Name(p.Context, p.Source)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code doesn't contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateConflicts(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`type Query { a: Int } type QueryResolver { b: Int }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate(s, "graph"); err == nil || !strings.Contains(err.Error(), "more than one type is named QueryResolver") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	s, err := load("../../tests/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(s, "graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the packages the generated code imports are read from source.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("graph", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("generated code doesn't compile: %v\n%s", err, src)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command gqlgen-server generates the Go side of a schema written in
+the schema definition language, so the two can't drift apart.
+
+Usage:
+
+	gqlgen-server [-package name] [-o file] schema.graphql
+
+For every object type it generates an interface with a method per
+field,
+
+	type UserResolver interface {
+		Name(ctx context.Context, obj User) (*string, error)
+		Friends(ctx context.Context, obj User, args UserFriendsArgs) ([]Friend, error)
+	}
+
+with a struct for the arguments of every field that has some. Values
+of composite types are empty interfaces named after the types, the
+executor passes the value a resolver returns on as obj. Enums and input
+objects get Go types of their own, and
+
+	func Register(e *executor.Executor, r *Resolvers)
+
+registers the resolvers of every object type with an executor. Without
+-o the code is written to standard output.
+*/
+package main // import "sevki.org/graphql/cmd/gqlgen-server"
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"sevki.org/graphql/schema"
+)
+
+var (
+	pkg = flag.String("package", "graph", "the package of the generated code")
+	out = flag.String("o", "", "the file to write the generated code to")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gqlgen-server [-package name] [-o file] schema.graphql\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+	s, err := load(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	src, err := generate(s, *pkg)
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gqlgen-server: %v\n", err)
+	os.Exit(1)
+}
+
+func load(name string) (*schema.Schema, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := schema.Parse(name, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dataloader batches and caches the loads of resolvers, so
+// that sibling fields that need values from the same backend make one
+// call to it instead of one call each.
+//
+// Loaders cache every key they load and are meant to live as long as
+// a single request, the executor makes a new one for every request.
+package dataloader // import "sevki.org/graphql/dataloader"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchFunc loads the values of keys. It must return a value for
+// every key, in the order of keys, a value that is an error fails the
+// load of its key only. An error fails the loads of all keys.
+type BatchFunc func(ctx context.Context, keys []interface{}) ([]interface{}, error)
+
+// DefaultWait is the time loaders wait for more keys by default.
+const DefaultWait = time.Millisecond
+
+// Loader collects the keys loaded at about the same time into
+// batches.
+type Loader struct {
+	// MaxBatch is the largest number of keys in a batch, a batch is
+	// loaded as soon as it is full. Zero means no limit.
+	MaxBatch int
+	// Wait is how long the first load of a batch waits for more keys.
+	Wait time.Duration
+
+	fn    BatchFunc
+	mu    sync.Mutex
+	cache map[interface{}]*result
+	batch *batch
+}
+
+// result is the result of loading a key, it is ready once done is
+// closed.
+type result struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type batch struct {
+	ctx     context.Context
+	keys    []interface{}
+	results []*result
+	// full is closed when the batch can't take more keys.
+	full chan struct{}
+}
+
+// New returns a loader that loads batches with fn.
+func New(fn BatchFunc) *Loader {
+	return &Loader{
+		Wait:  DefaultWait,
+		fn:    fn,
+		cache: make(map[interface{}]*result),
+	}
+}
+
+type contextKey int
+
+const yieldKey contextKey = 0
+
+// WithYield returns a context that makes loads call yield while they
+// wait for their batch and the function yield returns once they are
+// done waiting. Executors use it to let other resolvers run, and load
+// more keys, while one waits.
+func WithYield(ctx context.Context, yield func() (resume func())) context.Context {
+	return context.WithValue(ctx, yieldKey, yield)
+}
+
+// wait calls the yield function of ctx, if it has one, and returns
+// the function to call when done waiting.
+func wait(ctx context.Context) func() {
+	if yield, ok := ctx.Value(yieldKey).(func() func()); ok {
+		return yield()
+	}
+	return func() {}
+}
+
+// Load returns the value of key, loading it with the other keys that
+// are loaded within the wait time. Keys must be comparable.
+//
+// The batch is loaded with the context of the first load in it.
+func (l *Loader) Load(ctx context.Context, key interface{}) (interface{}, error) {
+	r := l.load(ctx, key)
+	defer wait(ctx)()
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LoadMany loads keys, usually in one batch, and returns their
+// values and errors in the order of keys.
+func (l *Loader) LoadMany(ctx context.Context, keys []interface{}) ([]interface{}, []error) {
+	rs := make([]*result, len(keys))
+	for i, k := range keys {
+		rs[i] = l.load(ctx, k)
+	}
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	defer wait(ctx)()
+	for i, r := range rs {
+		select {
+		case <-r.done:
+			values[i], errs[i] = r.value, r.err
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+	return values, errs
+}
+
+// Prime adds the value of key to the cache, unless it has been
+// loaded already.
+func (l *Loader) Prime(key, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	r := &result{done: make(chan struct{}), value: value}
+	close(r.done)
+	l.cache[key] = r
+}
+
+// Clear removes key from the cache, the next load of it loads it
+// again.
+func (l *Loader) Clear(key interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, key)
+}
+
+func (l *Loader) load(ctx context.Context, key interface{}) *result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.cache[key]; ok {
+		return r
+	}
+	r := &result{done: make(chan struct{})}
+	l.cache[key] = r
+	b := l.batch
+	if b == nil {
+		b = &batch{ctx: ctx, full: make(chan struct{})}
+		l.batch = b
+		go l.dispatch(b)
+	}
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if l.MaxBatch > 0 && len(b.keys) >= l.MaxBatch {
+		l.batch = nil
+		close(b.full)
+	}
+	return r
+}
+
+// dispatch loads b once it is full or the wait time is over.
+func (l *Loader) dispatch(b *batch) {
+	t := time.NewTimer(l.Wait)
+	select {
+	case <-t.C:
+		l.mu.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		l.mu.Unlock()
+	case <-b.full:
+		t.Stop()
+	}
+
+	values, err := l.call(b)
+	if err == nil && len(values) != len(b.keys) {
+		err = fmt.Errorf("dataloader: batch function returned %d values for %d keys", len(values), len(b.keys))
+	}
+	for i, r := range b.results {
+		if err != nil {
+			r.err = err
+		} else if e, ok := values[i].(error); ok {
+			r.err = e
+		} else {
+			r.value = values[i]
+		}
+		close(r.done)
+	}
+}
+
+// call calls the batch function, one that panics fails the batch.
+func (l *Loader) call(b *batch) (values []interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			values, err = nil, fmt.Errorf("dataloader: batch function panicked: %v", p)
+		}
+	}()
+	return l.fn(b.ctx, b.keys)
+}
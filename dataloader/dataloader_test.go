@@ -0,0 +1,156 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dataloader // import "sevki.org/graphql/dataloader"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a batch function that doubles ints and remembers the
+// batches it got.
+type recorder struct {
+	mu      sync.Mutex
+	batches []string
+}
+
+func (r *recorder) load(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+	var ks []string
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		ks = append(ks, fmt.Sprint(k))
+		if k.(int) < 0 {
+			values[i] = errors.New("negative")
+			continue
+		}
+		values[i] = k.(int) * 2
+	}
+	sort.Strings(ks)
+	r.mu.Lock()
+	r.batches = append(r.batches, strings.Join(ks, ","))
+	r.mu.Unlock()
+	return values, nil
+}
+
+func (r *recorder) String() string {
+	sort.Strings(r.batches)
+	return strings.Join(r.batches, " ")
+}
+
+// loadAll loads keys at the same time.
+func loadAll(t *testing.T, l *Loader, keys ...int) {
+	var wg sync.WaitGroup
+	for _, k := range keys {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			v, err := l.Load(context.Background(), k)
+			switch {
+			case k < 0 && (err == nil || err.Error() != "negative"):
+				t.Errorf("loading %d: got error %v", k, err)
+			case k >= 0 && (err != nil || v != k*2):
+				t.Errorf("loading %d: got %v, %v", k, v, err)
+			}
+		}(k)
+	}
+	wg.Wait()
+}
+
+func TestBatch(t *testing.T) {
+	r := &recorder{}
+	l := New(r.load)
+	l.Wait = 10 * time.Millisecond
+	loadAll(t, l, 1, 2, 3, 2, -1)
+	loadAll(t, l, 3, 4, 1)
+	if got := r.String(); got != "-1,1,2,3 4" {
+		t.Errorf("got batches %q", got)
+	}
+}
+
+func TestMaxBatch(t *testing.T) {
+	r := &recorder{}
+	l := New(r.load)
+	l.Wait = time.Second
+	l.MaxBatch = 2
+	start := time.Now()
+	loadAll(t, l, 1, 2, 3, 4)
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("full batches waited for %s", d)
+	}
+	if n := len(r.batches); n != 2 {
+		t.Errorf("got %d batches, expected 2: %s", n, r)
+	}
+}
+
+func TestPrimeAndClear(t *testing.T) {
+	r := &recorder{}
+	l := New(r.load)
+	l.Prime(1, 2)
+	l.Prime(2, 4)
+	loadAll(t, l, 1, 2)
+	if len(r.batches) != 0 {
+		t.Errorf("primed keys were loaded: %s", r)
+	}
+	l.Clear(1)
+	vs, errs := l.LoadMany(context.Background(), []interface{}{1, 2, -3})
+	if vs[0] != 2 || vs[1] != 4 || errs[2] == nil {
+		t.Errorf("got %v, %v", vs, errs)
+	}
+	if got := r.String(); got != "-3,1" {
+		t.Errorf("got batches %q", got)
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	l := New(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		return nil, nil
+	})
+	if _, err := l.Load(context.Background(), 1); err == nil || !strings.Contains(err.Error(), "0 values for 1 keys") {
+		t.Errorf("got error %v", err)
+	}
+	l = New(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		return nil, errors.New("down")
+	})
+	if _, errs := l.LoadMany(context.Background(), []interface{}{1, 2}); errs[0] == nil || errs[1] == nil {
+		t.Errorf("got errors %v", errs)
+	}
+}
+
+func TestBatchPanics(t *testing.T) {
+	l := New(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		panic("boom")
+	})
+	_, errs := l.LoadMany(context.Background(), []interface{}{1, 2})
+	for _, err := range errs {
+		if err == nil || err.Error() != "dataloader: batch function panicked: boom" {
+			t.Errorf("got error %v", err)
+		}
+	}
+}
+
+func TestYield(t *testing.T) {
+	r := &recorder{}
+	l := New(r.load)
+	var yields, resumes int
+	ctx := WithYield(context.Background(), func() func() {
+		yields++
+		return func() { resumes++ }
+	})
+	if v, err := l.Load(ctx, 1); err != nil || v != 2 {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	if _, errs := l.LoadMany(ctx, []interface{}{2, 3}); errs[0] != nil || errs[1] != nil {
+		t.Fatalf("got errors %v", errs)
+	}
+	if yields != 2 || resumes != 2 {
+		t.Errorf("yielded %d times and resumed %d times, expected 2", yields, resumes)
+	}
+}
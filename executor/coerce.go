@@ -0,0 +1,538 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/schema"
+)
+
+// Input values are coerced to these Go types:
+//
+//	Int               int
+//	Float             float64
+//	String, ID        string
+//	Boolean           bool
+//	enums             string
+//	lists             []interface{}
+//	input objects     map[string]interface{}
+//	null              nil
+//	custom scalars    what their ParseValue or ParseLiteral returns,
+//	                  or the value as it was given if they have no
+//	                  implementation
+
+// CoerceVariables coerces the variables of a request, usually decoded
+// from JSON, to the types of the variable definitions of an operation
+// and applies their defaults, as defined in
+// http://facebook.github.io/graphql/#CoerceVariableValues()
+//
+// Variables that aren't provided and have no default are left out of
+// the result.
+func CoerceVariables(s *schema.Schema, defs ast.VariableDefinitions, vars map[string]interface{}) (map[string]interface{}, []*Error) {
+	coerced := make(map[string]interface{})
+	var errs []*Error
+	fail := func(v *ast.Variable, format string, args ...interface{}) {
+		errs = append(errs, &Error{
+			Message:   fmt.Sprintf(format, args...),
+			Locations: []ast.Position{v.Pos},
+		})
+	}
+	for _, v := range defs {
+		val, ok := vars[string(v.Name)]
+		switch {
+		case !ok && v.DefaultValue != nil:
+			d, err := CoerceLiteral(s, v.DefaultValue, v.Type, nil)
+			if err != nil {
+				fail(v, "Variable \"$%s\" has invalid default value %s; %s", v.Name, ast.FormatValue(v.DefaultValue), err)
+				continue
+			}
+			coerced[string(v.Name)] = d
+		case !ok && v.Type.NonNull():
+			fail(v, "Variable \"$%s\" of required type %q was not provided.", v.Name, v.Type)
+		case ok && val == nil && v.Type.NonNull():
+			fail(v, "Variable \"$%s\" of non-null type %q must not be null.", v.Name, v.Type)
+		case ok:
+			c, err := coerceValue(s, val, v.Type, string(v.Name))
+			if err, ok := err.(*pathError); ok {
+				at := ""
+				if err.path != string(v.Name) {
+					at = fmt.Sprintf(" at %q", err.path)
+				}
+				fail(v, "Variable \"$%s\" got invalid value %s%s; %s", v.Name, jsonString(err.value), at, err.msg)
+				continue
+			}
+			coerced[string(v.Name)] = c
+		}
+	}
+	return coerced, errs
+}
+
+// CoerceArguments builds the argument values of a field or a
+// directive out of the arguments in the document, variables must have
+// been coerced by CoerceVariables. As defined in
+// http://facebook.github.io/graphql/#CoerceArgumentValues()
+func CoerceArguments(s *schema.Schema, defs []*ast.InputValueDefinition, args ast.Arguments, vars map[string]interface{}) (map[string]interface{}, error) {
+	coerced := make(map[string]interface{})
+	for _, d := range defs {
+		v, ok := args.Get(string(d.Name))
+		if vv, isVar := v.(ast.VariableValue); ok && isVar {
+			_, ok = vars[string(vv.Name)]
+		}
+		switch {
+		case !ok && d.DefaultValue != nil:
+			c, err := CoerceLiteral(s, d.DefaultValue, d.Type, nil)
+			if err != nil {
+				return nil, err
+			}
+			coerced[string(d.Name)] = c
+		case !ok && d.Type.NonNull():
+			return nil, fmt.Errorf("Argument %q of required type %q was not provided.", d.Name, d.Type)
+		case ok:
+			c, err := CoerceLiteral(s, v, d.Type, vars)
+			if err != nil {
+				return nil, fmt.Errorf("Argument %q has invalid value %s; %s", d.Name, ast.FormatValue(v), err)
+			}
+			coerced[string(d.Name)] = c
+		}
+	}
+	return coerced, nil
+}
+
+// CoerceLiteral coerces a value written in a document to typ, as
+// defined in http://facebook.github.io/graphql/#sec-Input-Values
+//
+// Variables are looked up in vars, which must have been coerced by
+// CoerceVariables.
+func CoerceLiteral(s *schema.Schema, v ast.Value, typ ast.Type, vars map[string]interface{}) (interface{}, error) {
+	if vv, ok := v.(ast.VariableValue); ok {
+		val, ok := vars[string(vv.Name)]
+		if (!ok || val == nil) && typ.NonNull() {
+			return nil, fmt.Errorf("Variable \"$%s\" of type %q must not be null.", vv.Name, typ)
+		}
+		return val, nil
+	}
+	if _, ok := v.(ast.NullValue); ok {
+		if typ.NonNull() {
+			return nil, fmt.Errorf("Expected value of non-null type %q not to be null.", typ)
+		}
+		return nil, nil
+	}
+	typ = typ.Nullable()
+	if typ.List() {
+		items, ok := v.(ast.ArrayValue)
+		if !ok {
+			// a single value is coerced to a list of one.
+			c, err := CoerceLiteral(s, v, typ.Elem(), vars)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{c}, nil
+		}
+		l := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := CoerceLiteral(s, item, typ.Elem(), vars)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = c
+		}
+		return l, nil
+	}
+
+	t := s.Type(typ)
+	if t == nil {
+		return nil, fmt.Errorf("Unknown type %q.", typ)
+	}
+	switch t.Kind {
+	case ast.InputObjectKind:
+		obj, ok := v.(ast.ObjectValue)
+		if !ok {
+			return nil, fmt.Errorf("Expected type %q to be an object.", t.Name)
+		}
+		for _, f := range obj {
+			if t.Field(f.Name) == nil {
+				return nil, fmt.Errorf("Field %q is not defined by type %q.", f.Name, t.Name)
+			}
+		}
+		m := make(map[string]interface{})
+		for _, fd := range t.Fields {
+			fv, ok := obj.Get(string(fd.Name))
+			if vv, isVar := fv.(ast.VariableValue); ok && isVar {
+				_, ok = vars[string(vv.Name)]
+			}
+			switch {
+			case !ok && fd.DefaultValue != nil:
+				c, err := CoerceLiteral(s, fd.DefaultValue, fd.Type, nil)
+				if err != nil {
+					return nil, err
+				}
+				m[string(fd.Name)] = c
+			case !ok && fd.Type.NonNull():
+				return nil, fmt.Errorf("Field \"%s.%s\" of required type %q was not provided.", t.Name, fd.Name, fd.Type)
+			case ok:
+				c, err := CoerceLiteral(s, fv, fd.Type, vars)
+				if err != nil {
+					return nil, err
+				}
+				m[string(fd.Name)] = c
+			}
+		}
+		return m, nil
+	case ast.EnumKind:
+		e, ok := v.(ast.EnumValue)
+		if !ok || t.EnumValue(ast.GraphQLName(e)) == nil {
+			return nil, fmt.Errorf("Value %s does not exist in %q enum.", ast.FormatValue(v), t.Name)
+		}
+		return string(e), nil
+	}
+	if sc, ok := s.Scalars[t.Name]; ok {
+		return sc.ParseLiteral(substitute(v, vars))
+	}
+	switch v := v.(type) {
+	case ast.IntValue:
+		switch t.Name {
+		case "Int":
+			if i, ok := v.Int32(); ok {
+				return int(i), nil
+			}
+			return nil, fmt.Errorf("Int cannot represent non 32-bit signed integer value: %s", v)
+		case "Float":
+			return v.Float64(), nil
+		case "ID":
+			return v.Int().String(), nil
+		}
+	case ast.GraphQLInt:
+		switch t.Name {
+		case "Int":
+			return int(v), nil
+		case "Float":
+			return float64(v), nil
+		case "ID":
+			return strconv.Itoa(int(v)), nil
+		}
+	case ast.GraphQLFloat:
+		if t.Name == "Float" {
+			return float64(v), nil
+		}
+	case ast.GraphQLString:
+		if t.Name == "String" || t.Name == "ID" {
+			return string(v), nil
+		}
+	case ast.GraphQLBoolean:
+		if t.Name == "Boolean" {
+			return bool(v), nil
+		}
+	}
+	if !schema.IsBuiltinScalar(t.Name) {
+		return literal(v, vars), nil
+	}
+	return nil, fmt.Errorf("%s cannot represent value: %s", t.Name, ast.FormatValue(v))
+}
+
+// literal turns a value written in a document into a Go value,
+// without looking at its type. Variables are looked up in vars.
+func literal(v ast.Value, vars map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case ast.VariableValue:
+		return vars[string(v.Name)]
+	case ast.IntValue:
+		if i, ok := v.Int32(); ok {
+			return int(i)
+		}
+		return json.Number(v.Int().String())
+	case ast.GraphQLInt:
+		return int(v)
+	case ast.GraphQLFloat:
+		return float64(v)
+	case ast.GraphQLString:
+		return string(v)
+	case ast.GraphQLBoolean:
+		return bool(v)
+	case ast.EnumValue:
+		return string(v)
+	case ast.ArrayValue:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = literal(e, vars)
+		}
+		return l
+	case ast.ObjectValue:
+		m := make(map[string]interface{}, len(v))
+		for _, f := range v {
+			if vv, ok := f.Value.(ast.VariableValue); ok {
+				// fields set to missing variables are left out.
+				if _, ok := vars[string(vv.Name)]; !ok {
+					continue
+				}
+			}
+			m[string(f.Name)] = literal(f.Value, vars)
+		}
+		return m
+	}
+	return nil
+}
+
+// substitute returns v with the variables nested in it replaced by
+// their values written as literals, so custom scalars don't have to
+// look them up. Object fields set to missing variables are left out.
+func substitute(v ast.Value, vars map[string]interface{}) ast.Value {
+	switch v := v.(type) {
+	case ast.VariableValue:
+		return value(vars[string(v.Name)])
+	case ast.ArrayValue:
+		l := make(ast.ArrayValue, len(v))
+		for i, e := range v {
+			l[i] = substitute(e, vars)
+		}
+		return l
+	case ast.ObjectValue:
+		var o ast.ObjectValue
+		for _, f := range v {
+			if vv, ok := f.Value.(ast.VariableValue); ok {
+				if _, ok := vars[string(vv.Name)]; !ok {
+					continue
+				}
+			}
+			o = append(o, &ast.ObjectField{Name: f.Name, Value: substitute(f.Value, vars), Pos: f.Pos})
+		}
+		return o
+	}
+	return v
+}
+
+// value writes the Go value of a variable as a literal. Values that
+// aren't made of JSON types, e.g. those of custom scalars, are
+// written the way they are encoded in JSON.
+func value(v interface{}) ast.Value {
+	switch v := v.(type) {
+	case nil:
+		return ast.NullValue{}
+	case bool:
+		return ast.GraphQLBoolean(v)
+	case string:
+		return ast.GraphQLString(v)
+	case int:
+		return ast.IntValue(strconv.Itoa(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return ast.IntValue(strconv.FormatInt(int64(v), 10))
+		}
+		return ast.GraphQLFloat(v)
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return ast.IntValue(v)
+		}
+		f, _ := v.Float64()
+		return ast.GraphQLFloat(f)
+	case []interface{}:
+		l := make(ast.ArrayValue, len(v))
+		for i, e := range v {
+			l[i] = value(e)
+		}
+		return l
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		o := make(ast.ObjectValue, len(keys))
+		for i, k := range keys {
+			o[i] = &ast.ObjectField{Name: ast.GraphQLName(k), Value: value(v[k])}
+		}
+		return o
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ast.NullValue{}
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var j interface{}
+	if err := d.Decode(&j); err != nil {
+		return ast.NullValue{}
+	}
+	return value(j)
+}
+
+// pathError is an error in a value nested in a variable, path is
+// written like "input.list[1].field".
+type pathError struct {
+	path  string
+	value interface{}
+	msg   string
+}
+
+func (e *pathError) Error() string {
+	return fmt.Sprintf("invalid value %s at %q; %s", jsonString(e.value), e.path, e.msg)
+}
+
+// coerceValue coerces a variable value to typ, as defined in
+// http://facebook.github.io/graphql/#sec-Input-Values
+func coerceValue(s *schema.Schema, v interface{}, typ ast.Type, path string) (interface{}, error) {
+	fail := func(format string, args ...interface{}) (interface{}, error) {
+		return nil, &pathError{path, v, fmt.Sprintf(format, args...)}
+	}
+	if isNil(v) {
+		if typ.NonNull() {
+			return fail("Expected non-nullable type %q not to be null.", typ)
+		}
+		return nil, nil
+	}
+	typ = typ.Nullable()
+	if typ.List() {
+		items, ok := list(v)
+		if !ok {
+			c, err := coerceValue(s, v, typ.Elem(), path)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{c}, nil
+		}
+		l := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerceValue(s, item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = c
+		}
+		return l, nil
+	}
+
+	t := s.Type(typ)
+	if t == nil {
+		return fail("Unknown type %q.", typ)
+	}
+	switch t.Kind {
+	case ast.InputObjectKind:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("Expected type %q to be an object.", t.Name)
+		}
+		m := make(map[string]interface{})
+		for _, fd := range t.Fields {
+			fpath := path + "." + string(fd.Name)
+			fv, ok := obj[string(fd.Name)]
+			switch {
+			case !ok && fd.DefaultValue != nil:
+				c, err := CoerceLiteral(s, fd.DefaultValue, fd.Type, nil)
+				if err != nil {
+					return nil, &pathError{fpath, fd.DefaultValue, err.Error()}
+				}
+				m[string(fd.Name)] = c
+			case !ok && fd.Type.NonNull():
+				return fail("Field %q of required type %q was not provided.", fd.Name, fd.Type)
+			case ok:
+				c, err := coerceValue(s, fv, fd.Type, fpath)
+				if err != nil {
+					return nil, err
+				}
+				m[string(fd.Name)] = c
+			}
+		}
+		for k := range obj {
+			if t.Field(ast.GraphQLName(k)) == nil {
+				return fail("Field %q is not defined by type %q.", k, t.Name)
+			}
+		}
+		return m, nil
+	case ast.EnumKind:
+		e, ok := v.(string)
+		if !ok || t.EnumValue(ast.GraphQLName(e)) == nil {
+			return fail("Value %s does not exist in %q enum.", jsonString(v), t.Name)
+		}
+		return e, nil
+	}
+
+	if sc, ok := s.Scalars[t.Name]; ok {
+		c, err := sc.ParseValue(v)
+		if err != nil {
+			return fail("%s", err)
+		}
+		return c, nil
+	}
+	rv := reflect.ValueOf(v)
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			rv = reflect.ValueOf(i)
+		} else if f, err := n.Float64(); err == nil {
+			rv = reflect.ValueOf(f)
+		}
+	}
+	switch t.Name {
+	case "Int":
+		var f float64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		default:
+			return fail("Int cannot represent non-integer value: %s", jsonString(v))
+		}
+		if f != math.Trunc(f) {
+			return fail("Int cannot represent non-integer value: %s", jsonString(v))
+		}
+		if f < math.MinInt32 || f > math.MaxInt32 {
+			return fail("Int cannot represent non 32-bit signed integer value: %s", jsonString(v))
+		}
+		return int(f), nil
+	case "Float":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		}
+		return fail("Float cannot represent non numeric value: %s", jsonString(v))
+	case "String":
+		if rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+		return fail("String cannot represent a non string value: %s", jsonString(v))
+	case "Boolean":
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+		return fail("Boolean cannot represent a non boolean value: %s", jsonString(v))
+	case "ID":
+		switch rv.Kind() {
+		case reflect.String:
+			return rv.String(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return strconv.FormatUint(rv.Uint(), 10), nil
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+				return strconv.FormatInt(int64(f), 10), nil
+			}
+		}
+		return fail("ID cannot represent value: %s", jsonString(v))
+	}
+	return v, nil
+}
+
+// jsonString formats v for error messages.
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/schema"
+)
+
+// FieldGroup is a list of fields that share a response key, they are
+// executed as one field.
+type FieldGroup struct {
+	Key    string
+	Fields []*ast.Field
+}
+
+// Fragments maps the names of the fragment definitions in doc to
+// them.
+func Fragments(doc *ast.Document) map[ast.GraphQLName]*ast.Fragment {
+	frags := make(map[ast.GraphQLName]*ast.Fragment)
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.Fragment); ok {
+			frags[f.FragmentName] = f
+		}
+	}
+	return frags
+}
+
+// CollectFields groups the fields selected by set on objects of type t
+// by response key, in the order they are first selected, as defined
+// in http://facebook.github.io/graphql/#CollectFields()
+//
+// Fragment spreads are expanded and fragments whose type condition
+// doesn't apply to t are left out, as are selections skipped by @skip
+// or @include. Variables used by those must have been coerced by
+// CoerceVariables.
+func CollectFields(s *schema.Schema, t *ast.TypeDefinition, set ast.SelectionSet, fragments map[ast.GraphQLName]*ast.Fragment, variables map[string]interface{}) []*FieldGroup {
+	return collectFields(s, t, set, fragments, variables, nil)
+}
+
+// deferredSet is a fragment, or a field, deferred with @defer.
+type deferredSet struct {
+	label string
+	set   ast.SelectionSet
+}
+
+// collectFields is CollectFields, if deferred isn't nil the fragments
+// and fields deferred with @defer are added to it instead of being
+// collected.
+func collectFields(s *schema.Schema, t *ast.TypeDefinition, set ast.SelectionSet, fragments map[ast.GraphQLName]*ast.Fragment, variables map[string]interface{}, deferred *[]*deferredSet) []*FieldGroup {
+	var groups []*FieldGroup
+	byKey := make(map[string]*FieldGroup)
+	visited := make(map[ast.GraphQLName]bool)
+	var collect func(set ast.SelectionSet)
+	collect = func(set ast.SelectionSet) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				if !included(s, sel.Directives, variables) {
+					continue
+				}
+				if label, ok := deferDirective(s, sel.Directives, variables); ok && deferred != nil {
+					// the field is deferred like a fragment holding it.
+					f := *sel
+					f.Directives = without(sel.Directives, "defer")
+					*deferred = append(*deferred, &deferredSet{label, ast.SelectionSet{&f}})
+					continue
+				}
+				k := string(sel.Name)
+				if sel.Alias != "" {
+					k = string(sel.Alias)
+				}
+				g, ok := byKey[k]
+				if !ok {
+					g = &FieldGroup{Key: k}
+					byKey[k] = g
+					groups = append(groups, g)
+				}
+				g.Fields = append(g.Fields, sel)
+			case *ast.Fragment:
+				if !included(s, sel.Directives, variables) {
+					continue
+				}
+				fset := sel.SelectionSet
+				if sel.TypeCondition == "" && sel.FragmentName != "" {
+					if visited[sel.FragmentName] {
+						continue
+					}
+					visited[sel.FragmentName] = true
+					frag, ok := fragments[sel.FragmentName]
+					if !ok || !applies(s, t, frag.TypeCondition) {
+						continue
+					}
+					fset = frag.SelectionSet
+				} else if sel.TypeCondition != "" && !applies(s, t, sel.TypeCondition) {
+					continue
+				}
+				if label, ok := deferDirective(s, sel.Directives, variables); ok && deferred != nil {
+					*deferred = append(*deferred, &deferredSet{label, fset})
+					continue
+				}
+				collect(fset)
+			}
+		}
+	}
+	collect(set)
+	return groups
+}
+
+// included evaluates @skip and @include, as defined in
+// http://facebook.github.io/graphql/#sec--skip and
+// http://facebook.github.io/graphql/#sec--include
+func included(s *schema.Schema, dirs ast.Directives, variables map[string]interface{}) bool {
+	if d := dirs.Get("skip"); d != nil && condition(s, d, variables) {
+		return false
+	}
+	if d := dirs.Get("include"); d != nil && !condition(s, d, variables) {
+		return false
+	}
+	return true
+}
+
+// condition returns the if argument of @skip or @include.
+func condition(s *schema.Schema, d *ast.Directive, variables map[string]interface{}) bool {
+	b, _ := directiveArgs(s, d, variables)["if"].(bool)
+	return b
+}
+
+// deferDirective reports whether dirs has @defer with its if argument
+// set and returns its label, as defined in
+// https://github.com/graphql/graphql-spec/blob/main/rfcs/DeferStream.md
+func deferDirective(s *schema.Schema, dirs ast.Directives, variables map[string]interface{}) (string, bool) {
+	d := dirs.Get("defer")
+	if d == nil {
+		return "", false
+	}
+	args := directiveArgs(s, d, variables)
+	if b, _ := args["if"].(bool); !b {
+		return "", false
+	}
+	label, _ := args["label"].(string)
+	return label, true
+}
+
+// directiveArgs returns the coerced arguments of d, nil if they don't
+// coerce.
+func directiveArgs(s *schema.Schema, d *ast.Directive, variables map[string]interface{}) map[string]interface{} {
+	def, ok := s.Directives[d.Name]
+	if !ok {
+		return nil
+	}
+	args, err := CoerceArguments(s, def.Arguments, d.Arguments, variables)
+	if err != nil {
+		return nil
+	}
+	return args
+}
+
+// without returns dirs without the directive named n.
+func without(dirs ast.Directives, n ast.GraphQLName) ast.Directives {
+	var r ast.Directives
+	for _, d := range dirs {
+		if d.Name != n {
+			r = append(r, d)
+		}
+	}
+	return r
+}
+
+// applies reports whether a fragment on the type named cond applies
+// to objects of type t.
+func applies(s *schema.Schema, t *ast.TypeDefinition, cond ast.GraphQLName) bool {
+	if t.Name == cond {
+		return true
+	}
+	c, ok := s.Types[cond]
+	return ok && s.IsPossibleType(c, t)
+}
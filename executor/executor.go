@@ -0,0 +1,508 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package executor runs GraphQL requests against a schema, as defined
+// in http://facebook.github.io/graphql/#sec-Execution
+//
+// Fields are resolved by functions registered per "Type.field", fields
+// without one are read from the value of their parent.
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/dataloader"
+	"sevki.org/graphql/schema"
+	"sevki.org/graphql/validation"
+)
+
+// Params are passed to resolvers.
+type Params struct {
+	// Context is the context of the request, resolvers should give
+	// up when it is done.
+	Context context.Context
+	// Source is the value of the parent field, or the root value for
+	// root fields.
+	Source interface{}
+	// Args are the arguments of the field, with defaults applied.
+	Args map[string]interface{}
+	// Field is the field being resolved, the first one if fields
+	// with the same response key were merged.
+	Field *ast.Field
+	// Parent is the object type the field belongs to.
+	Parent *ast.TypeDefinition
+	// Definition is the definition of the field in the schema.
+	Definition *ast.FieldDefinition
+	// Root is the root value of the request.
+	Root interface{}
+	// Variables are the coerced variables of the request.
+	Variables map[string]interface{}
+
+	ex *execution
+}
+
+// Loader returns the request's loader registered as name with
+// Executor.Loader, or nil if there is none.
+func (p Params) Loader(name string) *dataloader.Loader {
+	if p.ex == nil {
+		return nil
+	}
+	return p.ex.loader(name)
+}
+
+// ResolveFunc resolves the value of a field.
+type ResolveFunc func(p Params) (interface{}, error)
+
+// Executor executes requests against a schema.
+type Executor struct {
+	Schema *schema.Schema
+	// Workers is the number of resolvers that may run at once. The
+	// fields of queries and subscriptions, and the items of lists,
+	// are resolved in parallel when it is above zero, otherwise they
+	// are resolved one after another, unless loaders are registered.
+	// The root fields of mutations are always resolved in order.
+	//
+	// Resolvers waiting for a loader don't count, so that the others
+	// can add their keys to the batch.
+	Workers       int
+	resolvers     map[string]ResolveFunc
+	typeResolvers map[ast.GraphQLName]ResolveTypeFunc
+	isTypeOf      map[ast.GraphQLName]IsTypeOfFunc
+	loaders       map[string]func() *dataloader.Loader
+}
+
+// New returns an executor for s with no resolvers.
+func New(s *schema.Schema) *Executor {
+	return &Executor{
+		Schema:        s,
+		resolvers:     make(map[string]ResolveFunc),
+		typeResolvers: make(map[ast.GraphQLName]ResolveTypeFunc),
+		isTypeOf:      make(map[ast.GraphQLName]IsTypeOfFunc),
+		loaders:       make(map[string]func() *dataloader.Loader),
+	}
+}
+
+// Resolve registers fn as the resolver of field, which is written
+// "Type.field", e.g. "User.friends". It panics if the field isn't
+// defined by the schema.
+func (e *Executor) Resolve(field string, fn ResolveFunc) {
+	i := strings.Index(field, ".")
+	if i < 0 {
+		panic(fmt.Sprintf("executor: %q is not of the form Type.field", field))
+	}
+	t, ok := e.Schema.Types[ast.GraphQLName(field[:i])]
+	if !ok || t.Field(ast.GraphQLName(field[i+1:])) == nil {
+		panic(fmt.Sprintf("executor: %s is not defined by the schema", field))
+	}
+	e.resolvers[field] = fn
+}
+
+// Loader registers a loader, newLoader is called once per request
+// the first time a resolver asks for it with Params.Loader. Keys can
+// only be batched if fields are started together, so once a loader
+// is registered they are even if Workers is zero, with one resolver
+// running at a time.
+func (e *Executor) Loader(name string, newLoader func() *dataloader.Loader) {
+	e.loaders[name] = newLoader
+}
+
+// Execute validates doc and runs the operation named operationName,
+// which may be empty if doc has only one operation, with root as the
+// value of the root type.
+func (e *Executor) Execute(doc *ast.Document, operationName string, variables map[string]interface{}, root interface{}) *Result {
+	return e.ExecuteContext(context.Background(), doc, operationName, variables, root)
+}
+
+// ExecuteContext is like Execute, ctx is passed to the resolvers and
+// fields that haven't been resolved when it is done fail with its
+// error.
+func (e *Executor) ExecuteContext(ctx context.Context, doc *ast.Document, operationName string, variables map[string]interface{}, root interface{}) *Result {
+	op, ex, res := e.prepare(ctx, doc, operationName, variables, root)
+	if res != nil {
+		return res
+	}
+	t := e.Schema.Root(op.OperationType)
+	// http://facebook.github.io/graphql/#sec-Mutation
+	data, ok := ex.selectionSet(t, op.SelectionSet, root, nil, op.OperationType == ast.Mutation)
+	if !ok {
+		return &Result{Data: nil, Errors: ex.errors, executed: true}
+	}
+	return &Result{Data: data, Errors: ex.errors, executed: true}
+}
+
+// prepare validates doc and coerces the variables of the operation to
+// execute, if the request fails before execution it returns a result
+// with the errors.
+func (e *Executor) prepare(ctx context.Context, doc *ast.Document, operationName string, variables map[string]interface{}, root interface{}) (*ast.Operation, *execution, *Result) {
+	if errs := validation.ValidateWithSchema(e.Schema, doc); len(errs) > 0 {
+		res := &Result{}
+		for _, err := range errs {
+			res.Errors = append(res.Errors, &Error{Message: err.Message, Locations: err.Locations})
+		}
+		return nil, nil, res
+	}
+	op, err := Operation(doc, operationName)
+	if err != nil {
+		return nil, nil, &Result{Errors: []*Error{err}}
+	}
+	variables, errs := CoerceVariables(e.Schema, op.VariableDefinitions, variables)
+	if len(errs) > 0 {
+		return nil, nil, &Result{Errors: errs}
+	}
+	ex := &execution{
+		Executor:  e,
+		ctx:       ctx,
+		fragments: Fragments(doc),
+		variables: variables,
+		root:      root,
+	}
+	switch {
+	case e.Workers > 0:
+		ex.workers = make(chan struct{}, e.Workers)
+	case len(e.loaders) > 0:
+		ex.workers = make(chan struct{}, 1)
+	}
+	return op, ex, nil
+}
+
+// Operation finds the operation to execute as defined in
+// http://facebook.github.io/graphql/#GetOperation()
+func Operation(doc *ast.Document, name string) (*ast.Operation, *Error) {
+	var found *ast.Operation
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.Operation)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "" && found != nil:
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		case name == "" || string(op.Name) == name:
+			found = op
+		}
+	}
+	if found == nil {
+		if name == "" {
+			return nil, &Error{Message: "Must provide an operation."}
+		}
+		return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+	}
+	return found, nil
+}
+
+// execution is the state of a single request.
+type execution struct {
+	*Executor
+	ctx       context.Context
+	fragments map[ast.GraphQLName]*ast.Fragment
+	variables map[string]interface{}
+	root      interface{}
+	// workers holds a token for every resolver that is running, it
+	// is nil when fields are resolved one after another.
+	workers chan struct{}
+
+	// incremental is set if fragments and fields deferred with @defer
+	// and the items of lists streamed with @stream are delivered
+	// later, otherwise they are executed like the rest.
+	incremental bool
+
+	mu      sync.Mutex
+	errors  []*Error
+	loaders map[string]*dataloader.Loader
+	// pending are the deferred fragments and streamed items that
+	// haven't been executed yet, scope is the payload being executed.
+	pending []*deferred
+	scope   *scope
+}
+
+func (ex *execution) loader(name string) *dataloader.Loader {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if l, ok := ex.loaders[name]; ok {
+		return l
+	}
+	newLoader, ok := ex.Executor.loaders[name]
+	if !ok {
+		return nil
+	}
+	if ex.loaders == nil {
+		ex.loaders = make(map[string]*dataloader.Loader)
+	}
+	l := newLoader()
+	ex.loaders[name] = l
+	return l
+}
+
+// path is the path to a value in the response, keys are response
+// keys of fields and indices of list items.
+type path struct {
+	parent *path
+	key    interface{}
+}
+
+func (p *path) slice() []interface{} {
+	var keys []interface{}
+	for ; p != nil; p = p.parent {
+		keys = append([]interface{}{p.key}, keys...)
+	}
+	return keys
+}
+
+func (ex *execution) errorf(f *ast.Field, at *path, format string, args ...interface{}) {
+	ex.error(f, at, &Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// error records err, raised by field f at the given path.
+func (ex *execution) error(f *ast.Field, at *path, err error) {
+	e := &Error{Message: err.Error()}
+	switch err := err.(type) {
+	case *Error:
+		e.Message = err.Message
+		e.Extensions = err.Extensions
+	case ExtendedError:
+		e.Extensions = err.Extensions()
+	}
+	e.Locations = []ast.Position{f.Pos}
+	e.Path = at.slice()
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.errors = append(ex.errors, e)
+}
+
+// parallel calls fn for 0..n-1, at the same time if fields are
+// resolved in parallel and serial is false.
+func (ex *execution) parallel(n int, serial bool, fn func(i int)) {
+	if ex.workers == nil || serial || n < 2 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// selectionSet executes the fields of set on the object type t, as
+// defined in
+// http://facebook.github.io/graphql/#ExecuteSelectionSet()
+//
+// Like the other methods that complete values, it returns false if
+// the object is null because a non-null field in it failed.
+func (ex *execution) selectionSet(t *ast.TypeDefinition, set ast.SelectionSet, source interface{}, at *path, serial bool) (Object, bool) {
+	var later []*deferredSet
+	var fields []*FieldGroup
+	if ex.incremental {
+		fields = collectFields(ex.Schema, t, set, ex.fragments, ex.variables, &later)
+	} else {
+		fields = CollectFields(ex.Schema, t, set, ex.fragments, ex.variables)
+	}
+	obj := make(Object, len(fields))
+	failed := make([]bool, len(fields))
+	ex.parallel(len(fields), serial, func(i int) {
+		at := &path{at, fields[i].Key}
+		v, ok := ex.field(t, fields[i].Fields, source, at)
+		if v == nil {
+			ex.null(at)
+		}
+		obj[i] = &ObjectField{Name: fields[i].Key, Value: v}
+		failed[i] = !ok
+	})
+	for _, f := range failed {
+		if f {
+			return nil, false
+		}
+	}
+	for _, d := range later {
+		ex.enqueue(&deferred{label: d.label, at: at, t: t, set: d.set, source: source})
+	}
+	return obj, true
+}
+
+// field resolves and completes a field, as defined in
+// http://facebook.github.io/graphql/#ExecuteField()
+//
+// Errors make the field null, if its type is non-null its parent is
+// made null instead, as defined in
+// http://facebook.github.io/graphql/#sec-Errors-and-Non-Nullability
+func (ex *execution) field(t *ast.TypeDefinition, fields []*ast.Field, source interface{}, at *path) (interface{}, bool) {
+	f := fields[0]
+	if f.Name == "__typename" {
+		return string(t.Name), true
+	}
+	def := t.Field(f.Name)
+	args, err := CoerceArguments(ex.Schema, def.Arguments, f.Arguments, ex.variables)
+	if err != nil {
+		ex.error(f, at, err)
+		return nil, !def.Type.NonNull()
+	}
+	p := Params{
+		Context:    ex.ctx,
+		Source:     source,
+		Args:       args,
+		Field:      f,
+		Parent:     t,
+		Definition: def,
+		Root:       ex.root,
+		Variables:  ex.variables,
+		ex:         ex,
+	}
+	resolve, ok := ex.resolvers[string(t.Name)+"."+string(f.Name)]
+	if !ok {
+		resolve = DefaultResolver
+	}
+	v, err := ex.call(resolve, p)
+	if err != nil {
+		ex.error(f, at, err)
+		return nil, !def.Type.NonNull()
+	}
+	r, ok := ex.complete(t, def.Type, fields, v, at)
+	if !ok {
+		return nil, !def.Type.NonNull()
+	}
+	return r, true
+}
+
+// call runs a resolver once a worker is free, unless the request is
+// done by then. A resolver that panics fails its field.
+func (ex *execution) call(resolve ResolveFunc, p Params) (v interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("Resolver for %s.%s panicked: %v", p.Parent.Name, p.Field.Name, r)
+		}
+	}()
+	if err := ex.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ex.workers != nil {
+		select {
+		case ex.workers <- struct{}{}:
+			defer func() { <-ex.workers }()
+		case <-ex.ctx.Done():
+			return nil, ex.ctx.Err()
+		}
+		// resolvers give up their worker while they wait for a loader.
+		p.Context = dataloader.WithYield(p.Context, ex.yield())
+	}
+	return resolve(p)
+}
+
+// yield returns the yield function of a resolver holding a worker, its
+// worker is given up while at least one of its loads waits.
+func (ex *execution) yield() func() func() {
+	var mu sync.Mutex
+	waiting := 0
+	return func() func() {
+		mu.Lock()
+		if waiting++; waiting == 1 {
+			<-ex.workers
+		}
+		mu.Unlock()
+		return func() {
+			mu.Lock()
+			if waiting--; waiting == 0 {
+				ex.workers <- struct{}{}
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+// complete turns the value a resolver returned into a result, as
+// defined in
+// http://facebook.github.io/graphql/#CompleteValue()
+func (ex *execution) complete(parent *ast.TypeDefinition, typ ast.Type, fields []*ast.Field, v interface{}, at *path) (interface{}, bool) {
+	f := fields[0]
+	if typ.NonNull() {
+		r, ok := ex.complete(parent, typ.Nullable(), fields, v, at)
+		if ok && r == nil {
+			ex.errorf(f, at, "Cannot return null for non-nullable field %s.%s.", parent.Name, f.Name)
+			return nil, false
+		}
+		return r, ok
+	}
+	if isNil(v) {
+		return nil, true
+	}
+	if typ.List() {
+		items, ok := list(v)
+		if !ok {
+			ex.errorf(f, at, "Expected a list for field %s.%s, got %T.", parent.Name, f.Name, v)
+			return nil, false
+		}
+		elem := typ.Elem()
+		if ex.incremental {
+			// only the list the field resolved to is streamed, not the
+			// lists in it.
+			if _, ok := at.key.(string); ok {
+				label, n, ok, err := ex.stream(fields[0])
+				if err != nil {
+					ex.error(f, at, err)
+					return nil, false
+				}
+				if ok && n < len(items) {
+					for i, item := range items[n:] {
+						ex.enqueue(&deferred{label: label, at: &path{at, n + i}, parent: parent, elem: elem, fields: fields, item: item})
+					}
+					items = items[:n]
+				}
+			}
+		}
+		res := make([]interface{}, len(items))
+		failed := make([]bool, len(items))
+		ex.parallel(len(items), false, func(i int) {
+			at := &path{at, i}
+			r, ok := ex.complete(parent, elem, fields, items[i], at)
+			if r == nil {
+				ex.null(at)
+			}
+			res[i] = r
+			// null items are fine in lists of nullable items.
+			failed[i] = !ok && elem.NonNull()
+		})
+		for _, f := range failed {
+			if f {
+				return nil, false
+			}
+		}
+		return res, true
+	}
+	t := ex.Schema.Type(typ)
+	switch t.Kind {
+	case ast.ScalarKind, ast.EnumKind:
+		r, err := ex.serialize(t, v)
+		if err != nil {
+			ex.error(f, at, err)
+			return nil, false
+		}
+		return r, true
+	case ast.InterfaceKind, ast.UnionKind:
+		o, err := ex.objectType(t, v)
+		if err != nil {
+			ex.error(f, at, err)
+			return nil, false
+		}
+		t = o
+	case ast.ObjectKind:
+		if err := ex.checkType(t, v); err != nil {
+			ex.error(f, at, err)
+			return nil, false
+		}
+	}
+	var set ast.SelectionSet
+	for _, f := range fields {
+		set = append(set, f.SelectionSet...)
+	}
+	return ex.selectionSet(t, set, v, at, false)
+}
@@ -0,0 +1,1104 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/dataloader"
+	"sevki.org/graphql/parser"
+	"sevki.org/graphql/schema"
+)
+
+type user struct {
+	ID      string
+	Name    string `graphql:"name"`
+	Friends []*friend
+}
+
+type friend struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (f *friend) Foo() (string, error) {
+	if f.Name == "" {
+		return "", errors.New("nameless")
+	}
+	return "foo " + f.Name, nil
+}
+
+type story struct {
+	ID    string
+	Likes int
+}
+
+func loadSchema(t testing.TB) *schema.Schema {
+	f, err := os.Open("../tests/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := schema.Parse("schema.graphql", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testExecutor(t testing.TB) *Executor {
+	e := New(loadSchema(t))
+	stories := map[string]*story{"1": {ID: "1", Likes: 41}}
+	e.Resolve("User.friends", func(p Params) (interface{}, error) {
+		fs := p.Source.(*user).Friends
+		if n := p.Args["first"].(int); n < len(fs) {
+			fs = fs[:n]
+		}
+		return fs, nil
+	})
+	e.Resolve("Query.unnamed", func(p Params) (interface{}, error) {
+		b, _ := json.Marshal(p.Args)
+		return string(b), nil
+	})
+	e.Resolve("Mutation.like", func(p Params) (interface{}, error) {
+		s, ok := stories[p.Args["story"].(string)]
+		if !ok {
+			return nil, errors.New("no such story")
+		}
+		s.Likes++
+		return map[string]interface{}{"story": s}, nil
+	})
+	return e
+}
+
+var root = map[string]interface{}{
+	"me": &user{
+		ID:   "u1",
+		Name: "sevki",
+		Friends: []*friend{
+			{ID: 1, Name: "a"},
+			{ID: 2},
+			{ID: 3, Name: "c"},
+		},
+	},
+}
+
+var executorTests = []struct {
+	query     string
+	operation string
+	vars      map[string]interface{}
+	result    string
+}{
+	{
+		`{ me { id name friends { id } } }`, "", nil,
+		`{"data":{"me":{"id":"u1","name":"sevki","friends":[{"id":"1"},{"id":"2"},{"id":"3"}]}}}`,
+	},
+	{
+		`query q($n: Int = 2) { me { __typename ... on User { f: friends(first: $n) { name } } ...f } }
+fragment f on User { f: friends(first: $n) { id } }`, "", nil,
+		`{"data":{"me":{"__typename":"User","f":[{"name":"a","id":"1"},{"name":"","id":"2"}]}}}`,
+	},
+	{
+		`query q($n: Int) { me { friends(first: $n) { id } } }`, "", map[string]interface{}{"n": 1.0},
+		`{"data":{"me":{"friends":[{"id":"1"}]}}}`,
+	},
+	{
+		`query q($n: Int = 1) { me { friends(first: $n) { id } } }`, "", map[string]interface{}{"n": 1.5},
+		`{"errors":[{"message":"Variable \"$n\" got invalid value 1.5; Int cannot represent non-integer value: 1.5","locations":[{"line":1,"column":9}]}]}`,
+	},
+	{
+		`{ unnamed(truthy: true) query { unnamed(falsey: false) } }`, "", nil,
+		`{"data":{"unnamed":"{\"truthy\":true}","query":null}}`,
+	},
+	{
+		`query a { me { id } } mutation b { like(story: "1") { story { id likes } } }`, "b", nil,
+		`{"data":{"like":{"story":{"id":"1","likes":42}}}}`,
+	},
+	{
+		`mutation { like(story: "2") { story { id } } }`, "", nil,
+		`{"data":{"like":null},"errors":[{"message":"no such story","locations":[{"line":1,"column":12}],"path":["like"]}]}`,
+	},
+	{
+		`{ me { friends { foo } } }`, "", nil,
+		`{"data":{"me":{"friends":[{"foo":"foo a"},{"foo":null},{"foo":"foo c"}]}},"errors":[{"message":"nameless","locations":[{"line":1,"column":18}],"path":["me","friends",1,"foo"]}]}`,
+	},
+	{
+		`query a { me { id } } query b { me { id } }`, "", nil,
+		`{"errors":[{"message":"Must provide operation name if query contains multiple operations."}]}`,
+	},
+	{
+		`query a { me { id } }`, "b", nil,
+		`{"errors":[{"message":"Unknown operation named \"b\"."}]}`,
+	},
+	{
+		`{ me { nope } }`, "", nil,
+		`{"errors":[{"message":"Cannot query field \"nope\" on type \"User\".","locations":[{"line":1,"column":8}]}]}`,
+	},
+}
+
+func TestExecute(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		e := testExecutor(t)
+		e.Workers = workers
+		testExecute(t, e)
+	}
+}
+
+func testExecute(t *testing.T, e *Executor) {
+	for _, test := range executorTests {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.query, err)
+		}
+		b, err := json.Marshal(e.Execute(doc, test.operation, test.vars, root))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("executing %q with %d workers\ngot:\n%s\nexpected:\n%s", test.query, e.Workers, b, test.result)
+		}
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	e := New(loadSchema(t))
+	e.Workers = 3
+	var mu sync.Mutex
+	running, most := 0, 0
+	e.Resolve("Friend.foo", func(p Params) (interface{}, error) {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return fmt.Sprint(p.Args["size"]), nil
+	})
+	var friends []*friend
+	for i := 0; i < 10; i++ {
+		friends = append(friends, &friend{ID: i})
+	}
+	me := map[string]interface{}{"me": &user{Friends: friends}}
+	doc, err := parser.NewQuery([]byte(`{ me { friends(first: 100) { a: foo(size: 1) b: foo(size: 2) } } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	res := e.Execute(doc, "", nil, me)
+	if len(res.Errors) > 0 {
+		t.Fatal(res.Errors[0])
+	}
+	if most != 3 {
+		t.Errorf("%d resolvers ran at once, expected 3", most)
+	}
+	// 20 calls of 10ms, 3 at a time.
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("took %s, expected fields to be resolved in parallel", d)
+	}
+	b, _ := json.Marshal(res)
+	if string(b) != `{"data":{"me":{"friends":[`+strings.Repeat(`{"a":"1","b":"2"},`, 9)+`{"a":"1","b":"2"}]}}}` {
+		t.Errorf("got %s", b)
+	}
+}
+
+func TestSerialMutations(t *testing.T) {
+	e := New(loadSchema(t))
+	e.Workers = 10
+	var mu sync.Mutex
+	var order []string
+	e.Resolve("Mutation.like", func(p Params) (interface{}, error) {
+		id := p.Args["story"].(string)
+		// later mutations are faster, they would finish first if
+		// they ran at the same time.
+		time.Sleep(time.Duration(5-len(id)) * 5 * time.Millisecond)
+		mu.Lock()
+		order = append(order, id)
+		mu.Unlock()
+		return nil, nil
+	})
+	doc, err := parser.NewQuery([]byte(`mutation { a: like(story: "1") { __typename } b: like(story: "12") { __typename } c: like(story: "123") { __typename } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := e.Execute(doc, "", nil, nil); len(res.Errors) > 0 {
+		t.Fatal(res.Errors[0])
+	}
+	if got := strings.Join(order, ","); got != "1,12,123" {
+		t.Errorf("mutations ran in order %s, expected 1,12,123", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	e := New(loadSchema(t))
+	e.Workers = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	e.Resolve("Friend.foo", func(p Params) (interface{}, error) {
+		cancel()
+		<-p.Context.Done()
+		return nil, p.Context.Err()
+	})
+	me := map[string]interface{}{"me": &user{Friends: []*friend{{ID: 1}, {ID: 2}}}}
+	doc, err := parser.NewQuery([]byte(`{ me { friends { foo } } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := e.ExecuteContext(ctx, doc, "", nil, me)
+	if len(res.Errors) != 2 {
+		t.Fatalf("got %d errors, expected 2", len(res.Errors))
+	}
+	for _, err := range res.Errors {
+		if err.Message != context.Canceled.Error() {
+			t.Errorf("got error %q, expected %q", err.Message, context.Canceled)
+		}
+	}
+}
+
+type codeError string
+
+func (e codeError) Error() string { return "failed with " + string(e) }
+
+func (e codeError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": string(e)}
+}
+
+var nullTests = []struct {
+	query  string
+	result string
+}{
+	{
+		`{ broken: me { friends { __typename } } }`,
+		`{"data":{"broken":null},"errors":[{"message":"Cannot return null for non-nullable field User.friends.","locations":[{"line":1,"column":16}],"path":["broken","friends",1]}]}`,
+	},
+	{
+		`{ me { friends { foo } } }`,
+		`{"data":{"me":{"friends":[{"foo":null},{"foo":"2"}]}},"errors":[{"message":"failed with FORBIDDEN","locations":[{"line":1,"column":18}],"path":["me","friends",0,"foo"],"extensions":{"code":"FORBIDDEN"}}]}`,
+	},
+	{
+		`mutation { like(story: "1") { story { id } } }`,
+		`{"data":{"like":null},"errors":[{"message":"Cannot return null for non-nullable field LikePayload.story.","locations":[{"line":1,"column":31}],"path":["like","story"]}]}`,
+	},
+	{
+		`{ me { id } query { me { createdAt } } }`,
+		`{"data":{"me":null,"query":{"me":{"createdAt":null}}},"errors":[{"message":"not allowed","locations":[{"line":1,"column":8}],"path":["me","id"],"extensions":{"code":"FORBIDDEN","retry":false}}]}`,
+	},
+}
+
+func TestNullPropagation(t *testing.T) {
+	e := New(loadSchema(t))
+	e.Resolve("Query.me", func(p Params) (interface{}, error) {
+		if p.Field.Alias == "broken" {
+			return &user{Friends: []*friend{{ID: 1}, nil}}, nil
+		}
+		return &user{Friends: []*friend{{ID: 1}, {ID: 2}}}, nil
+	})
+	e.Resolve("Friend.foo", func(p Params) (interface{}, error) {
+		if id := p.Source.(*friend).ID; id != 1 {
+			return id, nil
+		}
+		return nil, codeError("FORBIDDEN")
+	})
+	e.Resolve("User.id", func(p Params) (interface{}, error) {
+		if p.Field.Pos.Column != 8 {
+			return "u", nil
+		}
+		return nil, &Error{Message: "not allowed", Extensions: map[string]interface{}{"code": "FORBIDDEN", "retry": false}}
+	})
+	e.Resolve("Query.query", func(p Params) (interface{}, error) {
+		return p.Root, nil
+	})
+	e.Resolve("Mutation.like", func(p Params) (interface{}, error) {
+		return map[string]interface{}{"story": nil}, nil
+	})
+	for _, test := range nullTests {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.query, err)
+		}
+		b, err := json.Marshal(e.Execute(doc, "", nil, struct{}{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("executing %q\ngot:\n%s\nexpected:\n%s", test.query, b, test.result)
+		}
+	}
+}
+
+func TestPanic(t *testing.T) {
+	e := testExecutor(t)
+	e.Resolve("Friend.foo", func(p Params) (interface{}, error) {
+		f := p.Source.(*friend)
+		if f.Name == "" {
+			panic("nameless")
+		}
+		return "foo " + f.Name, nil
+	})
+	doc, err := parser.NewQuery([]byte(`{ me { friends { foo } } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(e.Execute(doc, "", nil, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := `{"data":{"me":{"friends":[{"foo":"foo a"},{"foo":null},{"foo":"foo c"}]}},"errors":[{"message":"Resolver for Friend.foo panicked: nameless","locations":[{"line":1,"column":18}],"path":["me","friends",1,"foo"]}]}`
+	if string(b) != result {
+		t.Errorf("got:\n%s\nexpected:\n%s", b, result)
+	}
+}
+
+func parseVariables(t *testing.T, query string) ast.VariableDefinitions {
+	doc, err := parser.NewQuery([]byte(query))
+	if err != nil {
+		t.Fatalf("parsing %q: %v", query, err)
+	}
+	return doc.Definitions[0].(*ast.Operation).VariableDefinitions
+}
+
+var coerceTests = []struct {
+	defs   string
+	vars   string
+	result string
+	errs   string
+}{
+	{
+		`($a: Int, $b: Float, $c: String, $d: Boolean, $e: ID, $f: ID)`,
+		`{"a": 1, "b": 2, "c": "x", "d": true, "e": 3, "f": "4"}`,
+		`{"a":1,"b":2,"c":"x","d":true,"e":"3","f":"4"}`,
+		``,
+	},
+	{
+		`($a: Int = 1, $b: [Int] = 2, $c: Site = WAP, $d: ComplexType = {id: 1}, $e: String, $f: String)`,
+		`{"f": null}`,
+		`{"a":1,"b":[2],"c":"WAP","d":{"id":"1","site":"MOBILE"},"f":null}`,
+		``,
+	},
+	{
+		`($a: [[Int!]], $b: ComplexType!, $c: [Site])`,
+		`{"a": [[1, 2], 3], "b": {"id": 1, "tags": "x", "site": "DESKTOP"}, "c": "MOBILE"}`,
+		`{"a":[[1,2],[3]],"b":{"id":"1","site":"DESKTOP","tags":["x"]},"c":["MOBILE"]}`,
+		``,
+	},
+	{
+		`($a: Int!, $b: String!, $c: Int, $d: [Int!], $e: Site, $f: ComplexType, $g: ComplexType, $h: ComplexType)`,
+		`{"b": null, "c": 3000000000, "d": [1, null], "e": "NOPE", "f": {"site": "WAP"}, "g": {"id": 1, "nope": 2}, "h": {"id": 1, "tags": ["x", 1]}}`,
+		`{}`,
+		`1:8: Variable "$a" of required type "Int!" was not provided.
+1:18: Variable "$b" of non-null type "String!" must not be null.
+1:31: Variable "$c" got invalid value 3000000000; Int cannot represent non 32-bit signed integer value: 3000000000
+1:40: Variable "$d" got invalid value null at "d[1]"; Expected non-nullable type "Int!" not to be null.
+1:52: Variable "$e" got invalid value "NOPE"; Value "NOPE" does not exist in "Site" enum.
+1:62: Variable "$f" got invalid value {"site":"WAP"}; Field "id" of required type "ID!" was not provided.
+1:79: Variable "$g" got invalid value {"id":1,"nope":2}; Field "nope" is not defined by type "ComplexType".
+1:96: Variable "$h" got invalid value 1 at "h.tags[1]"; String cannot represent a non string value: 1`,
+	},
+}
+
+func TestNullData(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`type Query { a: Int! }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(s)
+	for _, test := range []struct {
+		query, result string
+		requestError  bool
+	}{
+		{`{ a }`, `{"data":null,"errors":[{"message":"Cannot return null for non-nullable field Query.a.","locations":[{"line":1,"column":3}],"path":["a"]}]}`, false},
+		{`{ b }`, `{"errors":[{"message":"Cannot query field \"b\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}`, true},
+	} {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := e.Execute(doc, "", nil, struct{}{})
+		if res.RequestError() != test.requestError {
+			t.Errorf("%s: RequestError is %v", test.query, res.RequestError())
+		}
+		b, err := json.Marshal(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("executing %q\ngot:\n%s\nexpected:\n%s", test.query, b, test.result)
+		}
+	}
+}
+
+func TestCoerceVariables(t *testing.T) {
+	s := loadSchema(t)
+	for _, test := range coerceTests {
+		var vars map[string]interface{}
+		if err := json.Unmarshal([]byte(test.vars), &vars); err != nil {
+			t.Fatal(err)
+		}
+		coerced, errs := CoerceVariables(s, parseVariables(t, "query "+test.defs+" { me }"), vars)
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		if got := strings.Join(msgs, "\n"); got != test.errs {
+			t.Errorf("coercing %s to %s\ngot errors:\n%s\nexpected:\n%s", test.vars, test.defs, got, test.errs)
+		}
+		if b, _ := json.Marshal(coerced); string(b) != test.result {
+			t.Errorf("coercing %s to %s\ngot %s, expected %s", test.vars, test.defs, b, test.result)
+		}
+	}
+}
+
+var literalTests = []struct {
+	typ    ast.Type
+	value  ast.Value
+	result string
+	err    string
+}{
+	{"Int", ast.GraphQLInt(1), `1`, ``},
+	{"Float", ast.GraphQLInt(1), `1`, ``},
+	{"ID", ast.GraphQLInt(1), `"1"`, ``},
+	{"[Int]", ast.GraphQLInt(1), `[1]`, ``},
+	{"[Int]!", ast.ArrayValue{ast.GraphQLInt(1), ast.VariableValue{Name: "missing"}}, `[1,null]`, ``},
+	{"Int!", ast.VariableValue{Name: "missing"}, `null`, `Variable "$missing" of type "Int!" must not be null.`},
+	{"Int", ast.VariableValue{Name: "a"}, `4`, ``},
+	{"Int", ast.GraphQLFloat(1.5), `null`, `Int cannot represent value: 1.5`},
+	{"Int", ast.IntValue("-2147483648"), `-2147483648`, ``},
+	{"Int", ast.IntValue("3000000000"), `null`, `Int cannot represent non 32-bit signed integer value: 3000000000`},
+	{"Float", ast.IntValue("3000000000"), `3000000000`, ``},
+	{"ID", ast.IntValue("0x10"), `"16"`, ``},
+	{"DateTime", ast.IntValue("12345678901234567890"), `12345678901234567890`, ``},
+	{"String!", ast.NullValue{}, `null`, `Expected value of non-null type "String!" not to be null.`},
+	{"Site", ast.GraphQLString("WAP"), `null`, `Value "WAP" does not exist in "Site" enum.`},
+	{"DateTime", ast.ObjectValue{{Name: "at", Value: ast.GraphQLInt(1)}}, `{"at":1}`, ``},
+	{
+		"ComplexType",
+		ast.ObjectValue{{Name: "id", Value: ast.VariableValue{Name: "a"}}, {Name: "site", Value: ast.VariableValue{Name: "missing"}}},
+		`{"id":4,"site":"MOBILE"}`, ``,
+	},
+}
+
+func TestCoerceLiteral(t *testing.T) {
+	s := loadSchema(t)
+	vars := map[string]interface{}{"a": 4}
+	for _, test := range literalTests {
+		v, err := CoerceLiteral(s, test.value, test.typ, vars)
+		var msg string
+		if err != nil {
+			msg = err.Error()
+		}
+		if msg != test.err {
+			t.Errorf("coercing %s to %s: got error %q, expected %q", ast.FormatValue(test.value), test.typ, msg, test.err)
+		}
+		if b, _ := json.Marshal(v); string(b) != test.result {
+			t.Errorf("coercing %s to %s: got %s, expected %s", ast.FormatValue(test.value), test.typ, b, test.result)
+		}
+	}
+}
+
+// dateTime is a DateTime scalar as specified by RFC 3339.
+type dateTime struct{}
+
+func (dateTime) ParseLiteral(v ast.Value) (interface{}, error) {
+	s, ok := v.(ast.GraphQLString)
+	if !ok {
+		return nil, fmt.Errorf("DateTime must be a string, got %s", ast.FormatValue(v))
+	}
+	return dateTime{}.ParseValue(string(s))
+}
+
+func (dateTime) ParseValue(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("DateTime must be a string, got %T", v)
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func (dateTime) Serialize(v interface{}) (interface{}, error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("DateTime can't represent %T", v)
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+var scalarTests = []struct {
+	query  string
+	vars   map[string]interface{}
+	result string
+}{
+	{
+		`{ later(t: "2015-07-01T12:00:00+02:00") }`, nil,
+		`{"data":{"later":"2015-07-02T10:00:00Z"}}`,
+	},
+	{
+		`query ($t: DateTime!) { later(t: $t, days: 2) }`, map[string]interface{}{"t": "2015-07-01T12:00:00Z"},
+		`{"data":{"later":"2015-07-03T12:00:00Z"}}`,
+	},
+	{
+		`{ later(t: "yesterday") }`, nil,
+		`{"errors":[{"message":"Expected value of type \"DateTime!\", found \"yesterday\"; parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\"","locations":[{"line":1,"column":9}]}]}`,
+	},
+	{
+		`query ($t: DateTime!) { later(t: $t) }`, map[string]interface{}{"t": 1},
+		`{"errors":[{"message":"Variable \"$t\" got invalid value 1; DateTime must be a string, got float64","locations":[{"line":1,"column":8}]}]}`,
+	},
+	{
+		`{ broken: later(t: "2015-07-01T12:00:00Z") }`, nil,
+		`{"data":{"broken":null},"errors":[{"message":"DateTime can't represent string","locations":[{"line":1,"column":3}],"path":["broken"]}]}`,
+	},
+}
+
+func TestCustomScalars(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`
+scalar DateTime @specifiedBy(url: "https://tools.ietf.org/html/rfc3339")
+type Query { later(t: DateTime!, days: Int = 1): DateTime }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetScalar("DateTime", dateTime{}); err != nil {
+		t.Fatal(err)
+	}
+	e := New(s)
+	e.Resolve("Query.later", func(p Params) (interface{}, error) {
+		if p.Field.Alias == "broken" {
+			return "tomorrow", nil
+		}
+		return p.Args["t"].(time.Time).AddDate(0, 0, p.Args["days"].(int)), nil
+	})
+	for _, test := range scalarTests {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.query, err)
+		}
+		// variables come from JSON.
+		b, _ := json.Marshal(test.vars)
+		var vars map[string]interface{}
+		json.Unmarshal(b, &vars)
+		b, err = json.Marshal(e.Execute(doc, "", vars, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("executing %q\ngot:\n%s\nexpected:\n%s", test.query, b, test.result)
+		}
+	}
+}
+
+// point is a scalar written as {x: Int, y: Int}.
+type point struct{}
+
+func (point) ParseLiteral(v ast.Value) (interface{}, error) {
+	o, ok := v.(ast.ObjectValue)
+	if !ok {
+		return nil, fmt.Errorf("Point must be an object, got %s", ast.FormatValue(v))
+	}
+	var xy []string
+	for _, k := range []string{"x", "y"} {
+		c, ok := o.Get(k)
+		if _, isInt := c.(ast.IntValue); !ok || !isInt {
+			return nil, fmt.Errorf("Point.%s must be an integer, got %s", k, ast.FormatValue(c))
+		}
+		xy = append(xy, ast.FormatValue(c))
+	}
+	return strings.Join(xy, ","), nil
+}
+
+func (point) ParseValue(v interface{}) (interface{}, error) { return v, nil }
+func (point) Serialize(v interface{}) (interface{}, error)  { return v, nil }
+
+func TestScalarVariables(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`
+scalar Point
+scalar Raw
+type Query { at(p: Point): String raw(r: Raw): String }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetScalar("Point", point{}); err != nil {
+		t.Fatal(err)
+	}
+	e := New(s)
+	e.Resolve("Query.at", func(p Params) (interface{}, error) {
+		return p.Args["p"], nil
+	})
+	e.Resolve("Query.raw", func(p Params) (interface{}, error) {
+		b, err := json.Marshal(p.Args["r"])
+		return string(b), err
+	})
+	doc, err := parser.NewQuery([]byte(`query ($x: Int, $m: Int) { at(p: {x: $x, y: 2}) raw(r: {a: [$x, $m], b: $m}) }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(e.Execute(doc, "", map[string]interface{}{"x": 1.0}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := `{"data":{"at":"1,2","raw":"{\"a\":[1,null]}"}}`
+	if string(b) != result {
+		t.Errorf("got:\n%s\nexpected:\n%s", b, result)
+	}
+}
+
+// brittle is a scalar that can't serialize anything.
+type brittle struct{}
+
+func (brittle) ParseLiteral(v ast.Value) (interface{}, error) { return nil, nil }
+func (brittle) ParseValue(v interface{}) (interface{}, error) { return v, nil }
+func (brittle) Serialize(v interface{}) (interface{}, error)  { panic("cracked") }
+
+// label is a Stringer with a pointer receiver.
+type label struct{ text string }
+
+func (l *label) String() string { return l.text }
+
+func TestSerialize(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`
+scalar Brittle
+type Query { a: Brittle b: [Brittle] c: String }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetScalar("Brittle", brittle{}); err != nil {
+		t.Fatal(err)
+	}
+	e := New(s)
+	e.Workers = 4
+	doc, err := parser.NewQuery([]byte(`{ c b }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := map[string]interface{}{"b": []int{1, 2}, "c": &label{"x"}}
+	b, err := json.Marshal(e.Execute(doc, "", nil, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Data   map[string]interface{}
+		Errors []*Error
+	}
+	json.Unmarshal(b, &res)
+	if res.Data["c"] != "x" || res.Data["b"] == nil {
+		t.Errorf("got %s", b)
+	}
+	if len(res.Errors) != 2 {
+		t.Fatalf("got %s", b)
+	}
+	for _, e := range res.Errors {
+		if e.Message != "Serializing Brittle panicked: cracked" {
+			t.Errorf("got error %q", e.Message)
+		}
+	}
+}
+
+func TestTypePanics(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`
+union U = A
+type A { x: Int }
+type Query { u: U a: A }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(s)
+	e.Workers = 4
+	e.ResolveType("U", func(ctx context.Context, v interface{}) (string, error) {
+		panic("lost")
+	})
+	e.IsTypeOf("A", func(ctx context.Context, v interface{}) bool {
+		panic("unsure")
+	})
+	doc, err := parser.NewQuery([]byte(`{ a { x } u { ... on A { x } } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := map[string]interface{}{"a": map[string]interface{}{"x": 1}, "u": map[string]interface{}{"x": 2}}
+	res := e.Execute(doc, "", nil, root)
+	var msgs []string
+	for _, e := range res.Errors {
+		msgs = append(msgs, fmt.Sprintf("%v: %s", e.Path, e.Message))
+	}
+	sort.Strings(msgs)
+	want := "[a]: Checking the type of A panicked: unsure\n[u]: Resolving the type of U panicked: lost"
+	if got := strings.Join(msgs, "\n"); got != want {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+var abstractTests = []struct {
+	query  string
+	result string
+}{
+	{
+		`{ search(text: "x") { __typename ... on User { name } ... on Friend { name id } ... on Picture { url } } }`,
+		`{"data":{"search":[{"__typename":"User","name":"u"},{"__typename":"Friend","name":"f","id":"1"},{"__typename":"Picture","url":"p.png"}]}}`,
+	},
+	{
+		`{ node(id: "s1") { __typename id ... on Story { likes } ... on User { name } } }`,
+		`{"data":{"node":{"__typename":"Story","id":"s1","likes":3}}}`,
+	},
+	{
+		`{ node(id: "p") { id } }`,
+		`{"data":{"node":null},"errors":[{"message":"Runtime object type \"Picture\" is not a possible type for \"Node\".","locations":[{"line":1,"column":3}],"path":["node"]}]}`,
+	},
+	{
+		`{ named: node(id: "x") { id } }`,
+		`{"data":{"named":null},"errors":[{"message":"Abstract type Node must resolve to an object type at runtime. Either the Node type should have a ResolveType function or each possible type should have an IsTypeOf function.","locations":[{"line":1,"column":3}],"path":["named"]}]}`,
+	},
+	{
+		`mutation { like(story: "s1") { story { id } } }`,
+		`{"data":{"like":null},"errors":[{"message":"Expected value of type \"Story\" but got: *executor.user.","locations":[{"line":1,"column":32}],"path":["like","story"]}]}`,
+	},
+}
+
+func TestAbstractTypes(t *testing.T) {
+	e := New(loadSchema(t))
+	e.Resolve("Query.search", func(p Params) (interface{}, error) {
+		return []interface{}{
+			&user{Name: "u"},
+			&friend{ID: 1, Name: "f"},
+			map[string]interface{}{"__typename": "Picture", "url": "p.png"},
+		}, nil
+	})
+	e.Resolve("Query.node", func(p Params) (interface{}, error) {
+		switch p.Args["id"].([]interface{})[0] {
+		case "s1":
+			return &story{ID: "s1", Likes: 3}, nil
+		case "p":
+			return map[string]interface{}{"url": "p.png"}, nil
+		}
+		return 42, nil
+	})
+	e.ResolveType("Node", func(ctx context.Context, v interface{}) (string, error) {
+		switch v.(type) {
+		case *story:
+			return "Story", nil
+		case map[string]interface{}:
+			return "Picture", nil
+		}
+		return "", nil
+	})
+	e.Resolve("Mutation.like", func(p Params) (interface{}, error) {
+		return map[string]interface{}{"story": &user{}}, nil
+	})
+	e.IsTypeOf("Story", func(ctx context.Context, v interface{}) bool {
+		_, ok := v.(*story)
+		return ok
+	})
+	for _, test := range abstractTests {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.query, err)
+		}
+		b, err := json.Marshal(e.Execute(doc, "", nil, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.result {
+			t.Errorf("executing %q\ngot:\n%s\nexpected:\n%s", test.query, b, test.result)
+		}
+	}
+}
+
+var collectTests = []struct {
+	query  string
+	vars   string
+	fields string
+}{
+	{`{ me { id } me { name } a: me b: me @skip(if: false) c: me @skip(if: true) }`, `{}`, `me(1:3,1:13) a(1:25) b(1:31)`},
+	{`query ($s: Boolean!) { me @include(if: $s) ...f ... @skip(if: $s) { me } } fragment f on Query { x: me @skip(if: $s) }`, `{"s": true}`, `me(1:24)`},
+	{`query ($s: Boolean!) { me @include(if: $s) ...f ... @skip(if: $s) { me } } fragment f on Query { x: me @skip(if: $s) }`, `{"s": false}`, `x(1:98) me(1:69)`},
+	{`query ($s: Boolean = true) { me @skip(if: $s) @include(if: true) ...f @include(if: $s) ... on User { x: me } } fragment f on Node { y: me }`, `{}`, ``},
+	{`{ ...f ...f ... on Query { ...f } } fragment f on Query { me }`, `{}`, `me(1:59)`},
+}
+
+func TestCollectFields(t *testing.T) {
+	s := loadSchema(t)
+	for _, test := range collectTests {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.query, err)
+		}
+		op := doc.Definitions[0].(*ast.Operation)
+		var vars map[string]interface{}
+		if err := json.Unmarshal([]byte(test.vars), &vars); err != nil {
+			t.Fatal(err)
+		}
+		coerced, errs := CoerceVariables(s, op.VariableDefinitions, vars)
+		if len(errs) > 0 {
+			t.Fatal(errs[0])
+		}
+		var got []string
+		for _, g := range CollectFields(s, s.Root(ast.Query), op.SelectionSet, Fragments(doc), coerced) {
+			var locs []string
+			for _, f := range g.Fields {
+				locs = append(locs, f.Pos.String())
+			}
+			got = append(got, fmt.Sprintf("%s(%s)", g.Key, strings.Join(locs, ",")))
+		}
+		if g := strings.Join(got, " "); g != test.fields {
+			t.Errorf("collecting %q with %s\ngot:      %s\nexpected: %s", test.query, test.vars, g, test.fields)
+		}
+	}
+}
+
+func TestLoaders(t *testing.T) {
+	// 50 sibling fields load 10 keys, fewer workers than siblings
+	// mustn't split the keys into more batches.
+	for _, workers := range []int{0, 1, 4, 100} {
+		e := New(loadSchema(t))
+		e.Workers = workers
+		var mu sync.Mutex
+		var batches [][]interface{}
+		e.Loader("names", func() *dataloader.Loader {
+			l := dataloader.New(func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+				mu.Lock()
+				batches = append(batches, keys)
+				mu.Unlock()
+				names := make([]interface{}, len(keys))
+				for i, k := range keys {
+					names[i] = fmt.Sprintf("friend %d", k)
+				}
+				return names, nil
+			})
+			l.Wait = 20 * time.Millisecond
+			return l
+		})
+		e.Resolve("Friend.name", func(p Params) (interface{}, error) {
+			return p.Loader("names").Load(p.Context, p.Source.(*friend).ID)
+		})
+		var friends []*friend
+		for i := 0; i < 50; i++ {
+			friends = append(friends, &friend{ID: i % 10})
+		}
+		me := map[string]interface{}{"me": &user{Friends: friends}}
+		doc, err := parser.NewQuery([]byte(`{ me { friends(first: 50) { name } } }`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 2; i++ {
+			res := e.Execute(doc, "", nil, me)
+			if len(res.Errors) > 0 {
+				t.Fatal(res.Errors[0])
+			}
+			// every request has its own cache.
+			if len(batches) != i || len(batches[i-1]) != 10 {
+				t.Fatalf("%d workers, request %d: got batches %v, expected one of 10 keys per request", workers, i, batches)
+			}
+			v, _ := res.Data.(Object).Get("me")
+			fs, _ := v.(Object).Get("friends")
+			if n, _ := fs.([]interface{})[13].(Object).Get("name"); n != "friend 3" {
+				t.Errorf("friend 13 is named %v", n)
+			}
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	e := New(loadSchema(t))
+	e.Resolve("Subscription.storyLiked", func(p Params) (interface{}, error) {
+		if p.Args["story"] != "1" {
+			return "nope", nil
+		}
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			for _, ev := range []interface{}{
+				&story{ID: "1", Likes: 1},
+				errors.New("lost a like"),
+				map[string]interface{}{"id": "1"},
+				&story{ID: "1", Likes: 2},
+			} {
+				select {
+				case ch <- ev:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	})
+	doc, err := parser.NewQuery([]byte(`subscription ($s: ID!) { liked: storyLiked(story: $s) { id likes } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, res := e.Subscribe(context.Background(), doc, "", map[string]interface{}{"s": "1"}, nil)
+	if res != nil {
+		t.Fatalf("subscribing failed: %v", res.Errors)
+	}
+	var got []string
+	for res := range results {
+		b, err := json.Marshal(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+	expected := []string{
+		`{"data":{"liked":{"id":"1","likes":1}}}`,
+		`{"data":{"liked":null},"errors":[{"message":"lost a like","locations":[{"line":1,"column":26}],"path":["liked"]}]}`,
+		`{"data":{"liked":null},"errors":[{"message":"Cannot return null for non-nullable field Story.likes.","locations":[{"line":1,"column":60}],"path":["liked","likes"]}]}`,
+		`{"data":{"liked":{"id":"1","likes":2}}}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, _ = e.Subscribe(ctx, doc, "", map[string]interface{}{"s": "1"}, nil)
+	<-results
+	cancel()
+	for range results {
+	}
+
+	for _, test := range []struct {
+		query, result string
+	}{
+		{
+			`subscription { storyLiked(story: "2") { id } }`,
+			`{"errors":[{"message":"Subscription field Subscription.storyLiked must resolve to a channel, got string.","locations":[{"line":1,"column":16}],"path":["storyLiked"]}]}`,
+		},
+		{
+			`{ me { id } }`,
+			`{"errors":[{"message":"Expected a subscription, got a query."}]}`,
+		},
+	} {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, res := e.Subscribe(context.Background(), doc, "", nil, nil)
+		if results != nil {
+			t.Errorf("%s: subscribed", test.query)
+			continue
+		}
+		b, _ := json.Marshal(res)
+		if string(b) != test.result {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", test.query, b, test.result)
+		}
+	}
+}
+
+var incrementalTests = []struct {
+	query    string
+	payloads []string
+}{
+	{
+		`{ me { id ... @defer(label: "more") { name } } }`,
+		[]string{
+			`{"data":{"me":{"id":"u1"}},"hasNext":true}`,
+			`{"incremental":[{"data":{"name":"sevki"},"path":["me"],"label":"more"}],"hasNext":false}`,
+		},
+	},
+	{
+		`{ me { id name @defer } }`,
+		[]string{
+			`{"data":{"me":{"id":"u1"}},"hasNext":true}`,
+			`{"incremental":[{"data":{"name":"sevki"},"path":["me"]}],"hasNext":false}`,
+		},
+	},
+	{
+		`{ me { friends @stream(initialCount: 1, label: "f") { id } } }`,
+		[]string{
+			`{"data":{"me":{"friends":[{"id":"1"}]}},"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":"2"}],"path":["me","friends",1],"label":"f"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":"3"}],"path":["me","friends",2],"label":"f"}],"hasNext":false}`,
+		},
+	},
+	{
+		`{ ... @defer { me { id ...f @defer(label: "f") } } }
+fragment f on User { friends(first: 1) { foo } }`,
+		[]string{
+			`{"data":{},"hasNext":true}`,
+			`{"incremental":[{"data":{"me":{"id":"u1"}},"path":[]}],"hasNext":true}`,
+			`{"incremental":[{"data":{"friends":[{"foo":"foo a"}]},"path":["me"],"label":"f"}],"hasNext":false}`,
+		},
+	},
+	{
+		`{ me { ... @defer { friends { foo } } } }`,
+		[]string{
+			`{"data":{"me":{}},"hasNext":true}`,
+			`{"incremental":[{"data":{"friends":[{"foo":"foo a"},{"foo":null},{"foo":"foo c"}]},"path":["me"],"errors":[{"message":"nameless","locations":[{"line":1,"column":31}],"path":["me","friends",1,"foo"]}]}],"hasNext":false}`,
+		},
+	},
+	{
+		`query ($d: Boolean!) { me { id ... @defer(if: $d) { name } friends @stream(if: $d) { id } } }`,
+		[]string{
+			`{"data":{"me":{"id":"u1","name":"sevki","friends":[{"id":"1"},{"id":"2"},{"id":"3"}]}}}`,
+		},
+	},
+	{
+		`{ me { friends @stream(initialCount: -1) { id } } }`,
+		[]string{
+			`{"data":{"me":null},"errors":[{"message":"initialCount must be a positive integer","locations":[{"line":1,"column":8}],"path":["me","friends"]}]}`,
+		},
+	},
+}
+
+// payloads returns the payloads of an incremental response.
+func payloads(res *Result, patches <-chan *Patch) []string {
+	b, _ := json.Marshal(res)
+	got := []string{string(b)}
+	if patches == nil {
+		return got
+	}
+	for p := range patches {
+		b, _ := json.Marshal(p)
+		got = append(got, string(b))
+	}
+	return got
+}
+
+func TestExecuteIncremental(t *testing.T) {
+	e := testExecutor(t)
+	for _, test := range incrementalTests {
+		doc, err := parser.NewQuery([]byte(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := payloads(e.ExecuteIncremental(context.Background(), doc, "", map[string]interface{}{"d": false}, root))
+		if g, w := strings.Join(got, "\n"), strings.Join(test.payloads, "\n"); g != w {
+			t.Errorf("%s\ngot:\n%s\nwant:\n%s", test.query, g, w)
+		}
+	}
+
+	// deferred fragments in values that are made null are dropped.
+	e.Resolve("Query.me", func(p Params) (interface{}, error) {
+		return &user{ID: "u1", Friends: []*friend{nil}}, nil
+	})
+	doc, err := parser.NewQuery([]byte(`{ me { friends { ... @defer { id } } } a: me { id ... @defer { name } } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := payloads(e.ExecuteIncremental(context.Background(), doc, "", nil, nil))
+	want := []string{
+		`{"data":{"me":null,"a":{"id":"u1"}},"errors":[{"message":"Cannot return null for non-nullable field User.friends.","locations":[{"line":1,"column":8}],"path":["me","friends",0]}],"hasNext":true}`,
+		`{"incremental":[{"data":{"name":""},"path":["a"]}],"hasNext":false}`,
+	}
+	if g, w := strings.Join(got, "\n"), strings.Join(want, "\n"); g != w {
+		t.Errorf("got:\n%s\nwant:\n%s", g, w)
+	}
+
+	// without ExecuteIncremental nothing is deferred.
+	doc, err = parser.NewQuery([]byte(`{ me { id ... @defer { name } } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(testExecutor(t).Execute(doc, "", nil, root))
+	if string(b) != `{"data":{"me":{"id":"u1","name":"sevki"}}}` {
+		t.Errorf("got %s", b)
+	}
+}
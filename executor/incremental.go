@@ -0,0 +1,211 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"context"
+	"errors"
+
+	"sevki.org/graphql/ast"
+)
+
+// ExecuteIncremental is like ExecuteContext, except that fragments and
+// fields deferred with @defer, and the items of lists streamed with
+// @stream past their initialCount, are delivered after the rest of
+// the response, as described in
+// https://github.com/graphql/graphql-spec/blob/main/rfcs/DeferStream.md
+//
+// If anything was deferred, the result has HasNext set and the
+// returned channel delivers a patch for every deferred fragment or
+// streamed item, it is closed after the patch that has HasNext unset
+// or once ctx is done. Otherwise the channel is nil.
+func (e *Executor) ExecuteIncremental(ctx context.Context, doc *ast.Document, operationName string, variables map[string]interface{}, root interface{}) (*Result, <-chan *Patch) {
+	op, ex, res := e.prepare(ctx, doc, operationName, variables, root)
+	if res != nil {
+		return res, nil
+	}
+	ex.incremental = true
+	ex.scope = &scope{}
+	t := e.Schema.Root(op.OperationType)
+	data, ok := ex.selectionSet(t, op.SelectionSet, root, nil, op.OperationType == ast.Mutation)
+	if !ok {
+		return &Result{Data: nil, Errors: ex.errors, executed: true}, nil
+	}
+	res = &Result{Data: data, Errors: ex.errors, executed: true}
+	if !ex.more() {
+		return res, nil
+	}
+	res.HasNext = true
+	patches := make(chan *Patch)
+	go func() {
+		defer close(patches)
+		for d := ex.take(); d != nil; d = ex.take() {
+			p := &Patch{Incremental: []*Incremental{ex.run(d)}, HasNext: ex.more()}
+			select {
+			case patches <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return res, patches
+}
+
+// deferred is a fragment deferred with @defer, or an item of a list
+// streamed with @stream.
+type deferred struct {
+	label string
+	// at is the path of the object the fragment is executed on, or
+	// of the item.
+	at *path
+	// scope is the payload the fragment or item is part of.
+	scope *scope
+
+	// t is the type of source, the object the fragment set is
+	// executed on.
+	t      *ast.TypeDefinition
+	set    ast.SelectionSet
+	source interface{}
+
+	// item is an item of type elem of the list fields of parent
+	// resolved to, fields is nil for fragments.
+	parent *ast.TypeDefinition
+	elem   ast.Type
+	fields []*ast.Field
+	item   interface{}
+}
+
+// scope is a payload of an incremental response.
+type scope struct {
+	// nulled are the paths of the values that were made null while
+	// executing it, deferred fragments and items in them are dropped.
+	nulled []*path
+}
+
+// enqueue adds a deferred fragment or item to the payload that is
+// being executed.
+func (ex *execution) enqueue(d *deferred) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	d.scope = ex.scope
+	ex.pending = append(ex.pending, d)
+}
+
+// null records that the value at the given path is null.
+func (ex *execution) null(at *path) {
+	if !ex.incremental {
+		return
+	}
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.scope.nulled = append(ex.scope.nulled, at)
+}
+
+// more drops the pending fragments and items that are in values that
+// were made null and reports whether any are left.
+func (ex *execution) more() bool {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	pending := ex.pending[:0]
+	for _, d := range ex.pending {
+		if !d.dropped() {
+			pending = append(pending, d)
+		}
+	}
+	ex.pending = pending
+	return len(pending) > 0
+}
+
+// take returns the next pending fragment or item, nil if there is
+// none left.
+func (ex *execution) take() *deferred {
+	if !ex.more() {
+		return nil
+	}
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	d := ex.pending[0]
+	ex.pending = ex.pending[1:]
+	return d
+}
+
+// dropped reports whether d is in a value of its payload that was made
+// null.
+func (d *deferred) dropped() bool {
+	at := d.at.slice()
+	for _, n := range d.scope.nulled {
+		if prefix(n.slice(), at) {
+			return true
+		}
+	}
+	return false
+}
+
+func prefix(p, at []interface{}) bool {
+	if len(p) > len(at) {
+		return false
+	}
+	for i := range p {
+		if p[i] != at[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// run executes a deferred fragment or a streamed item as a payload of
+// its own.
+func (ex *execution) run(d *deferred) *Incremental {
+	ex.mu.Lock()
+	ex.errors = nil
+	ex.scope = &scope{}
+	ex.mu.Unlock()
+	inc := &Incremental{Label: d.label, Path: d.at.slice()}
+	if inc.Path == nil {
+		inc.Path = []interface{}{}
+	}
+	if d.fields != nil {
+		inc.stream = true
+		r, ok := ex.complete(d.parent, d.elem, d.fields, d.item, d.at)
+		if r == nil {
+			ex.null(d.at)
+		}
+		// items of non-null types that fail make the items null, as
+		// the list was delivered already.
+		if ok || !d.elem.NonNull() {
+			inc.Items = []interface{}{r}
+		}
+	} else {
+		data, ok := ex.selectionSet(d.t, d.set, d.source, d.at, false)
+		if ok {
+			inc.Data = data
+		} else {
+			ex.null(d.at)
+		}
+	}
+	ex.mu.Lock()
+	inc.Errors = ex.errors
+	ex.mu.Unlock()
+	return inc
+}
+
+// stream reports whether field f is streamed with @stream and returns
+// its label and initialCount.
+func (ex *execution) stream(f *ast.Field) (string, int, bool, error) {
+	d := f.Directives.Get("stream")
+	if d == nil {
+		return "", 0, false, nil
+	}
+	args := directiveArgs(ex.Schema, d, ex.variables)
+	if b, _ := args["if"].(bool); !b {
+		return "", 0, false, nil
+	}
+	n, _ := args["initialCount"].(int)
+	if n < 0 {
+		return "", 0, false, errors.New("initialCount must be a positive integer")
+	}
+	label, _ := args["label"].(string)
+	return label, n, true, nil
+}
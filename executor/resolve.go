@@ -0,0 +1,138 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DefaultResolver resolves fields that have no resolvers registered.
+// It reads the field from the source value, which can be a map with
+// string keys, or a struct, or a pointer to one. Struct fields are
+// matched by their `graphql` tag, their `json` tag or their name,
+// ignoring case. Methods with no arguments are matched by name,
+// ignoring case, and may return an error as their second result.
+func DefaultResolver(p Params) (interface{}, error) {
+	name := string(p.Field.Name)
+	v := reflect.ValueOf(p.Source)
+	if isNil(p.Source) {
+		return nil, nil
+	}
+	if m := method(v, name); m.IsValid() {
+		out := m.Call(nil)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, nil
+		}
+		e := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !e.IsValid() {
+			return nil, nil
+		}
+		return e.Interface(), nil
+	case reflect.Struct:
+		if f := structField(v, name); f.IsValid() {
+			return f.Interface(), nil
+		}
+	}
+	return nil, nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// method returns the exported method of v called name, ignoring
+// case, if it takes no arguments and returns a value, optionally
+// followed by an error.
+func method(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if !strings.EqualFold(m.Name, name) {
+			continue
+		}
+		mt := m.Type
+		// the receiver is the first argument.
+		if mt.NumIn() != 1 {
+			continue
+		}
+		switch {
+		case mt.NumOut() == 1, mt.NumOut() == 2 && mt.Out(1) == errorType:
+			return v.Method(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+func structField(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if tag := tagName(f.Tag.Get("graphql")); tag != "" {
+			if tag == name {
+				return v.Field(i)
+			}
+			continue
+		}
+		if tag := tagName(f.Tag.Get("json")); tag != "" && tag != "-" {
+			if tag == name {
+				return v.Field(i)
+			}
+			continue
+		}
+		if strings.EqualFold(f.Name, name) {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+func tagName(tag string) string {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// isNil reports whether v is nil, or a nil pointer, map, slice,
+// interface or func.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// list returns the items of a slice or an array.
+func list(v interface{}) ([]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"sevki.org/graphql/ast"
+)
+
+// Result is the response to a request as defined in
+// http://facebook.github.io/graphql/#sec-Response-Format
+type Result struct {
+	Data   interface{} `json:"data"`
+	Errors []*Error    `json:"errors,omitempty"`
+	// HasNext is set if patches with deferred fragments or streamed
+	// items follow, see ExecuteIncremental.
+	HasNext bool `json:"hasNext,omitempty"`
+	// executed is set once execution started, data is part of the
+	// response from then on, even if it is null.
+	executed bool
+}
+
+// RequestError reports whether the request failed before execution
+// started, e.g. because the document didn't validate.
+func (r *Result) RequestError() bool {
+	return !r.executed && r.Data == nil && len(r.Errors) > 0
+}
+
+// MarshalJSON leaves data out of responses to requests that failed
+// before execution started.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	if r.RequestError() {
+		return json.Marshal(struct {
+			Errors []*Error `json:"errors"`
+		}{r.Errors})
+	}
+	type result Result
+	return json.Marshal((*result)(r))
+}
+
+// Patch is a payload that follows the result of an incremental
+// response.
+type Patch struct {
+	Incremental []*Incremental `json:"incremental"`
+	// HasNext is set if more patches follow.
+	HasNext bool `json:"hasNext"`
+}
+
+// Incremental is the result of a deferred fragment, or an item of a
+// streamed list.
+type Incremental struct {
+	// Data is the result of a deferred fragment, the fields it adds
+	// to the object at Path.
+	Data interface{}
+	// Items are the items of a streamed list, starting at the index
+	// at the end of Path.
+	Items  []interface{}
+	Path   []interface{}
+	Label  string
+	Errors []*Error
+
+	stream bool
+}
+
+// MarshalJSON writes data for deferred fragments and items for streamed
+// lists, even if they are null.
+func (inc *Incremental) MarshalJSON() ([]byte, error) {
+	type common struct {
+		Path   []interface{} `json:"path"`
+		Label  string        `json:"label,omitempty"`
+		Errors []*Error      `json:"errors,omitempty"`
+	}
+	c := common{inc.Path, inc.Label, inc.Errors}
+	if inc.stream {
+		return json.Marshal(struct {
+			Items []interface{} `json:"items"`
+			common
+		}{inc.Items, c})
+	}
+	return json.Marshal(struct {
+		Data interface{} `json:"data"`
+		common
+	}{inc.Data, c})
+}
+
+// Error is an error raised while executing a request, as defined in
+// http://facebook.github.io/graphql/#sec-Errors
+//
+// Resolvers can return an *Error to add extensions to the response,
+// its locations and path are filled in by the executor.
+type Error struct {
+	Message   string         `json:"message"`
+	Locations []ast.Position `json:"locations,omitempty"`
+	// Path is the path of the field that failed, made of response
+	// keys and list indices.
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// ExtendedError is an error that carries extensions, e.g. an error
+// code, to the response.
+type ExtendedError interface {
+	error
+	Extensions() map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	if len(e.Locations) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Locations[0], e.Message)
+}
+
+// Object is a result object, its fields are in the order they were
+// selected in.
+type Object []*ObjectField
+
+// ObjectField is a field of a result object.
+type ObjectField struct {
+	Name  string
+	Value interface{}
+}
+
+// Get returns the value of field k.
+func (o Object) Get(k string) (interface{}, bool) {
+	for _, f := range o {
+		if f.Name == k {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// MarshalJSON writes the object with its fields in order.
+func (o Object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// Subscribe validates doc and subscribes to the subscription named
+// operationName, as defined in
+// http://facebook.github.io/graphql/#sec-Subscription
+//
+// The resolver of the root field of a subscription returns a channel
+// of events, e.g. a <-chan *Message, which is read until it is closed
+// or ctx is done. Every event is the value of the root field in a
+// result sent on the returned channel, events that are errors fail
+// the field. The channel is closed when the subscription ends,
+// resolvers should stop sending events and close theirs once ctx is
+// done.
+//
+// If the subscription fails before it starts, the returned channel is
+// nil and the result holds the errors.
+func (e *Executor) Subscribe(ctx context.Context, doc *ast.Document, operationName string, variables map[string]interface{}, root interface{}) (<-chan *Result, *Result) {
+	op, ex, res := e.prepare(ctx, doc, operationName, variables, root)
+	if res != nil {
+		return nil, res
+	}
+	if op.OperationType != ast.Subscription {
+		return nil, &Result{Errors: []*Error{{Message: fmt.Sprintf("Expected a subscription, got a %s.", strings.ToLower(op.OperationType.String()))}}}
+	}
+	t := e.Schema.Root(ast.Subscription)
+	fields := CollectFields(e.Schema, t, op.SelectionSet, ex.fragments, ex.variables)
+	if len(fields) != 1 {
+		return nil, &Result{Errors: []*Error{{Message: "Subscriptions must select exactly one top level field."}}}
+	}
+	key, f := fields[0].Key, fields[0].Fields[0]
+	at := &path{nil, key}
+	events, err := ex.events(t, f, at)
+	if err != nil {
+		ex.error(f, at, err)
+		return nil, &Result{Errors: ex.errors}
+	}
+
+	results := make(chan *Result)
+	go func() {
+		defer close(results)
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: events},
+		}
+		for {
+			i, v, ok := reflect.Select(cases)
+			if i == 0 || !ok {
+				return
+			}
+			res := ex.event(t, fields[0].Fields, key, v.Interface())
+			select {
+			case results <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+// events resolves the source stream of the root field f of a
+// subscription, as defined in
+// http://facebook.github.io/graphql/#ResolveFieldEventStream()
+func (ex *execution) events(t *ast.TypeDefinition, f *ast.Field, at *path) (reflect.Value, error) {
+	def := t.Field(f.Name)
+	if f.Name == "__typename" || def == nil {
+		return reflect.Value{}, fmt.Errorf("Subscription field %s.%s can't be subscribed to.", t.Name, f.Name)
+	}
+	args, err := CoerceArguments(ex.Schema, def.Arguments, f.Arguments, ex.variables)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	resolve, ok := ex.resolvers[string(t.Name)+"."+string(f.Name)]
+	if !ok {
+		resolve = DefaultResolver
+	}
+	v, err := ex.call(resolve, Params{
+		Context:    ex.ctx,
+		Source:     ex.root,
+		Args:       args,
+		Field:      f,
+		Parent:     t,
+		Definition: def,
+		Root:       ex.root,
+		Variables:  ex.variables,
+		ex:         ex,
+	})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	ch := reflect.ValueOf(v)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0 || ch.IsNil() {
+		return reflect.Value{}, fmt.Errorf("Subscription field %s.%s must resolve to a channel, got %T.", t.Name, f.Name, v)
+	}
+	return ch, nil
+}
+
+// event executes the selection set of a subscription with an event as
+// the value of its root field, as defined in
+// http://facebook.github.io/graphql/#ExecuteSubscriptionEvent()
+func (ex *execution) event(t *ast.TypeDefinition, fields []*ast.Field, key string, v interface{}) *Result {
+	// every event is executed like a request of its own.
+	ev := &execution{
+		Executor:  ex.Executor,
+		ctx:       ex.ctx,
+		fragments: ex.fragments,
+		variables: ex.variables,
+		root:      ex.root,
+		workers:   ex.workers,
+	}
+	f, at := fields[0], &path{nil, key}
+	typ := t.Field(f.Name).Type
+	var r interface{}
+	ok := !typ.NonNull()
+	if err, isErr := v.(error); isErr {
+		ev.error(f, at, err)
+	} else {
+		r, ok = ev.complete(t, typ, fields, v, at)
+		if !ok {
+			r, ok = nil, !typ.NonNull()
+		}
+	}
+	if !ok {
+		return &Result{Data: nil, Errors: ev.errors, executed: true}
+	}
+	return &Result{Data: Object{{Name: key, Value: r}}, Errors: ev.errors, executed: true}
+}
@@ -0,0 +1,126 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// ResolveTypeFunc returns the name of the object type of v, a value
+// of an interface or a union type.
+type ResolveTypeFunc func(ctx context.Context, v interface{}) (string, error)
+
+// IsTypeOfFunc reports whether v is a value of the object type it is
+// registered for.
+type IsTypeOfFunc func(ctx context.Context, v interface{}) bool
+
+// ResolveType registers fn as the function that picks the object
+// types of values of the interface or union named abstract. It panics
+// if the schema has no such type.
+func (e *Executor) ResolveType(abstract string, fn ResolveTypeFunc) {
+	if !e.Schema.IsAbstractType(ast.Type(abstract)) {
+		panic(fmt.Sprintf("executor: %s is not an interface or a union", abstract))
+	}
+	e.typeResolvers[ast.GraphQLName(abstract)] = fn
+}
+
+// IsTypeOf registers fn as the function that tells values of the
+// object type named object apart. It panics if the schema has no such
+// type.
+func (e *Executor) IsTypeOf(object string, fn IsTypeOfFunc) {
+	if t := e.Schema.Types[ast.GraphQLName(object)]; t == nil || t.Kind != ast.ObjectKind {
+		panic(fmt.Sprintf("executor: %s is not an object type", object))
+	}
+	e.isTypeOf[ast.GraphQLName(object)] = fn
+}
+
+// objectType finds the object type of v, a value of the abstract
+// type t, as defined in
+// http://facebook.github.io/graphql/#ResolveAbstractType()
+//
+// Types registered with ResolveType pick the type themselves,
+// otherwise the first possible type whose IsTypeOf function accepts v
+// is used. Values without either are typed by their __typename, as a
+// map key, struct field or method, or by the name of their Go type,
+// ignoring case.
+//
+// Type resolvers and IsTypeOf functions that panic fail the field.
+func (ex *execution) objectType(t *ast.TypeDefinition, v interface{}) (o *ast.TypeDefinition, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			o, err = nil, fmt.Errorf("Resolving the type of %s panicked: %v", t.Name, p)
+		}
+	}()
+	var name string
+	if resolve, ok := ex.typeResolvers[t.Name]; ok {
+		n, err := resolve(ex.ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		name = n
+	} else {
+		possible := ex.Schema.PossibleTypes(t)
+		for _, o := range possible {
+			if is, _ := ex.isType(o, v); is {
+				return o, nil
+			}
+		}
+		name = typename(v)
+		if name == "" {
+			rt := reflect.TypeOf(v)
+			for rt.Kind() == reflect.Ptr {
+				rt = rt.Elem()
+			}
+			for _, o := range possible {
+				if strings.EqualFold(string(o.Name), rt.Name()) {
+					return o, nil
+				}
+			}
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("Abstract type %s must resolve to an object type at runtime. Either the %s type should have a ResolveType function or each possible type should have an IsTypeOf function.", t.Name, t.Name)
+	}
+	o, ok := ex.Schema.Types[ast.GraphQLName(name)]
+	if !ok || o.Kind != ast.ObjectKind || !ex.Schema.IsPossibleType(t, o) {
+		return nil, fmt.Errorf("Runtime object type %q is not a possible type for %q.", name, t.Name)
+	}
+	return o, nil
+}
+
+// isType calls the IsTypeOf function of the object type o, if it has
+// one, ok is false if it doesn't.
+func (ex *execution) isType(o *ast.TypeDefinition, v interface{}) (is, ok bool) {
+	isTypeOf, ok := ex.isTypeOf[o.Name]
+	return ok && isTypeOf(ex.ctx, v), ok
+}
+
+// checkType makes sure v is a value of the object type o, if o has an
+// IsTypeOf function. One that panics fails the check.
+func (ex *execution) checkType(o *ast.TypeDefinition, v interface{}) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("Checking the type of %s panicked: %v", o.Name, p)
+		}
+	}()
+	if is, ok := ex.isType(o, v); ok && !is {
+		return fmt.Errorf("Expected value of type %q but got: %T.", o.Name, v)
+	}
+	return nil
+}
+
+// typename reads the __typename of v.
+func typename(v interface{}) string {
+	n, err := DefaultResolver(Params{Source: v, Field: &ast.Field{Name: "__typename"}})
+	if s, ok := n.(string); ok && err == nil {
+		return s
+	}
+	return ""
+}
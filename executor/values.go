@@ -0,0 +1,114 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package executor // import "sevki.org/graphql/executor"
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/schema"
+)
+
+// serialize is serialize for a field being completed, a scalar that
+// panics fails the field.
+func (ex *execution) serialize(t *ast.TypeDefinition, v interface{}) (r interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			r, err = nil, fmt.Errorf("Serializing %s panicked: %v", t.Name, p)
+		}
+	}()
+	return serialize(ex.Schema, t, v)
+}
+
+// serialize turns v into a value of the scalar or enum type t, as
+// defined in http://facebook.github.io/graphql/#sec-Scalars
+func serialize(s *schema.Schema, t *ast.TypeDefinition, v interface{}) (interface{}, error) {
+	if sc, ok := s.Scalars[t.Name]; ok {
+		return sc.Serialize(v)
+	}
+	// Stringers may have pointer receivers.
+	stringer, isStringer := v.(fmt.Stringer)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		// nil pointers were completed as null already.
+		rv = rv.Elem()
+		v = rv.Interface()
+	}
+	if t.Kind == ast.EnumKind {
+		s := fmt.Sprint(v)
+		if isStringer {
+			s = stringer.String()
+		}
+		if t.EnumValue(ast.GraphQLName(s)) == nil {
+			return nil, fmt.Errorf("Enum %q cannot represent value: %v", t.Name, v)
+		}
+		return s, nil
+	}
+	switch t.Name {
+	case "Int":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := rv.Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n := rv.Uint(); n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+				return int(f), nil
+			}
+		case reflect.Bool:
+			if rv.Bool() {
+				return 1, nil
+			}
+			return 0, nil
+		}
+		return nil, fmt.Errorf("Int cannot represent value: %v", v)
+	case "Float":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("Float cannot represent value: %v", v)
+	case "String", "ID":
+		switch rv.Kind() {
+		case reflect.String:
+			return rv.String(), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return strconv.FormatUint(rv.Uint(), 10), nil
+		case reflect.Bool:
+			if t.Name == "String" {
+				return strconv.FormatBool(rv.Bool()), nil
+			}
+		case reflect.Float32, reflect.Float64:
+			if t.Name == "String" {
+				return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+			}
+		}
+		if isStringer {
+			return stringer.String(), nil
+		}
+		return nil, fmt.Errorf("%s cannot represent value: %v", t.Name, v)
+	case "Boolean":
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+		return nil, fmt.Errorf("Boolean cannot represent value: %v", v)
+	}
+	return v, nil
+}
@@ -0,0 +1,394 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package handler serves GraphQL over HTTP, as described in
+// https://graphql.github.io/graphql-over-http/draft/
+package handler // import "sevki.org/graphql/handler"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/parser"
+)
+
+// Media types of responses.
+const (
+	GraphQLResponse = "application/graphql-response+json"
+	JSON            = "application/json"
+)
+
+// DefaultMaxBodyBytes is the largest request body handlers read if
+// MaxBodyBytes isn't set.
+const DefaultMaxBodyBytes = 1 << 20
+
+// DefaultMaxBatchSize is the largest batch handlers accept if
+// MaxBatchSize isn't set.
+const DefaultMaxBatchSize = 10
+
+// Handler executes the GraphQL requests it is sent with Executor.
+//
+// It accepts GET requests with the parameters in the query string and
+// POST requests with application/json bodies, or application/graphql
+// bodies that hold the query with the other parameters in the query
+// string. Mutations can't be sent with GET.
+//
+// Responses are application/graphql-response+json, unless the client
+// only accepts application/json. Requests that fail before execution
+// starts get a 400 Bad Request with the former, and a 200 OK with the
+// latter, as it is what older clients expect.
+//
+// Files can be sent with multipart/form-data requests, see Upload.
+//
+// Subscriptions, as well as queries and mutations, can be sent over
+// WebSocket connections that speak the graphql-transport-ws protocol,
+// see Protocol.
+//
+// Clients that accept text/event-stream get the results of an
+// operation as Server-Sent Events, see EventStream.
+//
+// Clients that accept multipart/mixed get the fragments deferred with
+// @defer and the items streamed with @stream in parts that follow the
+// result, see Mixed. Clients that accept nothing else get a single
+// part if nothing was deferred.
+//
+// A JSON array of requests can be POSTed as a batch. Its operations are
+// executed concurrently and the response is an array of their results
+// in the same order, with a 200 OK as every result has its own errors.
+// Batches of clients that only accept multipart/mixed are rejected.
+type Handler struct {
+	Executor *executor.Executor
+	// Root returns the value of the root type for a request, if it is
+	// nil the root value is an empty struct.
+	Root func(r *http.Request) interface{}
+	// MaxBodyBytes limits the size of request bodies, to
+	// DefaultMaxBodyBytes if it is 0.
+	MaxBodyBytes int64
+	// MaxBatchSize limits the number of operations in a batch, to
+	// DefaultMaxBatchSize if it is 0. Batches are rejected if it is
+	// negative.
+	MaxBatchSize int
+	// BatchConcurrency limits how many operations of a batch are
+	// executed at the same time, all of them are if it is 0.
+	BatchConcurrency int
+	// MaxUploadBytes limits the size of multipart requests, to
+	// DefaultMaxUploadBytes if it is 0.
+	MaxUploadBytes int64
+	// UploadMemoryBytes is how much of the files of a multipart
+	// request are kept in memory, DefaultUploadMemoryBytes if it is
+	// 0, the rest is written to temporary files.
+	UploadMemoryBytes int64
+	// InitTimeout is how long WebSocket clients have to initialise
+	// the connection, DefaultInitTimeout if it is 0.
+	InitTimeout time.Duration
+	// InitWebSocket is called with the payload of the connection_init
+	// message of WebSocket clients, if it fails the connection is
+	// closed with 4403 Forbidden.
+	InitWebSocket func(r *http.Request, payload map[string]interface{}) error
+	// CheckOrigin reports whether WebSocket connections from the
+	// origin of r are allowed, if it is nil only connections from the
+	// same host are.
+	CheckOrigin func(r *http.Request) bool
+	// KeepAlive is how often comments are sent on event streams to
+	// keep them open, DefaultKeepAlive if it is 0 and never if it is
+	// negative.
+	KeepAlive time.Duration
+}
+
+// New returns a handler that executes requests with e.
+func New(e *executor.Executor) *Handler {
+	return &Handler{Executor: e}
+}
+
+// params are the parameters of a request.
+type params struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// requestError fails a request before it is executed.
+type requestError struct {
+	status int
+	msg    string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...interface{}) *requestError {
+	return &requestError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r)
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		w.Header().Set("Allow", "GET, POST")
+		fail(w, JSON, &requestError{http.StatusMethodNotAllowed, fmt.Sprintf("%s is not supported, use GET or POST.", r.Method)})
+		return
+	}
+	if acceptsEvents(r.Header.Get("Accept")) {
+		h.serveEvents(w, r)
+		return
+	}
+	mediaType := accepts(r.Header.Get("Accept"))
+	mixed := acceptsMixed(r.Header.Get("Accept"))
+	// clients that only accept multipart/mixed get every result in
+	// a part, even if nothing follows it.
+	onlyMixed := mediaType == "" && mixed
+	if mediaType == "" {
+		if !mixed {
+			fail(w, JSON, &requestError{http.StatusNotAcceptable, fmt.Sprintf("Responses are %s, %s or %s.", GraphQLResponse, JSON, Mixed)})
+			return
+		}
+		// request errors are still answered with JSON.
+		mediaType = GraphQLResponse
+	}
+	ps, batch, err := h.params(r)
+	defer closeUploads(r, ps)
+	if err != nil {
+		fail(w, mediaType, err)
+		return
+	}
+	if batch {
+		if onlyMixed {
+			// the results of a batch are a single JSON array.
+			fail(w, JSON, &requestError{http.StatusNotAcceptable, fmt.Sprintf("Batches are answered with %s or %s.", GraphQLResponse, JSON)})
+			return
+		}
+		write(w, mediaType, http.StatusOK, h.executeBatch(r, ps))
+		return
+	}
+	res, patches, err := h.execute(r, ps[0], mixed)
+	if err != nil {
+		if r.Method == "GET" && err.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", "POST")
+		}
+		fail(w, mediaType, err)
+		return
+	}
+	if patches != nil || onlyMixed {
+		writeMixed(w, res, patches)
+		return
+	}
+	status := http.StatusOK
+	if res.RequestError() && mediaType == GraphQLResponse {
+		status = http.StatusBadRequest
+	}
+	write(w, mediaType, status, res)
+}
+
+// executeBatch executes the operations of a batch, at most
+// BatchConcurrency at a time, and returns their results in order.
+func (h *Handler) executeBatch(r *http.Request, ps []*params) []*executor.Result {
+	n := h.BatchConcurrency
+	if n <= 0 || n > len(ps) {
+		n = len(ps)
+	}
+	results := make([]*executor.Result, len(ps))
+	tokens := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, p := range ps {
+		wg.Add(1)
+		tokens <- struct{}{}
+		go func(i int, p *params) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			res, _, err := h.execute(r, p, false)
+			if err != nil {
+				res = &executor.Result{Errors: []*executor.Error{{Message: err.msg}}}
+			}
+			results[i] = res
+		}(i, p)
+	}
+	wg.Wait()
+	return results
+}
+
+// execute parses and executes a request, if incremental is set
+// deferred fragments and streamed items are delivered as patches.
+func (h *Handler) execute(r *http.Request, p *params, incremental bool) (*executor.Result, <-chan *executor.Patch, *requestError) {
+	if p.Query == "" {
+		return nil, nil, badRequest("Must provide query string.")
+	}
+	doc, err := parser.NewQuery([]byte(p.Query))
+	if err != nil {
+		return &executor.Result{Errors: []*executor.Error{{Message: fmt.Sprintf("Syntax Error: %v", err)}}}, nil, nil
+	}
+	if r.Method == "GET" {
+		op, err := executor.Operation(doc, p.OperationName)
+		if err != nil {
+			return &executor.Result{Errors: []*executor.Error{err}}, nil, nil
+		}
+		if op.OperationType != ast.Query {
+			return nil, nil, &requestError{http.StatusMethodNotAllowed, fmt.Sprintf("Can only perform a %s operation from a POST request.", strings.ToLower(op.OperationType.String()))}
+		}
+	}
+	if incremental {
+		res, patches := h.Executor.ExecuteIncremental(r.Context(), doc, p.OperationName, p.Variables, h.root(r))
+		return res, patches, nil
+	}
+	return h.Executor.ExecuteContext(r.Context(), doc, p.OperationName, p.Variables, h.root(r)), nil, nil
+}
+
+// root returns the root value for a request.
+func (h *Handler) root(r *http.Request) interface{} {
+	if h.Root == nil {
+		return struct{}{}
+	}
+	return h.Root(r)
+}
+
+// params reads the parameters of a request, or of the operations of a
+// batch.
+func (h *Handler) params(r *http.Request) ([]*params, bool, *requestError) {
+	p := &params{}
+	q := r.URL.Query()
+	if r.Method == "GET" {
+		p.Query = q.Get("query")
+		p.OperationName = q.Get("operationName")
+		if err := decodeParam(q.Get("variables"), &p.Variables); err != nil {
+			return nil, false, badRequest("Variables are invalid JSON.")
+		}
+		if err := decodeParam(q.Get("extensions"), &p.Extensions); err != nil {
+			return nil, false, badRequest("Extensions are invalid JSON.")
+		}
+		return []*params{p}, false, nil
+	}
+
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		ct = ""
+	}
+	if ct == "multipart/form-data" {
+		return h.multipart(r)
+	}
+	max := h.MaxBodyBytes
+	if max == 0 {
+		max = DefaultMaxBodyBytes
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, max))
+	if err != nil {
+		return nil, false, &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Request bodies are limited to %d bytes.", max)}
+	}
+	switch ct {
+	case JSON:
+		return h.operations(body)
+	case "application/graphql":
+		p.Query = string(body)
+		p.OperationName = q.Get("operationName")
+		if err := decodeParam(q.Get("variables"), &p.Variables); err != nil {
+			return nil, false, badRequest("Variables are invalid JSON.")
+		}
+	default:
+		return nil, false, &requestError{http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be %s, application/graphql or multipart/form-data.", JSON)}
+	}
+	return []*params{p}, false, nil
+}
+
+// operations decodes a JSON request, or the operations of a batch.
+func (h *Handler) operations(body []byte) ([]*params, bool, *requestError) {
+	if b := bytes.TrimSpace(body); len(b) > 0 && b[0] == '[' {
+		ps, err := h.batch(b)
+		return ps, true, err
+	}
+	p := &params{}
+	if err := json.Unmarshal(body, p); err != nil {
+		return nil, false, badRequest("POST body sent invalid JSON.")
+	}
+	return []*params{p}, false, nil
+}
+
+// batch decodes the operations of a batch.
+func (h *Handler) batch(body []byte) ([]*params, *requestError) {
+	max := h.MaxBatchSize
+	if max == 0 {
+		max = DefaultMaxBatchSize
+	}
+	if max < 0 {
+		return nil, badRequest("Batching is not supported.")
+	}
+	var ps []*params
+	if err := json.Unmarshal(body, &ps); err != nil {
+		return nil, badRequest("POST body sent invalid JSON.")
+	}
+	switch {
+	case len(ps) == 0:
+		return nil, badRequest("Batches must have at least one operation.")
+	case len(ps) > max:
+		return nil, badRequest("Batches are limited to %d operations.", max)
+	}
+	for i, p := range ps {
+		if p == nil {
+			ps[i] = &params{}
+		}
+	}
+	return ps, nil
+}
+
+func decodeParam(s string, v interface{}) error {
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+// accepts returns the media type to respond with to a request with the
+// Accept header accept, or "" if the client accepts neither.
+func accepts(accept string) string {
+	if accept == "" {
+		return GraphQLResponse
+	}
+	plain := false
+	for _, r := range strings.Split(accept, ",") {
+		mt, ps, err := mime.ParseMediaType(strings.TrimSpace(r))
+		if err != nil || ps["q"] == "0" {
+			continue
+		}
+		switch mt {
+		case GraphQLResponse, "*/*", "application/*":
+			return GraphQLResponse
+		case JSON:
+			plain = true
+		}
+	}
+	if plain {
+		return JSON
+	}
+	return ""
+}
+
+func fail(w http.ResponseWriter, mediaType string, err *requestError) {
+	write(w, mediaType, err.status, &executor.Result{Errors: []*executor.Error{{Message: err.msg}}})
+}
+
+// write writes a result, or the results of a batch.
+func write(w http.ResponseWriter, mediaType string, status int, res interface{}) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b)
+}
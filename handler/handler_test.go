@@ -0,0 +1,745 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler // import "sevki.org/graphql/handler"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/schema"
+)
+
+const sdl = `
+type Query { hello(name: String = "world"): String! }
+type Mutation { bump: Int! }
+type Subscription { count(to: Int!): Int! }
+`
+
+func testHandler(t *testing.T) *Handler {
+	s, err := schema.Parse("test", strings.NewReader(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := executor.New(s)
+	e.Resolve("Query.hello", func(p executor.Params) (interface{}, error) {
+		return "hello " + p.Args["name"].(string), nil
+	})
+	var n int32
+	e.Resolve("Mutation.bump", func(p executor.Params) (interface{}, error) {
+		return int(atomic.AddInt32(&n, 1)), nil
+	})
+	// count counts forever if to is negative.
+	e.Resolve("Subscription.count", func(p executor.Params) (interface{}, error) {
+		ch := make(chan int)
+		go func() {
+			defer close(ch)
+			for i := 1; i <= p.Args["to"].(int) || p.Args["to"].(int) < 0; i++ {
+				select {
+				case ch <- i:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	})
+	return New(e)
+}
+
+func testServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(testHandler(t))
+}
+
+func TestHandler(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	get := func(q url.Values) *http.Request {
+		r, _ := http.NewRequest("GET", srv.URL+"?"+q.Encode(), nil)
+		return r
+	}
+	post := func(ct, body string, q url.Values) *http.Request {
+		r, _ := http.NewRequest("POST", srv.URL+"?"+q.Encode(), strings.NewReader(body))
+		r.Header.Set("Content-Type", ct)
+		return r
+	}
+	withAccept := func(r *http.Request, accept string) *http.Request {
+		r.Header.Set("Accept", accept)
+		return r
+	}
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		ct     string
+		body   string
+	}{
+		{
+			"get",
+			get(url.Values{"query": {"query ($n: String) { hello(name: $n) }"}, "variables": {`{"n":"ann"}`}}),
+			200, GraphQLResponse,
+			`{"data":{"hello":"hello ann"}}`,
+		},
+		{
+			"post json",
+			post("application/json; charset=utf-8", `{"query":"query A { hello } mutation B { bump }","operationName":"B"}`, nil),
+			200, GraphQLResponse,
+			`{"data":{"bump":1}}`,
+		},
+		{
+			"post graphql",
+			post("application/graphql", `query ($n: String) { hello(name: $n) }`, url.Values{"variables": {`{"n":"bob"}`}}),
+			200, GraphQLResponse,
+			`{"data":{"hello":"hello bob"}}`,
+		},
+		{
+			"mutation over get",
+			get(url.Values{"query": {"mutation { bump }"}}),
+			405, GraphQLResponse,
+			`{"errors":[{"message":"Can only perform a mutation operation from a POST request."}]}`,
+		},
+		{
+			"validation error",
+			get(url.Values{"query": {"{ nope }"}}),
+			400, GraphQLResponse,
+			`{"errors":[{"message":"Cannot query field \"nope\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			"validation error as json",
+			withAccept(get(url.Values{"query": {"{ nope }"}}), "application/json"),
+			200, JSON,
+			`{"errors":[{"message":"Cannot query field \"nope\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			"no query",
+			get(url.Values{}),
+			400, GraphQLResponse,
+			`{"errors":[{"message":"Must provide query string."}]}`,
+		},
+		{
+			"bad json",
+			post("application/json", `{"query":`, nil),
+			400, GraphQLResponse,
+			`{"errors":[{"message":"POST body sent invalid JSON."}]}`,
+		},
+		{
+			"bad variables",
+			get(url.Values{"query": {"{ hello }"}, "variables": {"nope"}}),
+			400, GraphQLResponse,
+			`{"errors":[{"message":"Variables are invalid JSON."}]}`,
+		},
+		{
+			"unsupported media type",
+			post("text/plain", `{ hello }`, nil),
+			415, GraphQLResponse,
+			`{"errors":[{"message":"Content-Type must be application/json, application/graphql or multipart/form-data."}]}`,
+		},
+		{
+			"not acceptable",
+			withAccept(get(url.Values{"query": {"{ hello }"}}), "text/html"),
+			406, JSON,
+			`{"errors":[{"message":"Responses are application/graphql-response+json, application/json or multipart/mixed."}]}`,
+		},
+		{
+			"wildcard",
+			withAccept(get(url.Values{"query": {"{ hello }"}}), "text/html, */*;q=0.8"),
+			200, GraphQLResponse,
+			`{"data":{"hello":"hello world"}}`,
+		},
+	}
+	for _, test := range tests {
+		resp, err := http.DefaultClient.Do(test.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status is %d, expected %d", test.name, resp.StatusCode, test.status)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != test.ct+"; charset=utf-8" {
+			t.Errorf("%s: content type is %q, expected %q", test.name, ct, test.ct)
+		}
+		if string(b) != test.body {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", test.name, b, test.body)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "?query=mutation+%7B+bump+%7D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Allow") != "POST" {
+		t.Errorf("mutation over GET allowed %q", resp.Header.Get("Allow"))
+	}
+	r, _ := http.NewRequest("PUT", srv.URL, nil)
+	resp, err = http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 405 || resp.Header.Get("Allow") != "GET, POST" {
+		t.Errorf("PUT got %s, allowed %q", resp.Status, resp.Header.Get("Allow"))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	h := testHandler(t)
+	h.MaxBatchSize = 3
+	h.BatchConcurrency = 2
+	var running, most int32
+	h.Executor.Resolve("Query.hello", func(p executor.Params) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "hello " + p.Args["name"].(string), nil
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, test := range []struct {
+		name, body string
+		status     int
+		result     string
+	}{
+		{
+			"batch",
+			`[{"query":"{ hello }"}, {"query":"query ($n: String) { hello(name: $n) }","variables":{"n":"ann"}}, {"query":"{ nope }"}]`,
+			200,
+			`[{"data":{"hello":"hello world"}},{"data":{"hello":"hello ann"}},{"errors":[{"message":"Cannot query field \"nope\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}]`,
+		},
+		{
+			"missing query",
+			` [{"query":"{ hello }"}, {}]`,
+			200,
+			`[{"data":{"hello":"hello world"}},{"errors":[{"message":"Must provide query string."}]}]`,
+		},
+		{
+			"too large",
+			`[{"query":"{ hello }"}, {"query":"{ hello }"}, {"query":"{ hello }"}, {"query":"{ hello }"}]`,
+			400,
+			`{"errors":[{"message":"Batches are limited to 3 operations."}]}`,
+		},
+		{
+			"empty",
+			`[]`,
+			400,
+			`{"errors":[{"message":"Batches must have at least one operation."}]}`,
+		},
+	} {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status is %d, expected %d", test.name, resp.StatusCode, test.status)
+		}
+		if string(b) != test.result {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", test.name, b, test.result)
+		}
+	}
+	if most != 2 {
+		t.Errorf("%d operations ran at the same time, expected 2", most)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(`[{"query":"{ hello }"}]`))
+	req.Header.Set("Content-Type", JSON)
+	req.Header.Set("Accept", Mixed)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := `{"errors":[{"message":"Batches are answered with application/graphql-response+json or application/json."}]}`; resp.StatusCode != 406 || string(b) != want {
+		t.Errorf("batch accepting only %s got %s %s", Mixed, resp.Status, b)
+	}
+
+	h.MaxBatchSize = -1
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader(`[{"query":"{ hello }"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("batches should be rejected, got %s", resp.Status)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	s, err := schema.Parse("test", strings.NewReader(`
+scalar Upload
+type Query { hello: String }
+type Mutation { upload(file: Upload!): String! uploads(files: [Upload!]!): [String!]! }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetScalar("Upload", UploadScalar{}); err != nil {
+		t.Fatal(err)
+	}
+	read := func(u *Upload) string {
+		b, _ := ioutil.ReadAll(u.File)
+		return fmt.Sprintf("%s %s %d %s", u.Filename, u.ContentType, u.Size, b)
+	}
+	e := executor.New(s)
+	e.Resolve("Mutation.upload", func(p executor.Params) (interface{}, error) {
+		return read(p.Args["file"].(*Upload)), nil
+	})
+	e.Resolve("Mutation.uploads", func(p executor.Params) (interface{}, error) {
+		var names []interface{}
+		for _, f := range p.Args["files"].([]interface{}) {
+			names = append(names, read(f.(*Upload)))
+		}
+		return names, nil
+	})
+	h := New(e)
+	h.UploadMemoryBytes = 4
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	type file struct{ key, name, content string }
+	for _, test := range []struct {
+		name, operations, m string
+		files               []file
+		status              int
+		result              string
+	}{
+		{
+			"single",
+			`{"query":"mutation ($f: Upload!) { upload(file: $f) }","variables":{"f":null}}`,
+			`{"0":["variables.f"]}`,
+			[]file{{"0", "a.txt", "hello"}},
+			200,
+			`{"data":{"upload":"a.txt text/plain 5 hello"}}`,
+		},
+		{
+			"list",
+			`{"query":"mutation ($f: [Upload!]!) { uploads(files: $f) }","variables":{"f":[null,null]}}`,
+			`{"0":["variables.f.0"],"1":["variables.f.1"]}`,
+			[]file{{"0", "a.txt", "a"}, {"1", "b.txt", "bb"}},
+			200,
+			`{"data":{"uploads":["a.txt text/plain 1 a","b.txt text/plain 2 bb"]}}`,
+		},
+		{
+			"batch",
+			`[{"query":"mutation ($f: Upload!) { upload(file: $f) }","variables":{"f":null}},{"query":"mutation ($f: Upload!) { upload(file: $f) }","variables":{"f":null}}]`,
+			`{"x":["0.variables.f","1.variables.f"]}`,
+			[]file{{"x", "x.txt", "x"}},
+			200,
+			`[{"data":{"upload":"x.txt text/plain 1 x"}},{"data":{"upload":"x.txt text/plain 1 x"}}]`,
+		},
+		{
+			"missing file",
+			`{"query":"mutation ($f: Upload!) { upload(file: $f) }","variables":{"f":null}}`,
+			`{"0":["variables.f"]}`,
+			nil,
+			400,
+			`{"errors":[{"message":"File \"0\" is missing in the request."}]}`,
+		},
+		{
+			"invalid path",
+			`{"query":"mutation ($f: Upload!) { upload(file: $f) }","variables":{"f":null}}`,
+			`{"0":["variables.g"]}`,
+			[]file{{"0", "a.txt", "a"}},
+			400,
+			`{"errors":[{"message":"Invalid file path \"variables.g\": no g."}]}`,
+		},
+		{
+			"missing map",
+			`{"query":"{ hello }"}`,
+			"",
+			nil,
+			400,
+			`{"errors":[{"message":"Missing multipart field \"map\"."}]}`,
+		},
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("operations", test.operations)
+		if test.m != "" {
+			mw.WriteField("map", test.m)
+		}
+		for _, f := range test.files {
+			hdr := make(textproto.MIMEHeader)
+			hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, f.key, f.name))
+			hdr.Set("Content-Type", "text/plain")
+			w, _ := mw.CreatePart(hdr)
+			w.Write([]byte(f.content))
+		}
+		mw.Close()
+		resp, err := http.Post(srv.URL, mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status is %d, expected %d", test.name, resp.StatusCode, test.status)
+		}
+		if string(b) != test.result {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", test.name, b, test.result)
+		}
+	}
+
+	h.MaxUploadBytes = 1024
+	for _, test := range []struct {
+		name, content string
+		status        int
+		result        string
+	}{
+		{"small", strings.Repeat("x", 10), 200, `{"data":{"upload":"f.txt text/plain 10 xxxxxxxxxx"}}`},
+		{"large", strings.Repeat("x", 2048), 413, `{"errors":[{"message":"Multipart requests are limited to 1024 bytes."}]}`},
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("operations", `{"query":"mutation ($f: Upload!) { upload(file: $f) }","variables":{"f":null}}`)
+		mw.WriteField("map", `{"0":["variables.f"]}`)
+		hdr := make(textproto.MIMEHeader)
+		hdr.Set("Content-Disposition", `form-data; name="0"; filename="f.txt"`)
+		hdr.Set("Content-Type", "text/plain")
+		w, _ := mw.CreatePart(hdr)
+		w.Write([]byte(test.content))
+		mw.Close()
+		resp, err := http.Post(srv.URL, mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status is %d, expected %d", test.name, resp.StatusCode, test.status)
+		}
+		if string(b) != test.result {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", test.name, b, test.result)
+		}
+	}
+	resp, err := http.Post(srv.URL, "multipart/form-data; boundary=x", strings.NewReader(strings.Repeat("x", 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("invalid upload got %s, expected 400", resp.Status)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	h := testHandler(t)
+	h.InitTimeout = 50 * time.Millisecond
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	addr := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dial := func(protocols ...string) *websocket.Conn {
+		d := websocket.Dialer{Subprotocols: protocols}
+		c, _, err := d.Dial(addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	send := func(c *websocket.Conn, m string) {
+		if err := c.WriteMessage(websocket.TextMessage, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(c *websocket.Conn) string {
+		_, b, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	closed := func(c *websocket.Conn, code int) {
+		defer c.Close()
+		for {
+			_, _, err := c.ReadMessage()
+			if err == nil {
+				continue
+			}
+			if !websocket.IsCloseError(err, code) {
+				t.Errorf("connection closed with %v, expected %d", err, code)
+			}
+			return
+		}
+	}
+
+	c := dial(Protocol)
+	send(c, `{"type":"connection_init","payload":{"token":"x"}}`)
+	if m := read(c); m != `{"type":"connection_ack"}` {
+		t.Fatalf("got %s, expected connection_ack", m)
+	}
+	send(c, `{"type":"ping"}`)
+	if m := read(c); m != `{"type":"pong"}` {
+		t.Errorf("got %s, expected pong", m)
+	}
+	for _, test := range []struct {
+		subscribe string
+		messages  []string
+	}{
+		{
+			`{"id":"1","type":"subscribe","payload":{"query":"subscription ($n: Int!) { count(to: $n) }","variables":{"n":2}}}`,
+			[]string{
+				`{"id":"1","type":"next","payload":{"data":{"count":1}}}`,
+				`{"id":"1","type":"next","payload":{"data":{"count":2}}}`,
+				`{"id":"1","type":"complete"}`,
+			},
+		},
+		{
+			`{"id":"2","type":"subscribe","payload":{"query":"{ hello }"}}`,
+			[]string{
+				`{"id":"2","type":"next","payload":{"data":{"hello":"hello world"}}}`,
+				`{"id":"2","type":"complete"}`,
+			},
+		},
+		{
+			`{"id":"3","type":"subscribe","payload":{"query":"{ nope }"}}`,
+			[]string{
+				`{"id":"3","type":"error","payload":[{"message":"Cannot query field \"nope\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}`,
+			},
+		},
+	} {
+		send(c, test.subscribe)
+		for _, want := range test.messages {
+			if got := read(c); got != want {
+				t.Errorf("got:  %s\nwant: %s", got, want)
+			}
+		}
+	}
+
+	// operations the client completes send nothing more.
+	send(c, `{"id":"4","type":"subscribe","payload":{"query":"subscription { count(to: -1) }"}}`)
+	if m := read(c); m != `{"id":"4","type":"next","payload":{"data":{"count":1}}}` {
+		t.Errorf("got %s, expected the first count", m)
+	}
+	send(c, `{"id":"4","type":"complete"}`)
+	send(c, `{"type":"ping"}`)
+	for m := read(c); m != `{"type":"pong"}`; m = read(c) {
+		if !strings.Contains(m, `"type":"next"`) {
+			t.Errorf("got %s after completing", m)
+		}
+	}
+	send(c, `{"id":"5","type":"subscribe","payload":{"query":"subscription { count(to: -1) }"}}`)
+	read(c)
+	send(c, `{"id":"5","type":"subscribe","payload":{"query":"{ hello }"}}`)
+	closed(c, 4409)
+
+	for _, test := range []struct {
+		name     string
+		protocol string
+		messages []string
+		code     int
+	}{
+		{"unauthorized", Protocol, []string{`{"id":"1","type":"subscribe","payload":{"query":"{ hello }"}}`}, 4401},
+		{"init twice", Protocol, []string{`{"type":"connection_init"}`, `{"type":"connection_init"}`}, 4429},
+		{"invalid", Protocol, []string{`{"id":1}`}, 4400},
+		{"timeout", Protocol, nil, 4408},
+		{"subprotocol", "graphql-ws", nil, 4406},
+	} {
+		c := dial(test.protocol)
+		for _, m := range test.messages {
+			send(c, m)
+		}
+		t.Run(test.name, func(t *testing.T) {
+			closed(c, test.code)
+		})
+	}
+
+	h.InitWebSocket = func(r *http.Request, payload map[string]interface{}) error {
+		if payload["token"] != "secret" {
+			return fmt.Errorf("no")
+		}
+		return nil
+	}
+	c = dial(Protocol)
+	send(c, `{"type":"connection_init","payload":{"token":"x"}}`)
+	closed(c, 4403)
+}
+
+func TestEvents(t *testing.T) {
+	h := testHandler(t)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, test := range []struct {
+		name   string
+		query  string
+		last   string
+		status int
+		body   string
+	}{
+		{
+			"subscription",
+			"subscription { count(to: 2) }", "",
+			200,
+			"id: 1\nevent: next\ndata: {\"data\":{\"count\":1}}\n\nid: 2\nevent: next\ndata: {\"data\":{\"count\":2}}\n\nevent: complete\ndata:\n\n",
+		},
+		{
+			"reconnect",
+			"subscription { count(to: 1) }", "41",
+			200,
+			"id: 42\nevent: next\ndata: {\"data\":{\"count\":1}}\n\nevent: complete\ndata:\n\n",
+		},
+		{
+			"query",
+			"{ hello }", "",
+			200,
+			"id: 1\nevent: next\ndata: {\"data\":{\"hello\":\"hello world\"}}\n\nevent: complete\ndata:\n\n",
+		},
+		{
+			"invalid",
+			"subscription { count }", "",
+			400,
+			`{"errors":[{"message":"Field \"count\" argument \"to\" of type \"Int!\" is required, but it was not provided.","locations":[{"line":1,"column":16}]}]}`,
+		},
+		{
+			"mutation",
+			"mutation { bump }", "",
+			405,
+			`{"errors":[{"message":"Can only perform a mutation operation from a POST request."}]}`,
+		},
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+"?"+url.Values{"query": {test.query}}.Encode(), nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if test.last != "" {
+			req.Header.Set("Last-Event-ID", test.last)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status is %d, expected %d", test.name, resp.StatusCode, test.status)
+		}
+		if string(b) != test.body {
+			t.Errorf("%s:\ngot:  %q\nwant: %q", test.name, b, test.body)
+		}
+	}
+
+	// streams are kept open until the client disconnects.
+	h.KeepAlive = 10 * time.Millisecond
+	done := make(chan int, 1)
+	h.Executor.Resolve("Subscription.count", func(p executor.Params) (interface{}, error) {
+		ch := make(chan int)
+		go func() {
+			<-p.Context.Done()
+			id, _ := LastEventID(p.Context)
+			done <- id
+			close(ch)
+		}()
+		return ch, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(`{"query":"subscription { count(to: 1) }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "7")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream; charset=utf-8" {
+		t.Errorf("Content-Type is %q", ct)
+	}
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(resp.Body, b); err != nil || string(b) != ":\n\n" {
+		t.Errorf("got %q, %v, expected a keepalive comment", b, err)
+	}
+	cancel()
+	select {
+	case id := <-done:
+		if id != 7 {
+			t.Errorf("LastEventID is %d, expected 7", id)
+		}
+	case <-time.After(time.Second):
+		t.Error("the subscription didn't end when the client disconnected")
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	for _, test := range []struct {
+		name, query, accept string
+		ct, body            string
+	}{
+		{
+			"defer",
+			`{ a: hello ... @defer(label: "b") { b: hello(name: "b") } }`,
+			"multipart/mixed; deferSpec=20220824, application/json",
+			`multipart/mixed; boundary="-"; deferSpec=20220824`,
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" + `{"data":{"a":"hello world"},"hasNext":true}` +
+				"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" + `{"incremental":[{"data":{"b":"hello b"},"path":[],"label":"b"}],"hasNext":false}` +
+				"\r\n-----\r\n",
+		},
+		{
+			"nothing deferred",
+			`{ hello }`,
+			"multipart/mixed",
+			`multipart/mixed; boundary="-"; deferSpec=20220824`,
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n" + `{"data":{"hello":"hello world"}}` +
+				"\r\n-----\r\n",
+		},
+		{
+			"nothing deferred with JSON",
+			`{ hello }`,
+			"multipart/mixed, application/graphql-response+json",
+			GraphQLResponse + "; charset=utf-8",
+			`{"data":{"hello":"hello world"}}`,
+		},
+		{
+			"not accepted",
+			`{ a: hello ... @defer { b: hello(name: "b") } }`,
+			JSON,
+			JSON + "; charset=utf-8",
+			`{"data":{"a":"hello world","b":"hello b"}}`,
+		},
+	} {
+		req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(fmt.Sprintf(`{"query":%q}`, test.query)))
+		req.Header.Set("Content-Type", JSON)
+		req.Header.Set("Accept", test.accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != test.ct {
+			t.Errorf("%s: Content-Type is %q, expected %q", test.name, ct, test.ct)
+		}
+		if string(b) != test.body {
+			t.Errorf("%s:\ngot:  %q\nwant: %q", test.name, b, test.body)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler // import "sevki.org/graphql/handler"
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"sevki.org/graphql/executor"
+)
+
+// Mixed is the media type of incremental responses, every part holds
+// the result or a patch that follows it, see
+// executor.ExecuteIncremental. Parts are delimited by "---", as
+// described in
+// https://github.com/graphql/graphql-over-http/blob/main/rfcs/IncrementalDelivery.md
+const Mixed = "multipart/mixed"
+
+// acceptsMixed reports whether a request with the Accept header accept
+// asks for an incremental response.
+func acceptsMixed(accept string) bool {
+	for _, r := range strings.Split(accept, ",") {
+		mt, ps, err := mime.ParseMediaType(strings.TrimSpace(r))
+		if err == nil && mt == Mixed && ps["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeMixed writes an incremental response, it flushes every part
+// as soon as it is written. The response is a single part if patches
+// is nil.
+func writeMixed(w http.ResponseWriter, res interface{}, patches <-chan *executor.Patch) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", Mixed+`; boundary="-"; deferSpec=20220824`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	part := func(v interface{}) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, _ = json.Marshal(&executor.Result{Errors: []*executor.Error{{Message: err.Error()}}})
+		}
+		fmt.Fprintf(w, "\r\n---\r\nContent-Type: %s; charset=utf-8\r\n\r\n%s", JSON, b)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	part(res)
+	if patches != nil {
+		// patches is closed when the client disconnects.
+		for p := range patches {
+			part(p)
+		}
+	}
+	fmt.Fprint(w, "\r\n-----\r\n")
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler // import "sevki.org/graphql/handler"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/parser"
+)
+
+// EventStream is the media type of responses that stream results as
+// Server-Sent Events, as described in the distinct connections mode
+// of
+// https://github.com/enisdenjo/graphql-sse/blob/master/PROTOCOL.md
+//
+// Every result is a "next" event and the stream ends with a
+// "complete" event. Events are numbered from 1, or from the
+// Last-Event-ID a reconnecting client sends, see LastEventID.
+const EventStream = "text/event-stream"
+
+// DefaultKeepAlive is how often comments are sent on event streams if
+// KeepAlive isn't set.
+const DefaultKeepAlive = 12 * time.Second
+
+type contextKey int
+
+const lastEventIDKey contextKey = 0
+
+// LastEventID returns the id of the last event a client that
+// reconnected to an event stream received, resolvers can use it to
+// skip the events it already has.
+func LastEventID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(lastEventIDKey).(int)
+	return id, ok
+}
+
+// acceptsEvents reports whether a request with the Accept header
+// accept asks for an event stream.
+func acceptsEvents(accept string) bool {
+	for _, r := range strings.Split(accept, ",") {
+		mt, ps, err := mime.ParseMediaType(strings.TrimSpace(r))
+		if err == nil && mt == EventStream && ps["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveEvents executes an operation and streams its results as
+// Server-Sent Events until it is done or the client disconnects.
+// Requests that fail before execution starts get a regular response.
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
+	mediaType := accepts(r.Header.Get("Accept"))
+	if mediaType == "" {
+		mediaType = GraphQLResponse
+	}
+	ps, batch, rerr := h.params(r)
+	defer closeUploads(r, ps)
+	switch {
+	case rerr != nil:
+		fail(w, mediaType, rerr)
+		return
+	case batch:
+		fail(w, mediaType, badRequest("Batches can't be streamed."))
+		return
+	}
+	p := ps[0]
+
+	ctx := r.Context()
+	last := 0
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 0 {
+			fail(w, mediaType, badRequest("Last-Event-ID must be an event id."))
+			return
+		}
+		last = id
+		ctx = context.WithValue(ctx, lastEventIDKey, id)
+	}
+	r = r.WithContext(ctx)
+
+	var results <-chan *executor.Result
+	if doc, op := subscription(p); op != nil {
+		var res *executor.Result
+		results, res = h.Executor.Subscribe(ctx, doc, p.OperationName, p.Variables, h.root(r))
+		if res != nil {
+			write(w, mediaType, http.StatusBadRequest, res)
+			return
+		}
+	} else {
+		res, _, err := h.execute(r, p, false)
+		if err != nil {
+			if r.Method == "GET" && err.status == http.StatusMethodNotAllowed {
+				w.Header().Set("Allow", "POST")
+			}
+			fail(w, mediaType, err)
+			return
+		}
+		if res.RequestError() {
+			write(w, mediaType, http.StatusBadRequest, res)
+			return
+		}
+		c := make(chan *executor.Result, 1)
+		c <- res
+		close(c)
+		results = c
+	}
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	w.Header().Set("Content-Type", EventStream+"; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flush()
+
+	var keepAlive <-chan time.Time
+	if d := h.KeepAlive; d >= 0 {
+		if d == 0 {
+			d = DefaultKeepAlive
+		}
+		t := time.NewTicker(d)
+		defer t.Stop()
+		keepAlive = t.C
+	}
+	id := last
+	for {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				flush()
+				return
+			}
+			b, err := json.Marshal(res)
+			if err != nil {
+				b, _ = json.Marshal(&executor.Result{Errors: []*executor.Error{{Message: err.Error()}}})
+			}
+			id++
+			fmt.Fprintf(w, "id: %d\nevent: next\ndata: %s\n\n", id, b)
+			flush()
+		case <-keepAlive:
+			fmt.Fprint(w, ":\n\n")
+			flush()
+		case <-ctx.Done():
+			// the client disconnected, which ends the subscription.
+			return
+		}
+	}
+}
+
+// subscription returns the document and the operation of a request if
+// it is a subscription.
+func subscription(p *params) (*ast.Document, *ast.Operation) {
+	doc, err := parser.NewQuery([]byte(p.Query))
+	if err != nil {
+		return nil, nil
+	}
+	op, oerr := executor.Operation(doc, p.OperationName)
+	if oerr != nil || op.OperationType != ast.Subscription {
+		return nil, nil
+	}
+	return doc, op
+}
@@ -0,0 +1,231 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler // import "sevki.org/graphql/handler"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// DefaultMaxUploadBytes is the largest multipart request handlers
+// read if MaxUploadBytes isn't set.
+const DefaultMaxUploadBytes = 32 << 20
+
+// DefaultUploadMemoryBytes is how much of the files of a multipart
+// request handlers keep in memory if UploadMemoryBytes isn't set.
+const DefaultUploadMemoryBytes = 8 << 20
+
+// Upload is a file sent along with the operations of a multipart
+// request, as described in
+// https://github.com/jaydenseric/graphql-multipart-request-spec
+//
+// Variables of the Upload scalar, see UploadScalar, hold *Upload
+// values. Files are only open until the request is done.
+type Upload struct {
+	File        io.Reader
+	Filename    string
+	ContentType string
+	Size        int64
+
+	f multipart.File
+}
+
+// UploadScalar implements the Upload scalar files are sent as, it
+// is added to schemas with
+//
+//	s.SetScalar("Upload", handler.UploadScalar{})
+//
+// Uploads can only be variables, they can't be written in documents
+// or returned by fields.
+type UploadScalar struct{}
+
+// ParseLiteral fails, uploads can't be written in documents.
+func (UploadScalar) ParseLiteral(v ast.Value) (interface{}, error) {
+	return nil, errors.New("Upload literal unsupported.")
+}
+
+// ParseValue accepts the *Upload values handlers put in variables.
+func (UploadScalar) ParseValue(v interface{}) (interface{}, error) {
+	if u, ok := v.(*Upload); ok {
+		return u, nil
+	}
+	return nil, errors.New("Upload value invalid.")
+}
+
+// Serialize fails, uploads can't be returned.
+func (UploadScalar) Serialize(v interface{}) (interface{}, error) {
+	return nil, errors.New("Upload serialization unsupported.")
+}
+
+// multipart reads the operations of a multipart request and puts the
+// files it holds in their variables.
+func (h *Handler) multipart(r *http.Request) ([]*params, bool, *requestError) {
+	max := h.MaxUploadBytes
+	if max == 0 {
+		max = DefaultMaxUploadBytes
+	}
+	mem := h.UploadMemoryBytes
+	if mem == 0 {
+		mem = DefaultUploadMemoryBytes
+	}
+	body := &limitReader{ReadCloser: r.Body, n: max}
+	r.Body = body
+	// parts that don't fit in mem are written to temporary files.
+	if err := r.ParseMultipartForm(mem); err != nil {
+		if body.over {
+			return nil, false, &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Multipart requests are limited to %d bytes.", max)}
+		}
+		return nil, false, badRequest("Invalid multipart request: %v.", err)
+	}
+	form := r.MultipartForm
+	if len(form.Value["operations"]) != 1 {
+		return nil, false, badRequest("Missing multipart field \"operations\".")
+	}
+	ps, batch, rerr := h.operations([]byte(form.Value["operations"][0]))
+	if rerr != nil {
+		return nil, false, rerr
+	}
+	if len(form.Value["map"]) != 1 {
+		return ps, batch, badRequest("Missing multipart field \"map\".")
+	}
+	var m map[string][]string
+	if err := json.Unmarshal([]byte(form.Value["map"][0]), &m); err != nil {
+		return ps, batch, badRequest("Multipart field \"map\" is invalid JSON.")
+	}
+	for key, paths := range m {
+		fhs := form.File[key]
+		if len(fhs) != 1 {
+			return ps, batch, badRequest("File %q is missing in the request.", key)
+		}
+		// every path gets its own reader, as the operations of a
+		// batch are executed concurrently.
+		for _, path := range paths {
+			f, err := fhs[0].Open()
+			if err != nil {
+				return ps, batch, &requestError{http.StatusInternalServerError, fmt.Sprintf("Opening file %q failed.", key)}
+			}
+			u := &Upload{
+				File:        f,
+				Filename:    fhs[0].Filename,
+				ContentType: fhs[0].Header.Get("Content-Type"),
+				Size:        fhs[0].Size,
+				f:           f,
+			}
+			if err := setUpload(ps, batch, path, u); err != nil {
+				f.Close()
+				return ps, batch, badRequest("Invalid file path %q: %v.", path, err)
+			}
+		}
+	}
+	return ps, batch, nil
+}
+
+// limitReader reads the body of a request up to n bytes and
+// remembers if there was more.
+type limitReader struct {
+	io.ReadCloser
+	n    int64
+	over bool
+}
+
+var errTooLarge = errors.New("request body too large")
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.over {
+		return 0, errTooLarge
+	}
+	// one byte more than allowed tells a body of n bytes from a
+	// longer one.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+	n, l.n, l.over = int(l.n), 0, true
+	return n, errTooLarge
+}
+
+// setUpload puts u in the variables of the operations at path, e.g.
+// "variables.files.1", or "0.variables.file" in a batch.
+func setUpload(ps []*params, batch bool, path string, u *Upload) error {
+	keys := strings.Split(path, ".")
+	p := ps[0]
+	if batch {
+		i, err := strconv.Atoi(keys[0])
+		if err != nil || i < 0 || i >= len(ps) {
+			return errors.New("no such operation")
+		}
+		p, keys = ps[i], keys[1:]
+	}
+	if len(keys) < 2 || keys[0] != "variables" || p.Variables == nil {
+		return errors.New("files can only be variables")
+	}
+	var v interface{} = p.Variables
+	for i, k := range keys[1:] {
+		last := i == len(keys)-2
+		switch c := v.(type) {
+		case map[string]interface{}:
+			if _, ok := c[k]; !ok {
+				return fmt.Errorf("no %s", k)
+			}
+			if last {
+				c[k] = u
+				return nil
+			}
+			v = c[k]
+		case []interface{}:
+			n, err := strconv.Atoi(k)
+			if err != nil || n < 0 || n >= len(c) {
+				return fmt.Errorf("no %s", k)
+			}
+			if last {
+				c[n] = u
+				return nil
+			}
+			v = c[n]
+		default:
+			return fmt.Errorf("no %s", k)
+		}
+	}
+	return nil
+}
+
+// closeUploads closes the files of a multipart request and removes
+// the temporary files holding them.
+func closeUploads(r *http.Request, ps []*params) {
+	if r.MultipartForm == nil {
+		return
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case *Upload:
+			v.f.Close()
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	for _, p := range ps {
+		walk(p.Variables)
+	}
+	r.MultipartForm.RemoveAll()
+}
@@ -0,0 +1,230 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handler // import "sevki.org/graphql/handler"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/executor"
+	"sevki.org/graphql/parser"
+)
+
+// Protocol is the WebSocket subprotocol handlers speak, as described
+// in
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const Protocol = "graphql-transport-ws"
+
+// DefaultInitTimeout is how long WebSocket clients have to send
+// connection_init if InitTimeout isn't set.
+const DefaultInitTimeout = 3 * time.Second
+
+// message is a message of the graphql-transport-ws protocol.
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsConn is a WebSocket connection, every operation it subscribes to
+// is executed by a goroutine of its own.
+type wsConn struct {
+	h *Handler
+	r *http.Request
+	c *websocket.Conn
+
+	// mu serializes writes and guards the fields below.
+	mu    sync.Mutex
+	acked bool
+	// subs cancels the operations that are running by id.
+	subs map[string]context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// serveWebSocket executes the operations a client subscribes to over
+// a WebSocket connection, until either side closes it.
+func (h *Handler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	up := websocket.Upgrader{Subprotocols: []string{Protocol}, CheckOrigin: h.CheckOrigin}
+	c, err := up.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader responded with an error.
+		return
+	}
+	defer c.Close()
+	ws := &wsConn{h: h, r: r, c: c, subs: make(map[string]context.CancelFunc)}
+	if c.Subprotocol() != Protocol {
+		ws.close(4406, "Subprotocol not acceptable")
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer func() {
+		cancel()
+		ws.wg.Wait()
+	}()
+
+	timeout := h.InitTimeout
+	if timeout == 0 {
+		timeout = DefaultInitTimeout
+	}
+	timer := time.AfterFunc(timeout, func() {
+		ws.mu.Lock()
+		acked := ws.acked
+		ws.mu.Unlock()
+		if !acked {
+			ws.close(4408, "Connection initialisation timeout")
+		}
+	})
+	defer timer.Stop()
+
+	initialised := false
+	for {
+		_, b, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var m message
+		if err := json.Unmarshal(b, &m); err != nil || m.Type == "" {
+			ws.close(4400, "Invalid message received")
+			return
+		}
+		switch m.Type {
+		case "connection_init":
+			if initialised {
+				ws.close(4429, "Too many initialisation requests")
+				return
+			}
+			initialised = true
+			if h.InitWebSocket != nil {
+				var payload map[string]interface{}
+				if len(m.Payload) > 0 {
+					if err := json.Unmarshal(m.Payload, &payload); err != nil {
+						ws.close(4400, "Invalid message received")
+						return
+					}
+				}
+				if err := h.InitWebSocket(r, payload); err != nil {
+					ws.close(4403, "Forbidden")
+					return
+				}
+			}
+			ws.mu.Lock()
+			ws.acked = true
+			ws.mu.Unlock()
+			ws.send("", "connection_ack", nil, false)
+		case "ping":
+			ws.send("", "pong", nil, false)
+		case "pong":
+		case "subscribe":
+			ws.mu.Lock()
+			acked, exists := ws.acked, ws.subs[m.ID] != nil
+			ws.mu.Unlock()
+			p := &params{}
+			switch {
+			case !acked:
+				ws.close(4401, "Unauthorized")
+				return
+			case m.ID == "" || json.Unmarshal(m.Payload, p) != nil:
+				ws.close(4400, "Invalid message received")
+				return
+			case exists:
+				ws.close(4409, fmt.Sprintf("Subscriber for %s already exists", m.ID))
+				return
+			}
+			sctx, scancel := context.WithCancel(ctx)
+			ws.mu.Lock()
+			ws.subs[m.ID] = scancel
+			ws.mu.Unlock()
+			ws.wg.Add(1)
+			go ws.subscribe(sctx, m.ID, p)
+		case "complete":
+			ws.mu.Lock()
+			if cancel, ok := ws.subs[m.ID]; ok {
+				cancel()
+				delete(ws.subs, m.ID)
+			}
+			ws.mu.Unlock()
+		default:
+			ws.close(4400, fmt.Sprintf("Unexpected message of type %s received", m.Type))
+			return
+		}
+	}
+}
+
+// subscribe executes the operation with the given id and sends its
+// results, queries and mutations have a single one.
+func (ws *wsConn) subscribe(ctx context.Context, id string, p *params) {
+	defer ws.wg.Done()
+	if p.Query == "" {
+		ws.send(id, "error", []*executor.Error{{Message: "Must provide query string."}}, true)
+		return
+	}
+	doc, err := parser.NewQuery([]byte(p.Query))
+	if err != nil {
+		ws.send(id, "error", []*executor.Error{{Message: fmt.Sprintf("Syntax Error: %v", err)}}, true)
+		return
+	}
+	root := ws.h.root(ws.r)
+	e := ws.h.Executor
+	if op, err := executor.Operation(doc, p.OperationName); err == nil && op.OperationType == ast.Subscription {
+		results, res := e.Subscribe(ctx, doc, p.OperationName, p.Variables, root)
+		if res != nil {
+			ws.send(id, "error", res.Errors, true)
+			return
+		}
+		for res := range results {
+			ws.send(id, "next", res, false)
+		}
+	} else {
+		res := e.ExecuteContext(ctx, doc, p.OperationName, p.Variables, root)
+		if res.RequestError() {
+			ws.send(id, "error", res.Errors, true)
+			return
+		}
+		ws.send(id, "next", res, false)
+	}
+	ws.send(id, "complete", nil, true)
+}
+
+// send writes a message, messages of operations the client completed
+// are dropped. The operation is done if last is set.
+func (ws *wsConn) send(id, typ string, payload interface{}, last bool) {
+	m := message{ID: id, Type: typ}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			b, _ = json.Marshal([]*executor.Error{{Message: err.Error()}})
+			m.Type, last = "error", true
+		}
+		m.Payload = b
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if id != "" {
+		cancel, ok := ws.subs[id]
+		if !ok {
+			return
+		}
+		if last {
+			cancel()
+			delete(ws.subs, id)
+		}
+	}
+	b, _ := json.Marshal(m)
+	ws.c.WriteMessage(websocket.TextMessage, b)
+}
+
+// close closes the connection with a close frame holding code and
+// reason, which ends the operations that are running.
+func (ws *wsConn) close(code int, reason string) {
+	ws.c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	ws.c.Close()
+}
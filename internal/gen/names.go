@@ -0,0 +1,60 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gen holds what the code generators in cmd have in common.
+package gen // import "sevki.org/graphql/internal/gen"
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// OneLine joins the lines of s, e.g. a description, into one.
+func OneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "json": true,
+	"uri": true, "url": true, "uuid": true, "xml": true,
+}
+
+// Exported returns the GraphQL name n as an exported Go name. Words
+// separated by underscores are joined, a name made only of
+// underscores becomes X.
+func Exported(n string) string {
+	var b bytes.Buffer
+	for _, w := range strings.Split(n, "_") {
+		if w == "" {
+			continue
+		}
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		if strings.HasSuffix(w, "Id") {
+			w = strings.TrimSuffix(w, "Id") + "ID"
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// Unexported returns the Go name n with its first letter in lower
+// case.
+func Unexported(n string) string {
+	if n == "" {
+		return n
+	}
+	r := []rune(n)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
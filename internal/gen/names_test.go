@@ -0,0 +1,33 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gen // import "sevki.org/graphql/internal/gen"
+
+import "testing"
+
+func TestNames(t *testing.T) {
+	for _, test := range []struct {
+		name, exported, unexported string
+	}{
+		{"id", "ID", "id"},
+		{"url", "URL", "url"},
+		{"userId", "UserID", "userId"},
+		{"__typename", "Typename", "__typename"},
+		{"GetMe", "GetMe", "getMe"},
+		{"site_wap", "SiteWap", "site_wap"},
+		{"html_url", "HTMLURL", "html_url"},
+		{"___", "X", "___"},
+		{"", "X", ""},
+	} {
+		if got := Exported(test.name); got != test.exported {
+			t.Errorf("Exported(%q) = %q, expected %q", test.name, got, test.exported)
+		}
+		if got := Unexported(test.name); got != test.unexported {
+			t.Errorf("Unexported(%q) = %q, expected %q", test.name, got, test.unexported)
+		}
+	}
+	if got := OneLine("a\n  b\tc "); got != "a b c" {
+		t.Errorf("OneLine = %q", got)
+	}
+}
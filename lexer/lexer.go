@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -56,7 +57,13 @@ func New(name string, r io.Reader) *Lexer {
 
 // errorf returns an error token and continues to scan.
 func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
-	l.Tokens <- token.Token{token.Error, l.start, []byte(fmt.Sprintf(format, args...)), l.start, l.pos}
+	l.Tokens <- token.Token{
+		Type:  token.Error,
+		Line:  l.line,
+		Text:  []byte(fmt.Sprintf(format, args...)),
+		Start: l.start,
+		End:   l.pos,
+	}
 	return lexAny
 }
 
@@ -88,15 +95,15 @@ func (l *Lexer) emit(t token.Type) {
 	}
 	s := l.input[l.start:l.pos]
 	if os.Getenv("DEBUG") == "true" {
-		fmt.Printf("%s:%d: emit %s\n", l.name, l.line, token.Token{t, l.line, []byte(s), l.start, l.pos})
+		fmt.Printf("%s:%d: emit %s %q\n", l.name, l.line, t, s)
 	}
 	if t != token.Newline {
 		l.Tokens <- token.Token{
-			t,
-			l.line,
-			[]byte(s),
-			l.start,
-			l.pos,
+			Type:  t,
+			Line:  l.line,
+			Text:  []byte(s),
+			Start: l.start,
+			End:   l.pos,
 		}
 	}
 	l.start = l.pos
@@ -151,7 +158,7 @@ func lexAny(l *Lexer) stateFn {
 		case r == '(':
 			l.emit(token.LeftParen)
 			return lexAny
-		case unicode.IsDigit(r):
+		case unicode.IsDigit(r), r == '-':
 			return lexNumber
 		case unicode.IsLetter(r):
 			return lexAlphaNumeric
@@ -183,6 +190,12 @@ func lexAny(l *Lexer) stateFn {
 		case r == '|':
 			l.emit(token.Pipe)
 			return lexAny
+		case r == '!':
+			l.emit(token.Bang)
+			return lexAny
+		case r == '&':
+			l.emit(token.Amp)
+			return lexAny
 		case r == '.':
 			return lexPeriodOrElipsis
 		case r == ',':
@@ -225,8 +238,22 @@ func lexPeriodOrElipsis(l *Lexer) stateFn {
 }
 
 func lexQuote(l *Lexer) stateFn {
-	for l.peek() != '"' {
+	if l.peek() == '"' {
 		l.next()
+		if l.peek() != '"' {
+			l.emit(token.Quote)
+			return lexAny
+		}
+		l.next()
+		return lexBlockQuote
+	}
+	for r := l.peek(); r != '"'; r = l.peek() {
+		if r == eof || isEndOfLine(r) {
+			break
+		}
+		if l.next() == '\\' {
+			l.next()
+		}
 	}
 	if r := l.next(); r == '"' {
 		l.emit(token.Quote)
@@ -240,6 +267,31 @@ func lexQuote(l *Lexer) stateFn {
 	return lexAny
 }
 
+// lexBlockQuote scans a block string, the opening triple quote has
+// already been seen. Block strings may span several lines.
+func lexBlockQuote(l *Lexer) stateFn {
+	lines := 0
+	for quotes := 0; quotes < 3; {
+		switch r := l.next(); {
+		case r == eof:
+			return l.errorf("Unterminated block string starting on line %d.", l.line)
+		case r == '"':
+			quotes++
+		case r == '\\' && strings.HasPrefix(l.input[l.pos:], `"""`):
+			l.pos += 3
+			quotes = 0
+		default:
+			if r == '\n' {
+				lines++
+			}
+			quotes = 0
+		}
+	}
+	l.emit(token.BlockQuote)
+	l.line += lines
+	return lexAny
+}
+
 func lexVariable(l *Lexer) stateFn {
 	l.ignore()
 	for isAlphaNumeric(l.peek()) {
@@ -274,6 +326,9 @@ func lexAlphaNumeric(l *Lexer) stateFn {
 	case "mutation":
 		l.emit(token.MutationStart)
 		break
+	case "subscription":
+		l.emit(token.SubscriptionStart)
+		break
 	case "on":
 		l.emit(token.On)
 		break
@@ -283,6 +338,9 @@ func lexAlphaNumeric(l *Lexer) stateFn {
 	case "false":
 		l.emit(token.False)
 		break
+	case "null":
+		l.emit(token.Null)
+		break
 	default:
 		l.emit(token.String)
 	}
@@ -297,10 +355,11 @@ func lexDirective(l *Lexer) stateFn {
 	for isAlphaNumeric(l.peek()) {
 		l.next()
 	}
-	if r := l.peek(); r == '(' || r == ' ' {
+	if l.pos > l.start {
 		l.emit(token.Directive)
 		l.ignore()
 	} else {
+		r := l.peek()
 		l.errorf("Unexpected character inside directive in position %d:%d character %q.",
 			l.line,
 			l.pos,
@@ -315,6 +374,11 @@ func lexNumber(l *Lexer) stateFn {
 		switch l.next() {
 		case '.':
 			emitee = token.Float
+		case 'e', 'E':
+			emitee = token.Float
+			if r := l.peek(); r == '+' || r == '-' {
+				l.next()
+			}
 		case 'x':
 			return lexHex
 		}
@@ -369,6 +433,8 @@ func isValidNumber(r rune) bool {
 	return unicode.IsDigit(r) ||
 		r == '-' ||
 		r == '.' ||
+		r == 'e' ||
+		r == 'E' ||
 		r == 'x'
 
 }
@@ -34,6 +34,10 @@ func (p *Parser) peek() token.Token {
 	return p.peekTok
 }
 func (p *Parser) next() token.Token {
+	if p.Error != nil {
+		p.curTok = token.Token{Type: token.EOF}
+		return p.curTok
+	}
 	tok := p.peekTok
 	p.peekTok = <-p.lexer.Tokens
 	p.curTok = tok
@@ -99,10 +103,18 @@ func parseDefinition(p *Parser) stateFn {
 		return nil
 	case token.LeftCurly, token.QueryStart:
 		return parseOperation
-	case token.MutationStart:
+	case token.MutationStart, token.SubscriptionStart:
 		return parseOperation
 	case token.FragmentStart:
 		return parseFragmentDefinition
+	case token.Quote, token.BlockQuote:
+		return parseTypeSystemDefinition
+	case token.String:
+		if typeSystemKeywords[string(p.peek().Text)] {
+			return parseTypeSystemDefinition
+		}
+		p.expect(p.peekTok, token.LeftCurly)
+		return nil
 	default:
 		p.expect(p.peekTok, token.LeftCurly)
 		return nil
@@ -112,17 +124,26 @@ func parseDefinition(p *Parser) stateFn {
 // parseOperation
 func parseOperation(p *Parser) stateFn {
 	t := p.next()
-	var op ast.Operation
+	op := ast.Operation{Pos: pos(t)}
+	p.ptr, p.prnt = nil, nil
 
-	if t.Type == token.MutationStart {
+	switch t.Type {
+	case token.MutationStart:
 		op.OperationType = ast.Mutation
-	} else {
+	case token.SubscriptionStart:
+		op.OperationType = ast.Subscription
+	default:
 		op.OperationType = ast.Query
 	}
-	if t.Type == token.QueryStart {
+	p.Document.Definitions = append(p.Document.Definitions, &op)
+
+	// query shorthand, the curly has already been consumed.
+	if t.Type == token.LeftCurly {
+		return parseSelection
+	}
+	if isName(p.peek()) {
 		op.Name = ast.GraphQLName(p.next().Text)
 	}
-	p.Document.Definitions = append(p.Document.Definitions, &op)
 
 	return parseVariables
 }
@@ -131,14 +152,26 @@ func parseSelection(p *Parser) stateFn {
 	switch p.peek().Type {
 	case token.Elipsis:
 		return parseFragment
-	case token.String:
-		return parseField
 	case token.RightCurly:
 		return parseRightCurly
 	default:
+		if isName(p.peek()) {
+			return parseField
+		}
+		p.expect(p.peek(), token.RightCurly)
 		return nil
 	}
 }
+
+// addSelection adds s to the selection set that is being parsed.
+func (p *Parser) addSelection(s ast.Selection) {
+	if p.prnt == nil {
+		def := p.Document.Definitions[len(p.Document.Definitions)-1]
+		def.AddSelection(s)
+	} else {
+		p.prnt.AddSelection(s)
+	}
+}
 func parseSelectionSet(p *Parser) stateFn {
 
 	if p.expect(p.next(), token.LeftCurly) {
@@ -158,7 +191,13 @@ func parseRightCurly(p *Parser) stateFn {
 
 	p.next()
 	if p.prnt == nil {
-		return parseDefinition
+		return parseDocument
+	}
+	// closing a fragment definition.
+	if f, ok := p.prnt.(*ast.Fragment); ok &&
+		ast.Definition(f) == p.Document.Definitions[len(p.Document.Definitions)-1] {
+		p.ptr, p.prnt = nil, nil
+		return parseDocument
 	}
 	switch p.prnt.(type) {
 	case *ast.Field:
@@ -167,12 +206,7 @@ func parseRightCurly(p *Parser) stateFn {
 		p.prnt = p.prnt.(*ast.Fragment).Parent
 	}
 	p.ptr = p.prnt
-	switch p.peek().Type {
-	case token.Elipsis, token.String, token.RightCurly:
-		return parseSelection
-	default:
-		return parseDocument
-	}
+	return parseSelection
 }
 func parseField(p *Parser) stateFn {
 
@@ -182,87 +216,81 @@ func parseField(p *Parser) stateFn {
 	}
 	var field ast.Field
 	field.Parent = p.prnt
+	field.Pos = pos(t)
 	if p.peek().Type == token.Colon {
 		field.Alias = ast.GraphQLName(t.Text)
 		p.next()
-		field.Name = ast.GraphQLName(p.next().Text)
-	} else {
+		t = p.next()
+		if !p.expect(t, token.String) {
+			return nil
+		}
 		field.Name = ast.GraphQLName(t.Text)
-	}
-
-	if p.prnt == nil {
-		def := p.Document.Definitions[len(p.Document.Definitions)-1]
-		def.AddSelection(&field)
 	} else {
-		p.prnt.AddSelection(&field)
+		field.Name = ast.GraphQLName(t.Text)
 	}
-	if p.peek().Type == token.Elipsis {
-		return parseSelection
-	} else {
-		p.ptr = &field
-		return parseArguments
 
-	}
+	p.addSelection(&field)
+	p.ptr = &field
+	return parseArguments
 
 }
 func parseFragment(p *Parser) stateFn {
 	if !p.expect(p.next(), token.Elipsis) {
 		return nil
 	}
-	if p.peek().Type == token.On {
+	switch t := p.peek(); {
+	case t.Type == token.On,
+		t.Type == token.Directive,
+		t.Type == token.LeftCurly:
 		return parseInlineFragment
-	} else if p.peek().Type == token.String {
+	case isName(t):
 		return parseFragmentSpread
-	} else {
+	default:
+		p.expect(t, token.String)
 		return nil
 	}
 }
 func parseFragmentSpread(p *Parser) stateFn {
-
+	start := p.curTok
 	t := p.next()
-	frag := ast.Fragment{FragmentName: ast.GraphQLName(t.Text)}
-	p.prnt.AddSelection(&frag)
+	frag := ast.Fragment{
+		FragmentName: ast.GraphQLName(t.Text),
+		Parent:       p.prnt,
+		Pos:          pos(start),
+	}
+	p.addSelection(&frag)
 	p.ptr = &frag
 
 	return parseDirectives
 }
 
 func parseInlineFragment(p *Parser) stateFn {
-	p.next()
-	t := p.next()
-
-	frag := ast.Fragment{TypeCondition: ast.GraphQLName(t.Text)}
-	p.prnt.AddSelection(&frag)
-	p.ptr = &frag
-	if p.peek().Type == token.Directive {
-		return parseDirectives
+	frag := ast.Fragment{
+		Parent: p.prnt,
+		Pos:    pos(p.curTok),
 	}
-	return nil
-}
-func parseDirectives(p *Parser) stateFn {
-	//	log.Println(firstCaller())
-	for p.peek().Type == token.Directive {
+	if p.peek().Type == token.On {
+		p.next()
 		t := p.next()
-		if !p.expect(t, token.Directive) {
+		if !p.expect(t, token.String) {
 			return nil
 		}
-		if p.peek().Type == token.LeftParen {
+		frag.TypeCondition = ast.GraphQLName(t.Text)
+	}
 
-			if p.ptr == nil {
-				op := p.Document.Definitions[len(p.Document.Definitions)-1].(*ast.Operation)
-				op.AddDirective(string(t.Text), p.parseArguments())
-			} else {
-				p.ptr.AddDirective(string(t.Text), p.parseArguments())
-			}
+	p.addSelection(&frag)
+	p.ptr = &frag
+	return parseDirectives
+}
+func parseDirectives(p *Parser) stateFn {
+	//	log.Println(firstCaller())
+	for _, d := range p.parseDirectives() {
+		if p.ptr == nil {
+			op := p.Document.Definitions[len(p.Document.Definitions)-1].(*ast.Operation)
+			op.AddDirective(d)
 		} else {
-			if p.ptr == nil {
-				op := p.Document.Definitions[len(p.Document.Definitions)-1].(*ast.Operation)
-				op.AddDirective(string(t.Text), nil)
-			} else {
-				p.ptr.AddDirective(string(t.Text), nil)
-			}
+			p.ptr.AddDirective(d)
 		}
-
 	}
 
 	if p.peek().Type == token.LeftCurly {
@@ -282,12 +310,12 @@ func parseArguments(p *Parser) stateFn {
 
 }
 
-//--------------------------------------------------------------
+// --------------------------------------------------------------
 // parseArguments
 func (p *Parser) parseArguments() ast.Arguments {
 	if p.peek().Type == token.LeftParen {
 		p.next()
-		args := make(ast.Arguments)
+		args := ast.Arguments{}
 		for p.peek().Type != token.RightParen {
 
 			key := p.next()
@@ -300,28 +328,100 @@ func (p *Parser) parseArguments() ast.Arguments {
 				break
 			}
 
-			if p.peek().Type == token.LeftBrac {
-				var ary ast.ArrayValue
-				p.next() // advance left brac
-				for t := p.next(); t.Type != token.RightBrac; t = p.next() {
-					ary = append(ary, ast.GraphQLValue(t))
-				}
-				args[string(key.Text)] = ary
-			} else {
-				value := p.next()
-
-				args[string(key.Text)] = ast.GraphQLValue(value)
-			}
+			args = append(args, &ast.Argument{
+				Name:  ast.GraphQLName(key.Text),
+				Value: p.parseValue(),
+				Pos:   pos(key),
+			})
 		}
 		p.next() // right paren
 		return args
 	}
 	return nil
 }
+
+// parseDirectives parses the directives of whatever precedes them.
+func (p *Parser) parseDirectives() ast.Directives {
+	var dirs ast.Directives
+	for p.peek().Type == token.Directive {
+		t := p.next()
+		dirs = append(dirs, &ast.Directive{
+			Name:      ast.GraphQLName(t.Text),
+			Arguments: p.parseArguments(),
+			Pos:       pos(t),
+		})
+	}
+	return dirs
+}
+
+// parseValue parses a value, lists and objects are parsed
+// recursively.
+func (p *Parser) parseValue() ast.Value {
+	switch p.peek().Type {
+	case token.LeftBrac:
+		p.next() // advance left brac
+		ary := ast.ArrayValue{}
+		for t := p.peek().Type; t != token.RightBrac; t = p.peek().Type {
+			if t == token.EOF {
+				p.expect(p.peek(), token.RightBrac)
+				return ary
+			}
+			ary = append(ary, p.parseValue())
+		}
+		p.next() // right brac
+		return ary
+	case token.LeftCurly:
+		p.next() // advance left curly
+		obj := ast.ObjectValue{}
+		for p.peek().Type != token.RightCurly {
+			key := p.next()
+			if !p.expect(key, token.String) {
+				return obj
+			}
+			if !p.expect(p.next(), token.Colon) {
+				return obj
+			}
+			obj = append(obj, &ast.ObjectField{
+				Name:  ast.GraphQLName(key.Text),
+				Value: p.parseValue(),
+				Pos:   pos(key),
+			})
+		}
+		p.next() // right curly
+		return obj
+	default:
+		return ast.GraphQLValue(p.next())
+	}
+}
+
+// parseType parses named, list and non-null types.
+func (p *Parser) parseType() ast.Type {
+	var typ string
+	if p.peek().Type == token.LeftBrac {
+		p.next()
+		typ = "[" + string(p.parseType()) + "]"
+		if !p.expect(p.next(), token.RightBrac) {
+			return ""
+		}
+	} else {
+		t := p.next()
+		if !p.expect(t, token.String) {
+			return ""
+		}
+		typ = string(t.Text)
+	}
+	if p.peek().Type == token.Bang {
+		p.next()
+		typ += "!"
+	}
+	return ast.Type(typ)
+}
+
 func parseVariables(p *Parser) stateFn {
-	if p.next().Type == token.LeftParen {
+	if p.peek().Type == token.LeftParen {
+		p.next()
 		op := p.Document.Definitions[len(p.Document.Definitions)-1].(*ast.Operation)
-		op.VariableDefinitions = make(ast.VariableDefinitions)
+		op.VariableDefinitions = ast.VariableDefinitions{}
 
 		for p.peek().Type != token.RightParen {
 
@@ -335,31 +435,36 @@ func parseVariables(p *Parser) stateFn {
 				return nil
 			}
 
-			typeName := p.next()
-			if !p.expect(typeName, token.String) {
+			typ := p.parseType()
+			if typ == "" {
 				return nil
 			}
 
-			varb := ast.Variable{Type: ast.Type(typeName.Text)}
+			varb := &ast.Variable{
+				Name: ast.GraphQLName(varName.Text),
+				Type: typ,
+				Pos:  pos(varName),
+			}
 
 			//followed by Equals
 			if p.peek().Type == token.Equal {
 				p.next()
-				varb.DefaultValue = ast.GraphQLString(p.next().Text)
+				varb.DefaultValue = p.parseValue()
 			}
 
-			op.VariableDefinitions[string(varName.Text)] = varb
+			op.VariableDefinitions = append(op.VariableDefinitions, varb)
 		}
+		p.next() // right paren
 	}
-	p.next() // right paren
 	return parseDirectives
 }
 
 func parseFragmentDefinition(p *Parser) stateFn {
-	if !p.expect(p.next(), token.FragmentStart) {
+	start := p.next()
+	if !p.expect(start, token.FragmentStart) {
 		return nil
 	}
-	var frag ast.Fragment
+	frag := ast.Fragment{Pos: pos(start)}
 	t := p.next()
 	if !p.expect(t, token.String) {
 		return nil
@@ -377,5 +482,36 @@ func parseFragmentDefinition(p *Parser) stateFn {
 			frag.TypeCondition = ast.GraphQLName(string(t.Text))
 		}
 	}
+	p.ptr, p.prnt = &frag, nil
 	return parseDirectives
 }
+
+// pos returns the position t starts at.
+func pos(t token.Token) ast.Position {
+	switch t.Type {
+	// the lexer drops the leading @ and $.
+	case token.Directive, token.Variable:
+		return ast.Position{Line: t.Line, Column: t.Start}
+	default:
+		return ast.Position{Line: t.Line, Column: t.Start + 1}
+	}
+}
+
+// isName reports whether t can be used as a name, keywords are only
+// reserved where they start a definition.
+func isName(t token.Token) bool {
+	switch t.Type {
+	case token.String,
+		token.QueryStart,
+		token.MutationStart,
+		token.SubscriptionStart,
+		token.FragmentStart,
+		token.On,
+		token.True,
+		token.False,
+		token.Null:
+		return true
+	default:
+		return false
+	}
+}
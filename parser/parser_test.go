@@ -32,3 +32,37 @@ func TestKitchenSink(t *testing.T) {
 	}
 
 }
+
+func TestSchemaDefinitions(t *testing.T) {
+	t.Parallel()
+	var doc ast.Document
+	ks, _ := os.Open("../tests/schema.graphql")
+	if err := New("schema", ks).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[ast.GraphQLName]*ast.TypeDefinition)
+	for _, def := range doc.Definitions {
+		if td, ok := def.(*ast.TypeDefinition); ok {
+			types[td.Name] = td
+		}
+	}
+	friend := types["Friend"]
+	if friend == nil || friend.Kind != ast.ObjectKind || len(friend.Interfaces) != 2 {
+		t.Fatalf("Friend was parsed as %+v", friend)
+	}
+	if f := friend.Field("foo"); f == nil || len(f.Arguments) != 3 || f.Type != "String" {
+		t.Errorf("Friend.foo was parsed as %+v", f)
+	}
+	if s := types["SearchResult"]; s == nil || len(s.Types) != 3 {
+		t.Errorf("SearchResult was parsed as %+v", s)
+	}
+	if e := types["Site"]; e == nil || e.EnumValue("WAP") == nil || e.EnumValue("WAP").Description == "" {
+		t.Errorf("Site was parsed as %+v", e)
+	}
+	if f := types["ComplexType"].Field("site"); f.DefaultValue != ast.EnumValue("MOBILE") {
+		t.Errorf("ComplexType.site defaults to %v", f.DefaultValue)
+	}
+	if f := types["Query"].Field("node"); f.Argument("id").Type != "[ID!]" {
+		t.Errorf("Query.node(id:) is of type %v", f.Argument("id").Type)
+	}
+}
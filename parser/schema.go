@@ -0,0 +1,340 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package parser // import "sevki.org/graphql/parser"
+
+import (
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/token"
+)
+
+// typeSystemKeywords start type system definitions, they are lexed
+// as plain strings since they are valid names everywhere else.
+var typeSystemKeywords = map[string]bool{
+	"schema":    true,
+	"scalar":    true,
+	"type":      true,
+	"interface": true,
+	"union":     true,
+	"enum":      true,
+	"input":     true,
+	"directive": true,
+	"extend":    true,
+}
+
+var typeKinds = map[string]ast.TypeKind{
+	"scalar":    ast.ScalarKind,
+	"type":      ast.ObjectKind,
+	"interface": ast.InterfaceKind,
+	"union":     ast.UnionKind,
+	"enum":      ast.EnumKind,
+	"input":     ast.InputObjectKind,
+}
+
+// parseTypeSystemDefinition parses schema, type and directive
+// definitions as defined in
+// http://facebook.github.io/graphql/#TypeSystemDefinition
+func parseTypeSystemDefinition(p *Parser) stateFn {
+	p.ptr, p.prnt = nil, nil
+	desc := p.parseDescription()
+
+	t := p.next()
+	if !p.expect(t, token.String) {
+		return nil
+	}
+	start := t
+	extend := string(t.Text) == "extend"
+	if extend {
+		t = p.next()
+	}
+
+	var def ast.Definition
+	kw := string(t.Text)
+	kind, isType := typeKinds[kw]
+	switch {
+	case kw == "schema":
+		def = p.parseSchemaDefinition(start, desc, extend)
+	case kw == "directive" && !extend:
+		def = p.parseDirectiveDefinition(start, desc)
+	case isType:
+		def = p.parseTypeDefinition(start, kind, desc, extend)
+	default:
+		p.errorf("While parsing TypeSystemDefinition got unexpected %q at %d:%d.",
+			t.Text,
+			t.Line,
+			t.Start)
+	}
+	if p.Error != nil {
+		return nil
+	}
+	p.Document.Definitions = append(p.Document.Definitions, def)
+	return parseDocument
+}
+
+// parseDescription parses the optional string that precedes
+// definitions.
+func (p *Parser) parseDescription() string {
+	switch p.peek().Type {
+	case token.Quote, token.BlockQuote:
+		if s, ok := ast.GraphQLValue(p.next()).(ast.GraphQLString); ok {
+			return string(s)
+		}
+	}
+	return ""
+}
+
+func (p *Parser) parseSchemaDefinition(start token.Token, desc string, extend bool) *ast.SchemaDefinition {
+	def := &ast.SchemaDefinition{
+		Description:    desc,
+		Directives:     p.parseDirectives(),
+		OperationTypes: make(map[ast.OperationType]ast.GraphQLName),
+		Extension:      extend,
+		Pos:            pos(start),
+	}
+	if extend && p.peek().Type != token.LeftCurly {
+		return def
+	}
+	if !p.expect(p.next(), token.LeftCurly) {
+		return nil
+	}
+	for p.peek().Type != token.RightCurly {
+		op := p.next()
+		var typ ast.OperationType
+		switch op.Type {
+		case token.QueryStart:
+			typ = ast.Query
+		case token.MutationStart:
+			typ = ast.Mutation
+		case token.SubscriptionStart:
+			typ = ast.Subscription
+		default:
+			p.expect(op, token.QueryStart)
+			return nil
+		}
+		if !p.expect(p.next(), token.Colon) {
+			return nil
+		}
+		t := p.next()
+		if !p.expect(t, token.String) {
+			return nil
+		}
+		def.OperationTypes[typ] = ast.GraphQLName(t.Text)
+	}
+	p.next() // right curly
+	return def
+}
+
+func (p *Parser) parseTypeDefinition(start token.Token, kind ast.TypeKind, desc string, extend bool) *ast.TypeDefinition {
+	t := p.next()
+	if !p.expect(t, token.String) {
+		return nil
+	}
+	def := &ast.TypeDefinition{
+		Kind:        kind,
+		Name:        ast.GraphQLName(t.Text),
+		Description: desc,
+		Extension:   extend,
+		Pos:         pos(start),
+	}
+
+	if (kind == ast.ObjectKind || kind == ast.InterfaceKind) &&
+		string(p.peek().Text) == "implements" {
+		p.next()
+		if p.peek().Type == token.Amp {
+			p.next()
+		}
+		for {
+			t := p.next()
+			if !p.expect(t, token.String) {
+				return nil
+			}
+			def.Interfaces = append(def.Interfaces, ast.GraphQLName(t.Text))
+			if p.peek().Type != token.Amp {
+				break
+			}
+			p.next()
+		}
+	}
+
+	def.Directives = p.parseDirectives()
+
+	switch kind {
+	case ast.ObjectKind, ast.InterfaceKind, ast.InputObjectKind:
+		if p.peek().Type != token.LeftCurly {
+			return def
+		}
+		p.next()
+		for p.peek().Type != token.RightCurly {
+			f := p.parseFieldDefinition(kind == ast.InputObjectKind)
+			if f == nil {
+				return nil
+			}
+			def.Fields = append(def.Fields, f)
+		}
+		p.next() // right curly
+	case ast.UnionKind:
+		if p.peek().Type != token.Equal {
+			return def
+		}
+		p.next()
+		if p.peek().Type == token.Pipe {
+			p.next()
+		}
+		for {
+			t := p.next()
+			if !p.expect(t, token.String) {
+				return nil
+			}
+			def.Types = append(def.Types, ast.GraphQLName(t.Text))
+			if p.peek().Type != token.Pipe {
+				break
+			}
+			p.next()
+		}
+	case ast.EnumKind:
+		if p.peek().Type != token.LeftCurly {
+			return def
+		}
+		p.next()
+		for p.peek().Type != token.RightCurly {
+			desc := p.parseDescription()
+			t := p.next()
+			if !p.expect(t, token.String) {
+				return nil
+			}
+			def.EnumValues = append(def.EnumValues, &ast.EnumValueDefinition{
+				Name:        ast.GraphQLName(t.Text),
+				Description: desc,
+				Directives:  p.parseDirectives(),
+				Pos:         pos(t),
+			})
+		}
+		p.next() // right curly
+	}
+	return def
+}
+
+// parseFieldDefinition parses a field of an object or interface, or
+// when input is set an input object field.
+func (p *Parser) parseFieldDefinition(input bool) *ast.FieldDefinition {
+	if input {
+		v := p.parseInputValueDefinition()
+		if v == nil {
+			return nil
+		}
+		return &ast.FieldDefinition{
+			Name:         v.Name,
+			Description:  v.Description,
+			Type:         v.Type,
+			DefaultValue: v.DefaultValue,
+			Directives:   v.Directives,
+			Pos:          v.Pos,
+		}
+	}
+	desc := p.parseDescription()
+	t := p.next()
+	if !p.expect(t, token.String) {
+		return nil
+	}
+	f := &ast.FieldDefinition{
+		Name:        ast.GraphQLName(t.Text),
+		Description: desc,
+		Pos:         pos(t),
+	}
+	if p.peek().Type == token.LeftParen {
+		if f.Arguments = p.parseArgumentsDefinition(); f.Arguments == nil {
+			return nil
+		}
+	}
+	if !p.expect(p.next(), token.Colon) {
+		return nil
+	}
+	if f.Type = p.parseType(); f.Type == "" {
+		return nil
+	}
+	f.Directives = p.parseDirectives()
+	return f
+}
+
+// parseArgumentsDefinition as defined in
+// http://facebook.github.io/graphql/#ArgumentsDefinition
+func (p *Parser) parseArgumentsDefinition() []*ast.InputValueDefinition {
+	if !p.expect(p.next(), token.LeftParen) {
+		return nil
+	}
+	args := []*ast.InputValueDefinition{}
+	for p.peek().Type != token.RightParen {
+		v := p.parseInputValueDefinition()
+		if v == nil {
+			return nil
+		}
+		args = append(args, v)
+	}
+	p.next() // right paren
+	return args
+}
+
+func (p *Parser) parseInputValueDefinition() *ast.InputValueDefinition {
+	desc := p.parseDescription()
+	t := p.next()
+	if !p.expect(t, token.String) {
+		return nil
+	}
+	v := &ast.InputValueDefinition{
+		Name:        ast.GraphQLName(t.Text),
+		Description: desc,
+		Pos:         pos(t),
+	}
+	if !p.expect(p.next(), token.Colon) {
+		return nil
+	}
+	if v.Type = p.parseType(); v.Type == "" {
+		return nil
+	}
+	if p.peek().Type == token.Equal {
+		p.next()
+		v.DefaultValue = p.parseValue()
+	}
+	v.Directives = p.parseDirectives()
+	return v
+}
+
+func (p *Parser) parseDirectiveDefinition(start token.Token, desc string) *ast.DirectiveDefinition {
+	t := p.next()
+	if !p.expect(t, token.Directive) {
+		return nil
+	}
+	def := &ast.DirectiveDefinition{
+		Name:        ast.GraphQLName(t.Text),
+		Description: desc,
+		Pos:         pos(start),
+	}
+	if p.peek().Type == token.LeftParen {
+		if def.Arguments = p.parseArgumentsDefinition(); def.Arguments == nil {
+			return nil
+		}
+	}
+	if string(p.peek().Text) == "repeatable" {
+		p.next()
+		def.Repeatable = true
+	}
+	if !p.expect(p.next(), token.On) {
+		return nil
+	}
+	if p.peek().Type == token.Pipe {
+		p.next()
+	}
+	for {
+		t := p.next()
+		if !p.expect(t, token.String) {
+			return nil
+		}
+		def.Locations = append(def.Locations, ast.DirectiveLocation(t.Text))
+		if p.peek().Type != token.Pipe {
+			break
+		}
+		p.next()
+	}
+	return def
+}
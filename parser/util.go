@@ -64,8 +64,11 @@ func arrow(buf string, tok token.Token) string {
 	}
 	return ret
 }
+
+// expect reports whether t is of the expected type, when expecting
+// token.String any name, keywords included, is accepted.
 func (p *Parser) expect(t token.Token, expected token.Type) bool {
-	if t.Type != expected {
+	if t.Type != expected && !(expected == token.String && isName(t)) {
 		name := caller()
 		red := color.New(color.FgRed).SprintFunc()
 		errf := ""
@@ -100,7 +103,7 @@ func NewQuery(query []byte) (*ast.Document, error) {
 	var doc ast.Document
 	sq := bytes.NewBuffer([]byte(query))
 	p := New("sq", sq)
-	if p.Decode(&doc); p.curTok.Type == token.Error {
+	if p.Decode(&doc); p.Error != nil {
 		return nil, p.Error
 	} else {
 		return p.Document, nil
@@ -111,7 +114,7 @@ func NewQuery(query []byte) (*ast.Document, error) {
 func (p *Parser) Decode(i interface{}) (err error) {
 	p.Document = (i.(*ast.Document))
 	p.run()
-	if p.curTok.Type == token.Error {
+	if p.Error != nil {
 		return p.Error
 	}
 
@@ -0,0 +1,378 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:generate stringer -type Criticality
+
+package schema // import "sevki.org/graphql/schema"
+
+import (
+	"fmt"
+	"sort"
+
+	"sevki.org/graphql/ast"
+)
+
+// Criticality tells how a change affects clients that were written
+// against the old schema.
+type Criticality int
+
+// Criticalities, from harmless to harmful.
+const (
+	// Safe changes can't break any client.
+	Safe Criticality = iota
+	// Dangerous changes won't break valid queries, but may change
+	// what clients see at runtime, e.g. an enum value they don't
+	// know about.
+	Dangerous
+	// Breaking changes make queries that used to be valid invalid,
+	// or change the shape of the results they get.
+	Breaking
+)
+
+// Change is a single difference between two schemas.
+type Change struct {
+	Criticality Criticality
+	// Path of the changed element, e.g. "User.friends.first" or
+	// "@include".
+	Path    string
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s\t%s\t%s", c.Criticality, c.Path, c.Message)
+}
+
+// Diff lists the changes that take old to new, sorted by path.
+func Diff(old, new *Schema) []Change {
+	d := &differ{}
+
+	for _, op := range []ast.OperationType{ast.Query, ast.Mutation, ast.Subscription} {
+		o, n := old.Roots[op], new.Roots[op]
+		switch {
+		case o == n:
+		case o == "":
+			d.add(Safe, "schema", "%s root type %s was added.", op, n)
+		case n == "":
+			d.add(Breaking, "schema", "%s root type %s was removed.", op, o)
+		default:
+			d.add(Breaking, "schema", "%s root type changed from %s to %s.", op, o, n)
+		}
+	}
+
+	for _, o := range old.TypeList() {
+		n, ok := new.Types[o.Name]
+		if !ok {
+			d.add(Breaking, string(o.Name), "%s %s was removed.", capital(Kind(o.Kind)), o.Name)
+			continue
+		}
+		d.typ(o, n)
+	}
+	for _, n := range new.TypeList() {
+		if _, ok := old.Types[n.Name]; !ok {
+			d.add(Safe, string(n.Name), "%s %s was added.", capital(Kind(n.Kind)), n.Name)
+		}
+	}
+
+	for _, name := range directiveNames(old) {
+		o := old.Directives[name]
+		n, ok := new.Directives[name]
+		if !ok {
+			d.add(Breaking, "@"+string(name), "Directive @%s was removed.", name)
+			continue
+		}
+		d.directive(o, n)
+	}
+	for _, name := range directiveNames(new) {
+		if _, ok := old.Directives[name]; !ok {
+			d.add(Safe, "@"+string(name), "Directive @%s was added.", name)
+		}
+	}
+
+	sort.Stable(byPath(d.changes))
+	return d.changes
+}
+
+// HasBreaking reports whether any of the changes is breaking.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Criticality == Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+type byPath []Change
+
+func (c byPath) Len() int           { return len(c) }
+func (c byPath) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byPath) Less(i, j int) bool { return c[i].Path < c[j].Path }
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(c Criticality, path string, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Criticality: c,
+		Path:        path,
+		Message:     fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *differ) typ(o, n *ast.TypeDefinition) {
+	path := string(o.Name)
+	if o.Kind != n.Kind {
+		d.add(Breaking, path, "%s changed from %s to %s.", o.Name, Kind(o.Kind), Kind(n.Kind))
+		return
+	}
+	if o.Description != n.Description {
+		d.add(Safe, path, "Description of %s changed.", o.Name)
+	}
+
+	switch o.Kind {
+	case ast.ObjectKind, ast.InterfaceKind:
+		for _, i := range o.Interfaces {
+			if !hasName(n.Interfaces, i) {
+				d.add(Breaking, path, "%s no longer implements %s.", o.Name, i)
+			}
+		}
+		for _, i := range n.Interfaces {
+			if !hasName(o.Interfaces, i) {
+				d.add(Dangerous, path, "%s now implements %s.", o.Name, i)
+			}
+		}
+		d.fields(o, n)
+	case ast.InputObjectKind:
+		d.inputFields(o, n)
+	case ast.UnionKind:
+		for _, t := range o.Types {
+			if !hasName(n.Types, t) {
+				d.add(Breaking, path, "%s was removed from union %s.", t, o.Name)
+			}
+		}
+		for _, t := range n.Types {
+			if !hasName(o.Types, t) {
+				d.add(Dangerous, path, "%s was added to union %s.", t, o.Name)
+			}
+		}
+	case ast.EnumKind:
+		for _, v := range o.EnumValues {
+			nv := n.EnumValue(v.Name)
+			if nv == nil {
+				d.add(Breaking, path+"."+string(v.Name), "Enum value %s was removed from %s.", v.Name, o.Name)
+				continue
+			}
+			d.deprecation(path+"."+string(v.Name), "Enum value", v.Directives, nv.Directives)
+		}
+		for _, v := range n.EnumValues {
+			if o.EnumValue(v.Name) == nil {
+				d.add(Dangerous, path+"."+string(v.Name), "Enum value %s was added to %s.", v.Name, o.Name)
+			}
+		}
+	}
+}
+
+func (d *differ) fields(o, n *ast.TypeDefinition) {
+	for _, of := range o.Fields {
+		path := string(o.Name) + "." + string(of.Name)
+		nf := n.Field(of.Name)
+		if nf == nil {
+			d.add(Breaking, path, "Field %s was removed.", path)
+			continue
+		}
+		if !safeOutputChange(of.Type, nf.Type) {
+			d.add(Breaking, path, "Field %s changed type from %s to %s.", path, of.Type, nf.Type)
+		} else if of.Type != nf.Type {
+			d.add(Safe, path, "Field %s changed type from %s to %s.", path, of.Type, nf.Type)
+		}
+		d.deprecation(path, "Field", of.Directives, nf.Directives)
+		d.arguments(path, of.Arguments, nf.Arguments)
+	}
+	for _, nf := range n.Fields {
+		if o.Field(nf.Name) == nil {
+			path := string(o.Name) + "." + string(nf.Name)
+			d.add(Safe, path, "Field %s was added.", path)
+		}
+	}
+}
+
+func (d *differ) inputFields(o, n *ast.TypeDefinition) {
+	for _, of := range o.Fields {
+		path := string(o.Name) + "." + string(of.Name)
+		nf := n.Field(of.Name)
+		if nf == nil {
+			d.add(Breaking, path, "Input field %s was removed.", path)
+			continue
+		}
+		d.inputValue(path, "Input field", of.Type, nf.Type, of.DefaultValue, nf.DefaultValue)
+	}
+	for _, nf := range n.Fields {
+		if o.Field(nf.Name) != nil {
+			continue
+		}
+		path := string(o.Name) + "." + string(nf.Name)
+		if nf.Type.NonNull() && nf.DefaultValue == nil {
+			d.add(Breaking, path, "Required input field %s was added.", path)
+		} else {
+			d.add(Dangerous, path, "Optional input field %s was added.", path)
+		}
+	}
+}
+
+func (d *differ) arguments(path string, o, n []*ast.InputValueDefinition) {
+	for _, oa := range o {
+		apath := path + "." + string(oa.Name)
+		na := find(n, oa.Name)
+		if na == nil {
+			d.add(Breaking, apath, "Argument %s was removed from %s.", oa.Name, path)
+			continue
+		}
+		d.inputValue(apath, "Argument", oa.Type, na.Type, oa.DefaultValue, na.DefaultValue)
+	}
+	for _, na := range n {
+		if find(o, na.Name) != nil {
+			continue
+		}
+		apath := path + "." + string(na.Name)
+		if na.Type.NonNull() && na.DefaultValue == nil {
+			d.add(Breaking, apath, "Required argument %s was added to %s.", na.Name, path)
+		} else {
+			d.add(Safe, apath, "Optional argument %s was added to %s.", na.Name, path)
+		}
+	}
+}
+
+// inputValue compares arguments and input fields.
+func (d *differ) inputValue(path, what string, ot, nt ast.Type, ov, nv ast.Value) {
+	switch {
+	case !safeInputChange(ot, nt):
+		if !ot.NonNull() && nt.NonNull() && ot == nt.Nullable() {
+			d.add(Breaking, path, "%s %s was made required.", what, path)
+		} else {
+			d.add(Breaking, path, "%s %s changed type from %s to %s.", what, path, ot, nt)
+		}
+	case ot != nt:
+		d.add(Safe, path, "%s %s changed type from %s to %s.", what, path, ot, nt)
+	}
+
+	o, n := ast.FormatValue(ov), ast.FormatValue(nv)
+	switch {
+	case o == n:
+	case o == "":
+		d.add(Dangerous, path, "%s %s now defaults to %s.", what, path, n)
+	case n == "":
+		d.add(Dangerous, path, "%s %s no longer defaults to %s.", what, path, o)
+	default:
+		d.add(Dangerous, path, "Default value of %s %s changed from %s to %s.", what, path, o, n)
+	}
+}
+
+func (d *differ) deprecation(path, what string, o, n ast.Directives) {
+	_, od := Deprecated(o)
+	reason, nd := Deprecated(n)
+	switch {
+	case !od && nd:
+		d.add(Safe, path, "%s %s was deprecated: %s", what, path, reason)
+	case od && !nd:
+		d.add(Safe, path, "%s %s is no longer deprecated.", what, path)
+	}
+}
+
+func (d *differ) directive(o, n *ast.DirectiveDefinition) {
+	path := "@" + string(o.Name)
+	if o.Repeatable && !n.Repeatable {
+		d.add(Breaking, path, "Directive %s is no longer repeatable.", path)
+	}
+	for _, l := range o.Locations {
+		if !hasLocation(n.Locations, l) {
+			d.add(Breaking, path, "Location %s was removed from directive %s.", l, path)
+		}
+	}
+	for _, l := range n.Locations {
+		if !hasLocation(o.Locations, l) {
+			d.add(Safe, path, "Location %s was added to directive %s.", l, path)
+		}
+	}
+	d.arguments(path, o.Arguments, n.Arguments)
+}
+
+// safeOutputChange reports whether clients reading a field of type o
+// can read one of type n, which is the case if n is o or a non-null
+// version of it.
+func safeOutputChange(o, n ast.Type) bool {
+	switch {
+	case o.NonNull():
+		return n.NonNull() && safeOutputChange(o.Nullable(), n.Nullable())
+	case n.NonNull():
+		return safeOutputChange(o, n.Nullable())
+	case o.List():
+		return n.List() && safeOutputChange(o.Elem(), n.Elem())
+	default:
+		return !n.List() && o.Name() == n.Name()
+	}
+}
+
+// safeInputChange reports whether values clients send for type o are
+// still valid for type n, which is the case if n is o or a nullable
+// version of it.
+func safeInputChange(o, n ast.Type) bool {
+	switch {
+	case n.NonNull():
+		return o.NonNull() && safeInputChange(o.Nullable(), n.Nullable())
+	case o.NonNull():
+		return safeInputChange(o.Nullable(), n)
+	case o.List():
+		return n.List() && safeInputChange(o.Elem(), n.Elem())
+	default:
+		return !n.List() && o.Name() == n.Name()
+	}
+}
+
+func find(defs []*ast.InputValueDefinition, n ast.GraphQLName) *ast.InputValueDefinition {
+	for _, d := range defs {
+		if d.Name == n {
+			return d
+		}
+	}
+	return nil
+}
+
+func hasName(names []ast.GraphQLName, n ast.GraphQLName) bool {
+	for _, m := range names {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLocation(locs []ast.DirectiveLocation, l ast.DirectiveLocation) bool {
+	for _, m := range locs {
+		if m == l {
+			return true
+		}
+	}
+	return false
+}
+
+func directiveNames(s *Schema) []ast.GraphQLName {
+	names := make([]string, 0, len(s.Directives))
+	for n := range s.Directives {
+		names = append(names, string(n))
+	}
+	sort.Strings(names)
+	ns := make([]ast.GraphQLName, len(names))
+	for i, n := range names {
+		ns[i] = ast.GraphQLName(n)
+	}
+	return ns
+}
+
+func capital(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
@@ -0,0 +1,162 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema // import "sevki.org/graphql/schema"
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"sevki.org/graphql/ast"
+)
+
+func mustParse(t *testing.T, sdl string) *Schema {
+	s, err := Parse("test", strings.NewReader(sdl))
+	if err != nil {
+		t.Fatalf("parsing %q: %v", sdl, err)
+	}
+	return s
+}
+
+func TestParseFixture(t *testing.T) {
+	f, err := os.Open("../tests/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := Parse("schema.graphql", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Root(ast.Query).Name != "Query" {
+		t.Errorf("query root is %s", s.Roots[ast.Query])
+	}
+	var names []string
+	for _, o := range s.PossibleTypes(s.Types["Node"]) {
+		names = append(names, string(o.Name))
+	}
+	if got := strings.Join(names, " "); got != "Friend Story User" {
+		t.Errorf("possible types of Node are %q", got)
+	}
+	if _, ok := s.Directives["skip"]; !ok {
+		t.Error("built-in directives are missing")
+	}
+}
+
+func TestBadSchemas(t *testing.T) {
+	for _, sdl := range []string{
+		`type Foo { id: ID }`,
+		`type Query { foo: Foo }`,
+		`type Query { a: Int } type Query { b: Int }`,
+		`type Query { a(in: Query): Int }`,
+		`input In { a: Int } type Query { a: In }`,
+		`type Query { a: Int } union U = Query | In input In { a: Int }`,
+		`type Query { a: Int } extend type Foo { b: Int }`,
+		`type Query { a: Int } type Foo @specifiedBy(url: "x") { a: Int }`,
+		`type Query { a: Int } scalar Foo @specifiedBy`,
+	} {
+		if _, err := Parse("test", strings.NewReader(sdl)); err == nil {
+			t.Errorf("expected %q to fail", sdl)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := `
+type Query {
+  user(id: ID!, site: Site = MOBILE): User
+  users(first: Int): [User]
+  search: SearchResult
+}
+type User {
+  id: ID!
+  name: String
+  age: Int
+  friends: [User!]!
+}
+type Picture { url: String }
+union SearchResult = User | Picture
+enum Site { DESKTOP MOBILE }
+input Filter { name: String, age: Int! }
+directive @cached(ttl: Int) on FIELD
+`
+	new := `
+type Query {
+  user(id: ID, site: Site = DESKTOP): User
+  users(first: Int!): [User]
+  search: SearchResult
+  me: User
+}
+type User {
+  id: ID!
+  name: String!
+  age: String
+  friends: [User]
+}
+type Picture { url: String }
+union SearchResult = User
+enum Site { DESKTOP MOBILE WAP }
+input Filter { name: String, age: Int, email: String! }
+directive @cached(ttl: Int, scope: String!) on FIELD | FRAGMENT_SPREAD
+`
+	want := map[string]Criticality{
+		"@cached.scope":     Breaking,
+		"@cached":           Safe,
+		"Filter.age":        Safe,
+		"Filter.email":      Breaking,
+		"Query.me":          Safe,
+		"Query.user.id":     Safe,
+		"Query.user.site":   Dangerous,
+		"Query.users.first": Breaking,
+		"SearchResult":      Breaking,
+		"Site.WAP":          Dangerous,
+		"User.age":          Breaking,
+		"User.friends":      Breaking,
+		"User.name":         Safe,
+	}
+	changes := Diff(mustParse(t, old), mustParse(t, new))
+	got := make(map[string]Criticality)
+	for _, c := range changes {
+		got[c.Path] = c.Criticality
+	}
+	for path, crit := range want {
+		if c, ok := got[path]; !ok {
+			t.Errorf("no change for %s", path)
+		} else if c != crit {
+			t.Errorf("%s is %s, expected %s", path, c, crit)
+		}
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			t.Errorf("unexpected change for %s", path)
+		}
+	}
+	if !HasBreaking(changes) {
+		t.Error("expected the diff to be breaking")
+	}
+	if changes := Diff(mustParse(t, old), mustParse(t, old)); len(changes) != 0 {
+		t.Errorf("diffing a schema against itself gave %v", changes)
+	}
+}
+
+func TestDiffRemovals(t *testing.T) {
+	old := mustParse(t, `type Query { a: Int, b: B } type B { c: Int } enum E { X Y }`)
+	new := mustParse(t, `type Query { a: Int } enum E { X }`)
+	var msgs []string
+	for _, c := range Diff(old, new) {
+		if c.Criticality != Breaking {
+			t.Errorf("%s should be breaking", c)
+		}
+		msgs = append(msgs, c.Message)
+	}
+	want := []string{
+		"Object type B was removed.",
+		"Enum value Y was removed from E.",
+		"Field Query.b was removed.",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, expected %q", msgs, want)
+	}
+}
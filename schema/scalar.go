@@ -0,0 +1,76 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema // import "sevki.org/graphql/schema"
+
+import (
+	"fmt"
+
+	"sevki.org/graphql/ast"
+)
+
+// Scalar implements a custom scalar type, e.g. DateTime or UUID, as
+// defined in http://facebook.github.io/graphql/#sec-Scalars
+//
+// Custom scalars without an implementation accept any input and
+// results are sent as they are.
+type Scalar interface {
+	// ParseLiteral turns a value written in a document into the Go
+	// value resolvers get as an argument. Validation uses it to
+	// check literals. Variables nested in v are replaced by their
+	// values before execution calls it, validation skips literals
+	// that hold variables.
+	ParseLiteral(v ast.Value) (interface{}, error)
+	// ParseValue turns a variable, as decoded from the request, into
+	// the Go value resolvers get as an argument.
+	ParseValue(v interface{}) (interface{}, error)
+	// Serialize turns a value a resolver returned into one that can
+	// be written to the response.
+	Serialize(v interface{}) (interface{}, error)
+}
+
+// SetScalar makes sc the implementation of the scalar type named n.
+// Built-in scalars can't be replaced.
+func (s *Schema) SetScalar(n ast.GraphQLName, sc Scalar) error {
+	t, ok := s.Types[n]
+	switch {
+	case !ok:
+		return fmt.Errorf("scalar %s is not defined", n)
+	case t.Kind != ast.ScalarKind:
+		return fmt.Errorf("%s is a %s, not a scalar", n, Kind(t.Kind))
+	case IsBuiltinScalar(n):
+		return fmt.Errorf("built-in scalar %s can't be replaced", n)
+	}
+	if s.Scalars == nil {
+		s.Scalars = make(map[ast.GraphQLName]Scalar)
+	}
+	s.Scalars[n] = sc
+	return nil
+}
+
+// IsBuiltinScalar reports whether n is one of the scalars every
+// schema has, as defined in
+// http://facebook.github.io/graphql/#sec-Scalars
+func IsBuiltinScalar(n ast.GraphQLName) bool {
+	switch n {
+	case "Int", "Float", "String", "Boolean", "ID":
+		return true
+	}
+	return false
+}
+
+// SpecifiedBy returns the URL of the specification of a custom scalar
+// given by its @specifiedBy directive.
+func SpecifiedBy(t *ast.TypeDefinition) (string, bool) {
+	d := t.Directives.Get("specifiedBy")
+	if d == nil {
+		return "", false
+	}
+	u, ok := d.Arguments.Get("url")
+	if !ok {
+		return "", false
+	}
+	s, ok := u.(ast.GraphQLString)
+	return string(s), ok
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema // import "sevki.org/graphql/schema"
+
+import (
+	"testing"
+
+	"sevki.org/graphql/ast"
+)
+
+type noScalar struct{}
+
+func (noScalar) ParseLiteral(v ast.Value) (interface{}, error) { return nil, nil }
+func (noScalar) ParseValue(v interface{}) (interface{}, error) { return nil, nil }
+func (noScalar) Serialize(v interface{}) (interface{}, error)  { return nil, nil }
+
+func TestScalars(t *testing.T) {
+	s := mustParse(t, `type Query { a: Time } scalar Time @specifiedBy(url: "https://tools.ietf.org/html/rfc3339") scalar Raw`)
+	if u, ok := SpecifiedBy(s.Types["Time"]); !ok || u != "https://tools.ietf.org/html/rfc3339" {
+		t.Errorf("Time is specified by %q", u)
+	}
+	if _, ok := SpecifiedBy(s.Types["Raw"]); ok {
+		t.Error("Raw has no @specifiedBy")
+	}
+	if err := s.SetScalar("Time", noScalar{}); err != nil {
+		t.Error(err)
+	}
+	for _, n := range []ast.GraphQLName{"Int", "Query", "Nope"} {
+		if err := s.SetScalar(n, noScalar{}); err == nil {
+			t.Errorf("expected setting %s to fail", n)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schema builds GraphQL type systems out of documents
+// written in the schema definition language, as defined in
+// http://facebook.github.io/graphql/#sec-Type-System
+package schema // import "sevki.org/graphql/schema"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/parser"
+)
+
+// Schema is a type system, with the built-in scalars and directives
+// added to the ones the document defines.
+type Schema struct {
+	Description string
+	// Types maps names to named types.
+	Types map[ast.GraphQLName]*ast.TypeDefinition
+	// Directives maps names to directive definitions.
+	Directives map[ast.GraphQLName]*ast.DirectiveDefinition
+	// Roots maps operation types to the object types that serve
+	// them.
+	Roots map[ast.OperationType]ast.GraphQLName
+	// SchemaDirectives are the directives of the schema definition.
+	SchemaDirectives ast.Directives
+	// Scalars maps names of custom scalars to their implementations,
+	// see SetScalar.
+	Scalars map[ast.GraphQLName]Scalar
+}
+
+const prelude = `
+"The Int scalar type represents non-fractional signed whole numeric values."
+scalar Int
+"The Float scalar type represents signed double-precision fractional values."
+scalar Float
+"The String scalar type represents textual data."
+scalar String
+"The Boolean scalar type represents true or false."
+scalar Boolean
+"The ID scalar type represents a unique identifier."
+scalar ID
+
+"Directs the executor to skip this field or fragment when the if argument is true."
+directive @skip(if: Boolean!) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+"Directs the executor to include this field or fragment only when the if argument is true."
+directive @include(if: Boolean!) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+"Marks an element of a GraphQL schema as no longer supported."
+directive @deprecated(reason: String = "No longer supported") on
+  | FIELD_DEFINITION
+  | ARGUMENT_DEFINITION
+  | INPUT_FIELD_DEFINITION
+  | ENUM_VALUE
+"Exposes a URL that specifies the behaviour of this scalar."
+directive @specifiedBy(url: String!) on SCALAR
+"Directs the executor to deliver this fragment or field after the rest of the response."
+directive @defer(label: String, if: Boolean! = true) on FRAGMENT_SPREAD | INLINE_FRAGMENT | FIELD
+"Directs the executor to deliver the items of this list after the first initialCount ones one at a time."
+directive @stream(label: String, if: Boolean! = true, initialCount: Int = 0) on FIELD
+`
+
+// Parse parses the SDL document in r and builds a schema out of it.
+func Parse(name string, r io.Reader) (*Schema, error) {
+	var doc ast.Document
+	if err := parser.New(name, r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return New(&doc)
+}
+
+// New builds a schema out of the type system definitions in doc.
+// Executable definitions in doc are ignored.
+func New(doc *ast.Document) (*Schema, error) {
+	var builtins ast.Document
+	if err := parser.New("prelude", bytes.NewBufferString(prelude)).Decode(&builtins); err != nil {
+		return nil, err
+	}
+	s := &Schema{
+		Types:      make(map[ast.GraphQLName]*ast.TypeDefinition),
+		Directives: make(map[ast.GraphQLName]*ast.DirectiveDefinition),
+		Roots:      make(map[ast.OperationType]ast.GraphQLName),
+	}
+	var extensions []ast.Definition
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.TypeDefinition:
+			if d.Extension {
+				extensions = append(extensions, d)
+				continue
+			}
+		case *ast.SchemaDefinition:
+			if d.Extension {
+				extensions = append(extensions, d)
+				continue
+			}
+		}
+		if err := s.add(def); err != nil {
+			return nil, err
+		}
+	}
+	// built-ins may be redefined by the document.
+	for _, def := range builtins.Definitions {
+		switch d := def.(type) {
+		case *ast.TypeDefinition:
+			if _, ok := s.Types[d.Name]; !ok {
+				s.Types[d.Name] = d
+			}
+		case *ast.DirectiveDefinition:
+			if _, ok := s.Directives[d.Name]; !ok {
+				s.Directives[d.Name] = d
+			}
+		}
+	}
+	for _, def := range extensions {
+		if err := s.extend(def); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.Roots) == 0 {
+		for op, n := range map[ast.OperationType]ast.GraphQLName{
+			ast.Query:        "Query",
+			ast.Mutation:     "Mutation",
+			ast.Subscription: "Subscription",
+		} {
+			if t, ok := s.Types[n]; ok && t.Kind == ast.ObjectKind {
+				s.Roots[op] = n
+			}
+		}
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) add(def ast.Definition) error {
+	switch d := def.(type) {
+	case *ast.SchemaDefinition:
+		if len(s.Roots) > 0 {
+			return fmt.Errorf("%s: there can be only one schema definition", d.Pos)
+		}
+		s.Description = d.Description
+		s.SchemaDirectives = d.Directives
+		for op, n := range d.OperationTypes {
+			s.Roots[op] = n
+		}
+	case *ast.TypeDefinition:
+		if old, ok := s.Types[d.Name]; ok {
+			return fmt.Errorf("%s: type %s is already defined at %s", d.Pos, d.Name, old.Pos)
+		}
+		s.Types[d.Name] = d
+	case *ast.DirectiveDefinition:
+		if old, ok := s.Directives[d.Name]; ok {
+			return fmt.Errorf("%s: directive @%s is already defined at %s", d.Pos, d.Name, old.Pos)
+		}
+		s.Directives[d.Name] = d
+	}
+	return nil
+}
+
+// extend merges type and schema extensions into the definitions they
+// extend. The definitions are copied so the document stays intact.
+func (s *Schema) extend(def ast.Definition) error {
+	switch d := def.(type) {
+	case *ast.SchemaDefinition:
+		for op, n := range d.OperationTypes {
+			if _, ok := s.Roots[op]; ok {
+				return fmt.Errorf("%s: %s root type is already defined", d.Pos, op)
+			}
+			s.Roots[op] = n
+		}
+		s.SchemaDirectives = mergeDirectives(s.SchemaDirectives, d.Directives)
+	case *ast.TypeDefinition:
+		old, ok := s.Types[d.Name]
+		if !ok {
+			return fmt.Errorf("%s: can't extend undefined type %s", d.Pos, d.Name)
+		}
+		if old.Kind != d.Kind {
+			return fmt.Errorf("%s: can't extend %s with a %s", d.Pos, old.Name, Kind(d.Kind))
+		}
+		t := *old
+		t.Interfaces = append(append([]ast.GraphQLName{}, old.Interfaces...), d.Interfaces...)
+		t.Fields = append(append([]*ast.FieldDefinition{}, old.Fields...), d.Fields...)
+		t.Types = append(append([]ast.GraphQLName{}, old.Types...), d.Types...)
+		t.EnumValues = append(append([]*ast.EnumValueDefinition{}, old.EnumValues...), d.EnumValues...)
+		t.Directives = mergeDirectives(old.Directives, d.Directives)
+		s.Types[d.Name] = &t
+	}
+	return nil
+}
+
+func mergeDirectives(a, b ast.Directives) ast.Directives {
+	if len(b) == 0 {
+		return a
+	}
+	return append(append(ast.Directives{}, a...), b...)
+}
+
+// check makes sure every type the schema refers to is defined and is
+// of a kind that is allowed where it is used.
+func (s *Schema) check() error {
+	if _, ok := s.Roots[ast.Query]; !ok {
+		return fmt.Errorf("schema has no query root type")
+	}
+	for op, n := range s.Roots {
+		if t := s.Types[n]; t == nil || t.Kind != ast.ObjectKind {
+			return fmt.Errorf("%s root type %s must be a defined object type", op, n)
+		}
+	}
+	for _, t := range s.TypeList() {
+		seen := make(map[ast.GraphQLName]bool)
+		for _, f := range t.Fields {
+			if seen[f.Name] {
+				return fmt.Errorf("%s: field %s.%s is defined more than once", f.Pos, t.Name, f.Name)
+			}
+			seen[f.Name] = true
+			if t.Kind == ast.InputObjectKind {
+				if !s.IsInputType(f.Type) {
+					return fmt.Errorf("%s: type of %s.%s must be an input type, got %s", f.Pos, t.Name, f.Name, f.Type)
+				}
+				continue
+			}
+			if !s.IsOutputType(f.Type) {
+				return fmt.Errorf("%s: type of %s.%s must be an output type, got %s", f.Pos, t.Name, f.Name, f.Type)
+			}
+			for _, a := range f.Arguments {
+				if !s.IsInputType(a.Type) {
+					return fmt.Errorf("%s: type of %s.%s(%s:) must be an input type, got %s", a.Pos, t.Name, f.Name, a.Name, a.Type)
+				}
+			}
+		}
+		for _, n := range t.Interfaces {
+			if i := s.Types[n]; i == nil || i.Kind != ast.InterfaceKind {
+				return fmt.Errorf("%s: %s can only implement defined interfaces, got %s", t.Pos, t.Name, n)
+			}
+		}
+		for _, n := range t.Types {
+			if m := s.Types[n]; m == nil || m.Kind != ast.ObjectKind {
+				return fmt.Errorf("%s: members of union %s must be defined object types, got %s", t.Pos, t.Name, n)
+			}
+		}
+		if d := t.Directives.Get("specifiedBy"); d != nil {
+			if t.Kind != ast.ScalarKind {
+				return fmt.Errorf("%s: @specifiedBy can only be used on scalars, not on %s %s", d.Pos, Kind(t.Kind), t.Name)
+			}
+			if _, ok := SpecifiedBy(t); !ok {
+				return fmt.Errorf("%s: @specifiedBy on %s needs a url string", d.Pos, t.Name)
+			}
+		}
+	}
+	for _, d := range s.Directives {
+		for _, a := range d.Arguments {
+			if !s.IsInputType(a.Type) {
+				return fmt.Errorf("%s: type of @%s(%s:) must be an input type, got %s", a.Pos, d.Name, a.Name, a.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// TypeList returns the named types of the schema sorted by name.
+func (s *Schema) TypeList() []*ast.TypeDefinition {
+	names := make([]string, 0, len(s.Types))
+	for n := range s.Types {
+		names = append(names, string(n))
+	}
+	sort.Strings(names)
+	types := make([]*ast.TypeDefinition, len(names))
+	for i, n := range names {
+		types[i] = s.Types[ast.GraphQLName(n)]
+	}
+	return types
+}
+
+// Type returns the named type t wraps, nil if it isn't defined.
+func (s *Schema) Type(t ast.Type) *ast.TypeDefinition {
+	return s.Types[t.Name()]
+}
+
+// Root returns the object type that serves op, nil if the schema
+// doesn't support op.
+func (s *Schema) Root(op ast.OperationType) *ast.TypeDefinition {
+	n, ok := s.Roots[op]
+	if !ok {
+		return nil
+	}
+	return s.Types[n]
+}
+
+// typenameField is the meta field every composite type has.
+var typenameField = &ast.FieldDefinition{
+	Name:        "__typename",
+	Description: "The name of the object type being queried.",
+	Type:        "String!",
+}
+
+// Field returns the field named n of t, the __typename meta field
+// included, nil if there is no such field.
+func (s *Schema) Field(t *ast.TypeDefinition, n ast.GraphQLName) *ast.FieldDefinition {
+	switch t.Kind {
+	case ast.ObjectKind, ast.InterfaceKind, ast.UnionKind:
+		if n == typenameField.Name {
+			return typenameField
+		}
+		return t.Field(n)
+	}
+	return nil
+}
+
+// IsInputType reports whether t can be used for arguments, variables
+// and input object fields.
+func (s *Schema) IsInputType(t ast.Type) bool {
+	d := s.Type(t)
+	if d == nil {
+		return false
+	}
+	switch d.Kind {
+	case ast.ScalarKind, ast.EnumKind, ast.InputObjectKind:
+		return true
+	}
+	return false
+}
+
+// IsOutputType reports whether t can be used for fields.
+func (s *Schema) IsOutputType(t ast.Type) bool {
+	d := s.Type(t)
+	return d != nil && d.Kind != ast.InputObjectKind
+}
+
+// IsLeafType reports whether the named type t wraps is a scalar or
+// an enum.
+func (s *Schema) IsLeafType(t ast.Type) bool {
+	d := s.Type(t)
+	return d != nil && (d.Kind == ast.ScalarKind || d.Kind == ast.EnumKind)
+}
+
+// IsCompositeType reports whether the named type t wraps is an
+// object, an interface or a union.
+func (s *Schema) IsCompositeType(t ast.Type) bool {
+	d := s.Type(t)
+	return d != nil && (d.Kind == ast.ObjectKind || d.Kind == ast.InterfaceKind || d.Kind == ast.UnionKind)
+}
+
+// IsAbstractType reports whether the named type t wraps is an
+// interface or a union.
+func (s *Schema) IsAbstractType(t ast.Type) bool {
+	d := s.Type(t)
+	return d != nil && (d.Kind == ast.InterfaceKind || d.Kind == ast.UnionKind)
+}
+
+// PossibleTypes returns the object types that can be returned where
+// the named type t is expected, sorted by name.
+func (s *Schema) PossibleTypes(t *ast.TypeDefinition) []*ast.TypeDefinition {
+	switch t.Kind {
+	case ast.ObjectKind:
+		return []*ast.TypeDefinition{t}
+	case ast.UnionKind:
+		var types []*ast.TypeDefinition
+		for _, d := range s.TypeList() {
+			for _, n := range t.Types {
+				if d.Name == n {
+					types = append(types, d)
+				}
+			}
+		}
+		return types
+	case ast.InterfaceKind:
+		var types []*ast.TypeDefinition
+		for _, d := range s.TypeList() {
+			if d.Kind == ast.ObjectKind && implements(d, t.Name) {
+				types = append(types, d)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// IsPossibleType reports whether the object type obj can be returned
+// where abstract is expected.
+func (s *Schema) IsPossibleType(abstract, obj *ast.TypeDefinition) bool {
+	switch abstract.Kind {
+	case ast.ObjectKind:
+		return abstract.Name == obj.Name
+	case ast.UnionKind:
+		for _, n := range abstract.Types {
+			if n == obj.Name {
+				return true
+			}
+		}
+	case ast.InterfaceKind:
+		return implements(obj, abstract.Name)
+	}
+	return false
+}
+
+func implements(t *ast.TypeDefinition, iface ast.GraphQLName) bool {
+	for _, n := range t.Interfaces {
+		if n == iface {
+			return true
+		}
+	}
+	return false
+}
+
+// Kind returns the SDL keyword for k, used in messages.
+func Kind(k ast.TypeKind) string {
+	switch k {
+	case ast.ScalarKind:
+		return "scalar"
+	case ast.ObjectKind:
+		return "object type"
+	case ast.InterfaceKind:
+		return "interface"
+	case ast.UnionKind:
+		return "union"
+	case ast.EnumKind:
+		return "enum"
+	case ast.InputObjectKind:
+		return "input object"
+	}
+	return k.String()
+}
+
+// Deprecated reports whether dirs has @deprecated and returns the
+// reason it gives.
+func Deprecated(dirs ast.Directives) (string, bool) {
+	d := dirs.Get("deprecated")
+	if d == nil {
+		return "", false
+	}
+	if r, ok := d.Arguments.Get("reason"); ok {
+		if r, ok := r.(ast.GraphQLString); ok {
+			return string(r), true
+		}
+	}
+	return "No longer supported", true
+}
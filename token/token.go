@@ -49,4 +49,9 @@ const (
 	On
 	True
 	False
+	Null
+	SubscriptionStart
+	Bang
+	Amp
+	BlockQuote
 )
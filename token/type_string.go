@@ -4,9 +4,9 @@ package token // import "sevki.org/graphql/token"
 
 import "fmt"
 
-const _Type_name = "EOFErrorNewlineStringSpaceNumberFloatHexLeftCurlyRightCurlyLeftParenRightParenLeftBracRightBracQuoteEqualColonCommaSemicolonPeriodCommentPipeVariableElipsisKeyDirectiveFragmentStartQueryStartMutationStartOnTrueFalse"
+const _Type_name = "EOFErrorNewlineStringSpaceNumberFloatHexLeftCurlyRightCurlyLeftParenRightParenLeftBracRightBracQuoteEqualColonCommaSemicolonPeriodCommentPipeVariableElipsisKeyDirectiveFragmentStartQueryStartMutationStartOnTrueFalseNullSubscriptionStartBangAmpBlockQuote"
 
-var _Type_index = [...]uint8{0, 3, 8, 15, 21, 26, 32, 37, 40, 49, 59, 68, 78, 86, 95, 100, 105, 110, 115, 124, 130, 137, 141, 149, 156, 159, 168, 181, 191, 204, 206, 210, 215}
+var _Type_index = [...]uint8{0, 3, 8, 15, 21, 26, 32, 37, 40, 49, 59, 68, 78, 86, 95, 100, 105, 110, 115, 124, 130, 137, 141, 149, 156, 159, 168, 181, 191, 204, 206, 210, 215, 219, 236, 240, 243, 253}
 
 func (i Type) String() string {
 	if i < 0 || i+1 >= Type(len(_Type_index)) {
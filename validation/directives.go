@@ -0,0 +1,58 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import "sevki.org/graphql/ast"
+
+// knownDirectives as defined in
+// http://facebook.github.io/graphql/#sec-Directives-Are-Defined and
+// http://facebook.github.io/graphql/#sec-Directives-Are-In-Valid-Locations
+func knownDirectives(c *context) {
+	walk(c.doc, func(n interface{}) {
+		dirs, loc := directivesOf(n)
+		for _, d := range dirs {
+			def, ok := c.schema.Directives[d.Name]
+			if !ok {
+				c.errorf(at(d.Pos), "Unknown directive \"@%s\".", d.Name)
+				continue
+			}
+			if !hasLocation(def.Locations, loc) {
+				c.errorf(at(d.Pos), "Directive \"@%s\" may not be used on %s.", d.Name, loc)
+			}
+		}
+	})
+}
+
+// directives calls fn for every directive in the document that is
+// declared by the schema.
+func (c *context) directives(fn func(d *ast.Directive, def *ast.DirectiveDefinition)) {
+	walk(c.doc, func(n interface{}) {
+		dirs, _ := directivesOf(n)
+		for _, d := range dirs {
+			if def, ok := c.schema.Directives[d.Name]; ok {
+				fn(d, def)
+			}
+		}
+	})
+}
+
+// repeatable reports whether the directive named n may be used more
+// than once at a location. Without a schema no directive is.
+func (c *context) repeatable(n ast.GraphQLName) bool {
+	if c.schema == nil {
+		return false
+	}
+	def, ok := c.schema.Directives[n]
+	return ok && def.Repeatable
+}
+
+func hasLocation(locs []ast.DirectiveLocation, l ast.DirectiveLocation) bool {
+	for _, m := range locs {
+		if m == l {
+			return true
+		}
+	}
+	return false
+}
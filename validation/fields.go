@@ -0,0 +1,261 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import "sevki.org/graphql/ast"
+
+// walkTypes walks the document like walk, and tells fn the type the
+// selection n is made on and, for fields, the definition of the
+// field. Either is nil when it isn't known, e.g. below a field that
+// doesn't exist. For fragment definitions parent is the type they
+// are on.
+func (c *context) walkTypes(fn func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition)) {
+	for _, d := range c.doc.Definitions {
+		switch d := d.(type) {
+		case *ast.Operation:
+			root := c.schema.Root(d.OperationType)
+			fn(d, root, nil)
+			c.walkSelectionTypes(d.SelectionSet, root, fn)
+		case *ast.Fragment:
+			t := c.composite(d.TypeCondition)
+			fn(d, t, nil)
+			c.walkSelectionTypes(d.SelectionSet, t, fn)
+		}
+	}
+}
+
+func (c *context) walkSelectionTypes(set ast.SelectionSet, parent *ast.TypeDefinition, fn func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition)) {
+	for _, s := range set {
+		switch s := s.(type) {
+		case *ast.Field:
+			var def *ast.FieldDefinition
+			if parent != nil {
+				def = c.schema.Field(parent, s.Name)
+			}
+			fn(s, parent, def)
+			// leaf types have no fields to look up.
+			var t *ast.TypeDefinition
+			if def != nil && c.schema.IsCompositeType(def.Type) {
+				t = c.schema.Type(def.Type)
+			}
+			c.walkSelectionTypes(s.SelectionSet, t, fn)
+		case *ast.Fragment:
+			fn(s, parent, nil)
+			t := parent
+			if s.TypeCondition != "" {
+				t = c.composite(s.TypeCondition)
+			}
+			c.walkSelectionTypes(s.SelectionSet, t, fn)
+		}
+	}
+}
+
+// composite returns the composite type named n, or nil.
+func (c *context) composite(n ast.GraphQLName) *ast.TypeDefinition {
+	if !c.schema.IsCompositeType(ast.Type(n)) {
+		return nil
+	}
+	return c.schema.Types[n]
+}
+
+// knownOperationTypes makes sure the schema supports the operations
+// in the document.
+func knownOperationTypes(c *context) {
+	for _, op := range c.operations() {
+		if c.schema.Root(op.OperationType) == nil {
+			c.errorf(at(op.Pos), "Schema is not configured for %s operations.", op.OperationType)
+		}
+	}
+}
+
+// fieldsOnCorrectType as defined in
+// http://facebook.github.io/graphql/#sec-Field-Selections-on-Objects-Interfaces-and-Unions-Types
+func fieldsOnCorrectType(c *context) {
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		if f, ok := n.(*ast.Field); ok && parent != nil && def == nil {
+			c.errorf(at(f.Pos), "Cannot query field %q on type %q.", f.Name, parent.Name)
+		}
+	})
+}
+
+// scalarLeafs as defined in
+// http://facebook.github.io/graphql/#sec-Leaf-Field-Selections
+func scalarLeafs(c *context) {
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		f, ok := n.(*ast.Field)
+		if !ok || def == nil || c.schema.Type(def.Type) == nil {
+			return
+		}
+		switch leaf := c.schema.IsLeafType(def.Type); {
+		case leaf && len(f.SelectionSet) > 0:
+			c.errorf(at(f.Pos), "Field %q must not have a selection since type %q has no subfields.", f.Name, def.Type)
+		case !leaf && len(f.SelectionSet) == 0:
+			c.errorf(at(f.Pos), "Field %q of type %q must have a selection of subfields. Did you mean \"%s { ... }\"?", f.Name, def.Type, f.Name)
+		}
+	})
+}
+
+// knownArgumentNames as defined in
+// http://facebook.github.io/graphql/#sec-Argument-Names
+func knownArgumentNames(c *context) {
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		f, ok := n.(*ast.Field)
+		if !ok || def == nil {
+			return
+		}
+		for _, a := range f.Arguments {
+			if def.Argument(a.Name) == nil {
+				c.errorf(at(a.Pos), "Unknown argument %q on field \"%s.%s\".", a.Name, parent.Name, f.Name)
+			}
+		}
+	})
+	c.directives(func(d *ast.Directive, def *ast.DirectiveDefinition) {
+		for _, a := range d.Arguments {
+			if def.Argument(a.Name) == nil {
+				c.errorf(at(a.Pos), "Unknown argument %q on directive \"@%s\".", a.Name, d.Name)
+			}
+		}
+	})
+}
+
+// providedRequiredArguments as defined in
+// http://facebook.github.io/graphql/#sec-Required-Arguments
+func providedRequiredArguments(c *context) {
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		f, ok := n.(*ast.Field)
+		if !ok || def == nil {
+			return
+		}
+		for _, a := range def.Arguments {
+			if !a.Type.NonNull() || a.DefaultValue != nil {
+				continue
+			}
+			if _, ok := f.Arguments.Get(string(a.Name)); !ok {
+				c.errorf(at(f.Pos), "Field %q argument %q of type %q is required, but it was not provided.", f.Name, a.Name, a.Type)
+			}
+		}
+	})
+	c.directives(func(d *ast.Directive, def *ast.DirectiveDefinition) {
+		for _, a := range def.Arguments {
+			if !a.Type.NonNull() || a.DefaultValue != nil {
+				continue
+			}
+			if _, ok := d.Arguments.Get(string(a.Name)); !ok {
+				c.errorf(at(d.Pos), "Directive \"@%s\" argument %q of type %q is required, but it was not provided.", d.Name, a.Name, a.Type)
+			}
+		}
+	})
+}
+
+// valuesOfCorrectType as defined in
+// http://facebook.github.io/graphql/#sec-Values-of-Correct-Type
+func valuesOfCorrectType(c *context) {
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		f, ok := n.(*ast.Field)
+		if !ok || def == nil {
+			return
+		}
+		for _, a := range f.Arguments {
+			if ad := def.Argument(a.Name); ad != nil {
+				c.checkValue(a.Value, ad.Type, a.Pos)
+			}
+		}
+	})
+	c.directives(func(d *ast.Directive, def *ast.DirectiveDefinition) {
+		for _, a := range d.Arguments {
+			if ad := def.Argument(a.Name); ad != nil {
+				c.checkValue(a.Value, ad.Type, a.Pos)
+			}
+		}
+	})
+	for _, op := range c.operations() {
+		for _, v := range op.VariableDefinitions {
+			if v.DefaultValue != nil && c.schema.IsInputType(v.Type) {
+				c.checkValue(v.DefaultValue, v.Type, v.Pos)
+			}
+		}
+	}
+}
+
+// knownTypeNames as defined in
+// http://facebook.github.io/graphql/#sec-Fragment-Spread-Type-Existence
+// applied to type conditions and variable types.
+func knownTypeNames(c *context) {
+	walk(c.doc, func(n interface{}) {
+		if f, ok := n.(*ast.Fragment); ok && f.TypeCondition != "" {
+			if _, ok := c.schema.Types[f.TypeCondition]; !ok {
+				c.errorf(at(f.Pos), "Unknown type %q.", f.TypeCondition)
+			}
+		}
+	})
+	for _, op := range c.operations() {
+		for _, v := range op.VariableDefinitions {
+			if c.schema.Type(v.Type) == nil {
+				c.errorf(at(v.Pos), "Unknown type %q.", v.Type.Name())
+			}
+		}
+	}
+}
+
+// fragmentsOnCompositeTypes as defined in
+// http://facebook.github.io/graphql/#sec-Fragments-On-Composite-Types
+func fragmentsOnCompositeTypes(c *context) {
+	walk(c.doc, func(n interface{}) {
+		f, ok := n.(*ast.Fragment)
+		if !ok || f.TypeCondition == "" {
+			return
+		}
+		t := ast.Type(f.TypeCondition)
+		if c.schema.Type(t) == nil || c.schema.IsCompositeType(t) {
+			return
+		}
+		if f.FragmentName != "" {
+			c.errorf(at(f.Pos), "Fragment %q cannot condition on non composite type %q.", f.FragmentName, t)
+		} else {
+			c.errorf(at(f.Pos), "Fragment cannot condition on non composite type %q.", t)
+		}
+	})
+}
+
+// possibleFragmentSpreads as defined in
+// http://facebook.github.io/graphql/#sec-Fragment-spread-is-possible
+func possibleFragmentSpreads(c *context) {
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		f, ok := n.(*ast.Fragment)
+		if !ok || parent == nil {
+			return
+		}
+		var t *ast.TypeDefinition
+		switch {
+		case isSpread(f):
+			if frag, ok := c.fragments[f.FragmentName]; ok {
+				t = c.schema.Types[frag.TypeCondition]
+			}
+		case f.FragmentName == "" && f.TypeCondition != "":
+			t = c.schema.Types[f.TypeCondition]
+		}
+		if t == nil || !c.schema.IsCompositeType(ast.Type(t.Name)) || !c.schema.IsCompositeType(ast.Type(parent.Name)) {
+			return
+		}
+		if c.overlap(parent, t) {
+			return
+		}
+		if isSpread(f) {
+			c.errorf(at(f.Pos), "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", f.FragmentName, parent.Name, t.Name)
+		} else {
+			c.errorf(at(f.Pos), "Fragment cannot be spread here as objects of type %q can never be of type %q.", parent.Name, t.Name)
+		}
+	})
+}
+
+// overlap reports whether an object can be of both types a and b.
+func (c *context) overlap(a, b *ast.TypeDefinition) bool {
+	for _, x := range c.schema.PossibleTypes(a) {
+		if c.schema.IsPossibleType(b, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import (
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// uniqueFragmentNames as defined in
+// http://facebook.github.io/graphql/#sec-Fragment-Name-Uniqueness
+func uniqueFragmentNames(c *context) {
+	for _, def := range c.doc.Definitions {
+		f, ok := def.(*ast.Fragment)
+		if !ok {
+			continue
+		}
+		if first := c.fragments[f.FragmentName]; first != f {
+			c.errorf(at(first.Pos, f.Pos), "There can be only one fragment named %q.", f.FragmentName)
+		}
+	}
+}
+
+// knownFragmentNames as defined in
+// http://facebook.github.io/graphql/#sec-Fragment-spread-target-defined
+func knownFragmentNames(c *context) {
+	walk(c.doc, func(n interface{}) {
+		if f, ok := n.(*ast.Fragment); ok && isSpread(f) {
+			if _, ok := c.fragments[f.FragmentName]; !ok {
+				c.errorf(at(f.Pos), "Unknown fragment %q.", f.FragmentName)
+			}
+		}
+	})
+}
+
+// noUnusedFragments as defined in
+// http://facebook.github.io/graphql/#sec-Fragments-Must-Be-Used
+func noUnusedFragments(c *context) {
+	used := make(map[ast.GraphQLName]bool)
+	for _, def := range c.doc.Definitions {
+		if op, ok := def.(*ast.Operation); ok {
+			for _, f := range c.reachableFragments(op.SelectionSet) {
+				used[f.FragmentName] = true
+			}
+		}
+	}
+	for _, def := range c.doc.Definitions {
+		if f, ok := def.(*ast.Fragment); ok && !used[f.FragmentName] {
+			c.errorf(at(f.Pos), "Fragment %q is never used.", f.FragmentName)
+		}
+	}
+}
+
+// noFragmentCycles as defined in
+// http://facebook.github.io/graphql/#sec-Fragment-spreads-must-not-form-cycles
+//
+// Every fragment is walked once, the spreads on the way to the
+// current fragment are kept on a stack so a cycle can be reported
+// with its full path.
+func noFragmentCycles(c *context) {
+	visited := make(map[ast.GraphQLName]bool)
+	// index of the spread of a fragment on the stack.
+	onStack := make(map[ast.GraphQLName]int)
+	var stack []*ast.Fragment
+
+	var detect func(frag *ast.Fragment)
+	detect = func(frag *ast.Fragment) {
+		if visited[frag.FragmentName] {
+			return
+		}
+		visited[frag.FragmentName] = true
+		onStack[frag.FragmentName] = len(stack)
+
+		for _, spread := range spreads(frag.SelectionSet) {
+			target, ok := c.fragments[spread.FragmentName]
+			if !ok {
+				continue
+			}
+			i, cycle := onStack[spread.FragmentName]
+			if !cycle {
+				stack = append(stack, spread)
+				detect(target)
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			path := append(append([]*ast.Fragment{}, stack[i:]...), spread)
+			names := []string{string(spread.FragmentName)}
+			var locs []ast.Position
+			for _, s := range path {
+				names = append(names, string(s.FragmentName))
+				locs = append(locs, s.Pos)
+			}
+			c.errorf(locs, "Cannot spread fragment %q within itself: %s.", spread.FragmentName, strings.Join(names, " -> "))
+		}
+		delete(onStack, frag.FragmentName)
+	}
+
+	for _, def := range c.doc.Definitions {
+		if f, ok := def.(*ast.Fragment); ok && c.fragments[f.FragmentName] == f {
+			detect(f)
+		}
+	}
+}
+
+// spreads returns the fragment spreads in set and its nested selection
+// sets, without following them.
+func spreads(set ast.SelectionSet) []*ast.Fragment {
+	var found []*ast.Fragment
+	walkSelections(set, func(n interface{}) {
+		if f, ok := n.(*ast.Fragment); ok && isSpread(f) {
+			found = append(found, f)
+		}
+	})
+	return found
+}
+
+// reachableFragments returns the definitions of the fragments set
+// spreads, directly or through other fragments.
+func (c *context) reachableFragments(set ast.SelectionSet) []*ast.Fragment {
+	var found []*ast.Fragment
+	seen := make(map[ast.GraphQLName]bool)
+	sets := []ast.SelectionSet{set}
+	for len(sets) > 0 {
+		set, sets = sets[0], sets[1:]
+		for _, s := range spreads(set) {
+			frag, ok := c.fragments[s.FragmentName]
+			if !ok || seen[s.FragmentName] {
+				continue
+			}
+			seen[s.FragmentName] = true
+			found = append(found, frag)
+			sets = append(sets, frag.SelectionSet)
+		}
+	}
+	return found
+}
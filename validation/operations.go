@@ -0,0 +1,116 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import "sevki.org/graphql/ast"
+
+// uniqueOperationNames as defined in
+// http://facebook.github.io/graphql/#sec-Operation-Name-Uniqueness
+func uniqueOperationNames(c *context) {
+	seen := make(map[ast.GraphQLName]*ast.Operation)
+	for _, def := range c.doc.Definitions {
+		op, ok := def.(*ast.Operation)
+		if !ok || op.Name == "" {
+			continue
+		}
+		if first, ok := seen[op.Name]; ok {
+			c.errorf(at(first.Pos, op.Pos), "There can be only one operation named %q.", op.Name)
+			continue
+		}
+		seen[op.Name] = op
+	}
+}
+
+// loneAnonymousOperation as defined in
+// http://facebook.github.io/graphql/#sec-Lone-Anonymous-Operation
+func loneAnonymousOperation(c *context) {
+	ops := c.operations()
+	if len(ops) < 2 {
+		return
+	}
+	for _, op := range ops {
+		if op.Name == "" {
+			c.errorf(at(op.Pos), "This anonymous operation must be the only defined operation.")
+		}
+	}
+}
+
+// singleFieldSubscriptions as defined in
+// http://facebook.github.io/graphql/#sec-Single-root-field
+func singleFieldSubscriptions(c *context) {
+	for _, def := range c.doc.Definitions {
+		op, ok := def.(*ast.Operation)
+		if !ok || op.OperationType != ast.Subscription {
+			continue
+		}
+		var fields []*ast.Field
+		keys := make(map[ast.GraphQLName]bool)
+		c.rootFields(op.SelectionSet, make(map[ast.GraphQLName]bool), func(f *ast.Field) {
+			k := responseKey(f)
+			if !keys[k] {
+				keys[k] = true
+				fields = append(fields, f)
+			}
+		})
+
+		name := "Anonymous Subscription"
+		if op.Name != "" {
+			name = "Subscription \"" + string(op.Name) + "\""
+		}
+		if len(fields) > 1 {
+			var locs []ast.Position
+			for _, f := range fields[1:] {
+				locs = append(locs, f.Pos)
+			}
+			c.errorf(locs, "%s must select only one top level field.", name)
+		}
+		for _, f := range fields {
+			if f.Name == "__typename" {
+				c.errorf(at(f.Pos), "%s must not select an introspection top level field.", name)
+			}
+		}
+	}
+}
+
+// rootFields calls fn for the fields in set and in the fragments set
+// spreads, fields of nested selection sets are left out.
+func (c *context) rootFields(set ast.SelectionSet, visited map[ast.GraphQLName]bool, fn func(*ast.Field)) {
+	for _, s := range set {
+		switch s := s.(type) {
+		case *ast.Field:
+			fn(s)
+		case *ast.Fragment:
+			if !isSpread(s) {
+				c.rootFields(s.SelectionSet, visited, fn)
+				continue
+			}
+			frag, ok := c.fragments[s.FragmentName]
+			if !ok || visited[s.FragmentName] {
+				continue
+			}
+			visited[s.FragmentName] = true
+			c.rootFields(frag.SelectionSet, visited, fn)
+		}
+	}
+}
+
+// responseKey returns the key the field's result is stored under.
+func responseKey(f *ast.Field) ast.GraphQLName {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// operations returns the operations in the document.
+func (c *context) operations() []*ast.Operation {
+	var ops []*ast.Operation
+	for _, def := range c.doc.Definitions {
+		if op, ok := def.(*ast.Operation); ok {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
@@ -0,0 +1,435 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"sevki.org/graphql/ast"
+)
+
+// overlappingFieldsCanBeMerged as defined in
+// http://facebook.github.io/graphql/#sec-Field-Selection-Merging
+//
+// Comparing every field with every other one it could be merged with
+// is quadratic, and fragments spread many times make it worse. Like
+// the reference implementation, the fields of each selection set are
+// collected once, fragments are compared to each other once per pair,
+// and a fragment is compared to a set of fields only once. Fields that
+// are selected the same way more than once are only compared once, and
+// so is every pair of fields.
+func overlappingFieldsCanBeMerged(c *context) {
+	m := newMerger(c)
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		var set ast.SelectionSet
+		switch n := n.(type) {
+		case *ast.Operation:
+			set = n.SelectionSet
+		case *ast.Fragment:
+			// inline fragments are merged with the set they are in.
+			if isSpread(n) || n.FragmentName == "" {
+				return
+			}
+			set = n.SelectionSet
+		case *ast.Field:
+			set = n.SelectionSet
+			parent = nil
+			if def != nil {
+				parent = c.composite(def.Type.Name())
+			}
+		}
+		if len(set) == 0 {
+			return
+		}
+		for _, conf := range m.withinSet(parent, set) {
+			c.errorf(append(conf.locs1, conf.locs2...), "Fields %q conflict because %s. Use different aliases on the fields to fetch both if this was intentional.", conf.key, conf.reason())
+		}
+	})
+}
+
+// fieldInfo is a field together with the type it is selected on and
+// its definition, either may be nil if they are not known.
+type fieldInfo struct {
+	parent *ast.TypeDefinition
+	field  *ast.Field
+	def    *ast.FieldDefinition
+}
+
+// fieldSet holds the fields of a selection set by response key, and
+// the names of the fragments spread in it.
+type fieldSet struct {
+	keys      []ast.GraphQLName
+	fields    map[ast.GraphQLName][]fieldInfo
+	fragments []ast.GraphQLName
+	// signatures of the fields collected so far.
+	signatures map[signature]bool
+}
+
+// signature is the same for fields that are selected the same way on
+// the same type.
+type signature struct {
+	parent *ast.TypeDefinition
+	shape  int
+}
+
+// conflict explains why two groups of fields can't be merged.
+type conflict struct {
+	key          ast.GraphQLName
+	why          string
+	subs         []conflict
+	locs1, locs2 []ast.Position
+}
+
+func (c conflict) reason() string {
+	if len(c.subs) == 0 {
+		return c.why
+	}
+	var rs []string
+	for _, s := range c.subs {
+		rs = append(rs, fmt.Sprintf("subfields %q conflict because %s", s.key, s.reason()))
+	}
+	return strings.Join(rs, " and ")
+}
+
+type fragmentPair struct {
+	a, b ast.GraphQLName
+}
+
+type fieldsAndFragment struct {
+	fields   *fieldSet
+	fragment ast.GraphQLName
+}
+
+type fieldPair struct {
+	a, b      fieldInfo
+	exclusive bool
+}
+
+func newMerger(c *context) *merger {
+	return &merger{
+		c:         c,
+		sets:      make(map[*ast.Selection]*fieldSet),
+		pairs:     make(map[fragmentPair]bool),
+		compared:  make(map[fieldsAndFragment]bool),
+		found:     make(map[fieldPair]*conflict),
+		fragments: make(map[ast.GraphQLName]*fieldSet),
+		shapes:    make(map[ast.Selection]int),
+		shapeIDs:  make(map[string]int),
+	}
+}
+
+type merger struct {
+	c *context
+	// sets caches the fields of selection sets by their first
+	// selection.
+	sets map[*ast.Selection]*fieldSet
+	// fragments caches the fields of fragment definitions.
+	fragments map[ast.GraphQLName]*fieldSet
+	// pairs of fragments that were compared, and whether they were
+	// compared as mutually exclusive.
+	pairs map[fragmentPair]bool
+	// compared field sets and fragments, and whether they were
+	// compared as mutually exclusive.
+	compared map[fieldsAndFragment]bool
+	// found caches the conflicts of pairs of fields.
+	found map[fieldPair]*conflict
+	// shapes numbers selections by the way they are written, see
+	// shape.
+	shapes   map[ast.Selection]int
+	shapeIDs map[string]int
+	// compares counts the pairs of fields compared.
+	compares int
+}
+
+// withinSet finds the conflicts between the fields of a single
+// selection set, including those of the fragments spread in it.
+func (m *merger) withinSet(parent *ast.TypeDefinition, set ast.SelectionSet) []conflict {
+	var confs []conflict
+	fs := m.fieldsOf(parent, set)
+	for _, k := range fs.keys {
+		fields := fs.fields[k]
+		for i := range fields {
+			for j := i + 1; j < len(fields); j++ {
+				if conf := m.find(false, k, fields[i], fields[j]); conf != nil {
+					confs = append(confs, *conf)
+				}
+			}
+		}
+	}
+	for i, a := range fs.fragments {
+		m.betweenFieldsAndFragment(&confs, false, fs, a)
+		for _, b := range fs.fragments[i+1:] {
+			m.betweenFragments(&confs, false, a, b)
+		}
+	}
+	return confs
+}
+
+// betweenSets finds the conflicts between the fields of two
+// selection sets, which are merged because their parent fields are.
+func (m *merger) betweenSets(exclusive bool, p1 *ast.TypeDefinition, s1 ast.SelectionSet, p2 *ast.TypeDefinition, s2 ast.SelectionSet) []conflict {
+	var confs []conflict
+	fs1, fs2 := m.fieldsOf(p1, s1), m.fieldsOf(p2, s2)
+	m.betweenFields(&confs, exclusive, fs1, fs2)
+	for _, f := range fs2.fragments {
+		m.betweenFieldsAndFragment(&confs, exclusive, fs1, f)
+	}
+	for _, f := range fs1.fragments {
+		m.betweenFieldsAndFragment(&confs, exclusive, fs2, f)
+	}
+	for _, a := range fs1.fragments {
+		for _, b := range fs2.fragments {
+			m.betweenFragments(&confs, exclusive, a, b)
+		}
+	}
+	return confs
+}
+
+func (m *merger) betweenFields(confs *[]conflict, exclusive bool, fs1, fs2 *fieldSet) {
+	for _, k := range fs1.keys {
+		for _, a := range fs1.fields[k] {
+			for _, b := range fs2.fields[k] {
+				if conf := m.find(exclusive, k, a, b); conf != nil {
+					*confs = append(*confs, *conf)
+				}
+			}
+		}
+	}
+}
+
+func (m *merger) betweenFieldsAndFragment(confs *[]conflict, exclusive bool, fs *fieldSet, name ast.GraphQLName) {
+	key := fieldsAndFragment{fs, name}
+	if excl, ok := m.compared[key]; ok && (exclusive || !excl) {
+		return
+	}
+	m.compared[key] = exclusive
+	ffs := m.fragment(name)
+	if ffs == nil || ffs == fs {
+		return
+	}
+	m.betweenFields(confs, exclusive, fs, ffs)
+	for _, f := range ffs.fragments {
+		m.betweenFieldsAndFragment(confs, exclusive, fs, f)
+	}
+}
+
+func (m *merger) betweenFragments(confs *[]conflict, exclusive bool, a, b ast.GraphQLName) {
+	if a == b {
+		return
+	}
+	pair := fragmentPair{a, b}
+	if b < a {
+		pair = fragmentPair{b, a}
+	}
+	// a pair compared as mutually exclusive must be compared again
+	// when it isn't, as more fields have to agree.
+	if excl, ok := m.pairs[pair]; ok && (exclusive || !excl) {
+		return
+	}
+	m.pairs[pair] = exclusive
+	fa, fb := m.fragment(a), m.fragment(b)
+	if fa == nil || fb == nil {
+		return
+	}
+	m.betweenFields(confs, exclusive, fa, fb)
+	for _, f := range fb.fragments {
+		m.betweenFragments(confs, exclusive, a, f)
+	}
+	for _, f := range fa.fragments {
+		m.betweenFragments(confs, exclusive, f, b)
+	}
+}
+
+// find reports whether fields a and b, which share the response key
+// k, conflict.
+func (m *merger) find(exclusive bool, k ast.GraphQLName, a, b fieldInfo) *conflict {
+	pair := fieldPair{a, b, exclusive}
+	if conf, ok := m.found[pair]; ok {
+		return conf
+	}
+	conf := m.compare(exclusive, k, a, b)
+	m.found[pair] = conf
+	m.compares++
+	return conf
+}
+
+func (m *merger) compare(exclusive bool, k ast.GraphQLName, a, b fieldInfo) *conflict {
+	// fields on different object types are never both selected.
+	exclusive = exclusive || a.parent != b.parent && a.parent != nil && b.parent != nil &&
+		a.parent.Kind == ast.ObjectKind && b.parent.Kind == ast.ObjectKind
+	conf := &conflict{key: k, locs1: at(a.field.Pos), locs2: at(b.field.Pos)}
+	if !exclusive {
+		if a.field.Name != b.field.Name {
+			conf.why = fmt.Sprintf("%q and %q are different fields", a.field.Name, b.field.Name)
+			return conf
+		}
+		if !sameArguments(a.field.Arguments, b.field.Arguments) {
+			conf.why = "they have differing arguments"
+			return conf
+		}
+	}
+	if a.def != nil && b.def != nil && m.typesConflict(a.def.Type, b.def.Type) {
+		conf.why = fmt.Sprintf("they return conflicting types %q and %q", a.def.Type, b.def.Type)
+		return conf
+	}
+	if len(a.field.SelectionSet) == 0 || len(b.field.SelectionSet) == 0 {
+		return nil
+	}
+	var pa, pb *ast.TypeDefinition
+	if a.def != nil {
+		pa = m.c.composite(a.def.Type.Name())
+	}
+	if b.def != nil {
+		pb = m.c.composite(b.def.Type.Name())
+	}
+	conf.subs = m.betweenSets(exclusive, pa, a.field.SelectionSet, pb, b.field.SelectionSet)
+	if len(conf.subs) == 0 {
+		return nil
+	}
+	for _, s := range conf.subs {
+		conf.locs1 = append(conf.locs1, s.locs1...)
+		conf.locs2 = append(conf.locs2, s.locs2...)
+	}
+	return conf
+}
+
+// typesConflict reports whether the values of two fields with types
+// a and b can't be merged into one response.
+func (m *merger) typesConflict(a, b ast.Type) bool {
+	switch {
+	case a.NonNull() || b.NonNull():
+		return a.NonNull() != b.NonNull() || m.typesConflict(a.Nullable(), b.Nullable())
+	case a.List() || b.List():
+		return a.List() != b.List() || m.typesConflict(a.Elem(), b.Elem())
+	case m.c.schema.IsLeafType(a) || m.c.schema.IsLeafType(b):
+		return a != b
+	}
+	return false
+}
+
+func sameArguments(a, b ast.Arguments) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		v, ok := b.Get(string(x.Name))
+		if !ok || ast.FormatValue(v) != ast.FormatValue(x.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// fragment returns the fields of the fragment definition named n.
+func (m *merger) fragment(n ast.GraphQLName) *fieldSet {
+	if fs, ok := m.fragments[n]; ok {
+		return fs
+	}
+	var fs *fieldSet
+	if f, ok := m.c.fragments[n]; ok {
+		fs = m.fieldsOf(m.c.composite(f.TypeCondition), f.SelectionSet)
+	}
+	m.fragments[n] = fs
+	return fs
+}
+
+// fieldsOf collects the fields of set, and the fields of the inline
+// fragments in it.
+func (m *merger) fieldsOf(parent *ast.TypeDefinition, set ast.SelectionSet) *fieldSet {
+	if len(set) == 0 {
+		return &fieldSet{}
+	}
+	if fs, ok := m.sets[&set[0]]; ok {
+		return fs
+	}
+	fs := &fieldSet{fields: make(map[ast.GraphQLName][]fieldInfo), signatures: make(map[signature]bool)}
+	seen := make(map[ast.GraphQLName]bool)
+	m.collect(fs, seen, parent, set)
+	m.sets[&set[0]] = fs
+	return fs
+}
+
+func (m *merger) collect(fs *fieldSet, seen map[ast.GraphQLName]bool, parent *ast.TypeDefinition, set ast.SelectionSet) {
+	for _, s := range set {
+		switch s := s.(type) {
+		case *ast.Field:
+			var def *ast.FieldDefinition
+			if parent != nil {
+				def = m.c.schema.Field(parent, s.Name)
+			}
+			// fields selected the same way can't conflict with each
+			// other, only the first one is compared to the rest.
+			sig := signature{parent, m.shape(s)}
+			if fs.signatures[sig] {
+				continue
+			}
+			fs.signatures[sig] = true
+			k := responseKey(s)
+			if _, ok := fs.fields[k]; !ok {
+				fs.keys = append(fs.keys, k)
+			}
+			fs.fields[k] = append(fs.fields[k], fieldInfo{parent, s, def})
+		case *ast.Fragment:
+			switch {
+			case isSpread(s):
+				if !seen[s.FragmentName] {
+					seen[s.FragmentName] = true
+					fs.fragments = append(fs.fragments, s.FragmentName)
+				}
+			case s.TypeCondition != "":
+				m.collect(fs, seen, m.c.composite(s.TypeCondition), s.SelectionSet)
+			default:
+				m.collect(fs, seen, parent, s.SelectionSet)
+			}
+		}
+	}
+}
+
+// shape numbers s so that selections written the same way, with
+// the same selections in them, get the same number. Every selection
+// is written once, with the numbers of the selections in it.
+func (m *merger) shape(s ast.Selection) int {
+	if id, ok := m.shapes[s]; ok {
+		return id
+	}
+	var b bytes.Buffer
+	var dirs ast.Directives
+	var set ast.SelectionSet
+	switch s := s.(type) {
+	case *ast.Field:
+		fmt.Fprintf(&b, "%s:%s", s.Alias, s.Name)
+		writeArguments(&b, s.Arguments)
+		dirs, set = s.Directives, s.SelectionSet
+	case *ast.Fragment:
+		fmt.Fprintf(&b, "...%s on %s", s.FragmentName, s.TypeCondition)
+		dirs, set = s.Directives, s.SelectionSet
+	}
+	for _, d := range dirs {
+		fmt.Fprintf(&b, "@%s", d.Name)
+		writeArguments(&b, d.Arguments)
+	}
+	b.WriteByte('{')
+	for _, s := range set {
+		fmt.Fprintf(&b, "%d ", m.shape(s))
+	}
+	b.WriteByte('}')
+	id, ok := m.shapeIDs[b.String()]
+	if !ok {
+		id = len(m.shapeIDs)
+		m.shapeIDs[b.String()] = id
+	}
+	m.shapes[s] = id
+	return id
+}
+
+func writeArguments(b *bytes.Buffer, args ast.Arguments) {
+	b.WriteByte('(')
+	for _, a := range args {
+		fmt.Fprintf(b, "%s:%s ", a.Name, ast.FormatValue(a.Value))
+	}
+	b.WriteByte(')')
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import "sevki.org/graphql/ast"
+
+// uniqueArgumentNames as defined in
+// http://facebook.github.io/graphql/#sec-Argument-Uniqueness
+func uniqueArgumentNames(c *context) {
+	walk(c.doc, func(n interface{}) {
+		argumentsOf(n, func(args ast.Arguments) {
+			seen := make(map[ast.GraphQLName]*ast.Argument)
+			for _, a := range args {
+				if first, ok := seen[a.Name]; ok {
+					c.errorf(at(first.Pos, a.Pos), "There can be only one argument named %q.", a.Name)
+					continue
+				}
+				seen[a.Name] = a
+			}
+		})
+	})
+}
+
+// uniqueDirectivesPerLocation as defined in
+// http://facebook.github.io/graphql/#sec-Directives-Are-Unique-Per-Location
+func uniqueDirectivesPerLocation(c *context) {
+	walk(c.doc, func(n interface{}) {
+		dirs, _ := directivesOf(n)
+		seen := make(map[ast.GraphQLName]*ast.Directive)
+		for _, d := range dirs {
+			if c.repeatable(d.Name) {
+				continue
+			}
+			if first, ok := seen[d.Name]; ok {
+				c.errorf(at(first.Pos, d.Pos), "The directive \"@%s\" can only be used once at this location.", d.Name)
+				continue
+			}
+			seen[d.Name] = d
+		}
+	})
+}
+
+// uniqueInputFieldNames as defined in
+// http://facebook.github.io/graphql/#sec-Input-Object-Field-Uniqueness
+func uniqueInputFieldNames(c *context) {
+	check := func(v ast.Value) {
+		obj, ok := v.(ast.ObjectValue)
+		if !ok {
+			return
+		}
+		seen := make(map[ast.GraphQLName]*ast.ObjectField)
+		for _, f := range obj {
+			if first, ok := seen[f.Name]; ok {
+				c.errorf(at(first.Pos, f.Pos), "There can be only one input field named %q.", f.Name)
+				continue
+			}
+			seen[f.Name] = f
+		}
+	}
+	walk(c.doc, func(n interface{}) {
+		// the defaults of variables are literals too.
+		if op, ok := n.(*ast.Operation); ok {
+			for _, v := range op.VariableDefinitions {
+				if v.DefaultValue != nil {
+					walkValue(v.DefaultValue, check)
+				}
+			}
+		}
+		argumentsOf(n, func(args ast.Arguments) {
+			for _, a := range args {
+				walkValue(a.Value, check)
+			}
+		})
+	})
+}
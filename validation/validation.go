@@ -0,0 +1,215 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package validation checks that executable documents are valid as
+// defined in http://facebook.github.io/graphql/#sec-Validation
+package validation // import "sevki.org/graphql/validation"
+
+import (
+	"fmt"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/schema"
+)
+
+// Error is a validation error, Locations point at the parts of the
+// document that caused it.
+type Error struct {
+	Message   string         `json:"message"`
+	Locations []ast.Position `json:"locations,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Locations) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Locations[0], e.Message)
+}
+
+// rule checks a single validation rule and reports what's wrong
+// through the context.
+type rule func(c *context)
+
+// rules don't need a schema, they are checked in this order.
+var rules = []rule{
+	uniqueOperationNames,
+	loneAnonymousOperation,
+	singleFieldSubscriptions,
+	uniqueArgumentNames,
+	uniqueDirectivesPerLocation,
+	uniqueInputFieldNames,
+	uniqueFragmentNames,
+	knownFragmentNames,
+	noUnusedFragments,
+	noFragmentCycles,
+	uniqueVariableNames,
+	noUndefinedVariables,
+	noUnusedVariables,
+}
+
+// schemaRules need a schema, they are checked after rules.
+var schemaRules = []rule{
+	knownOperationTypes,
+	knownDirectives,
+	knownTypeNames,
+	variablesAreInputTypes,
+	fragmentsOnCompositeTypes,
+	possibleFragmentSpreads,
+	fieldsOnCorrectType,
+	scalarLeafs,
+	knownArgumentNames,
+	providedRequiredArguments,
+	valuesOfCorrectType,
+	variablesInAllowedPosition,
+	overlappingFieldsCanBeMerged,
+}
+
+// Validate checks doc against the rules that don't need a schema.
+// Errors are grouped by rule and are in document order within a
+// rule.
+func Validate(doc *ast.Document) []*Error {
+	c := newContext(doc)
+	for _, r := range rules {
+		r(c)
+	}
+	return c.errors
+}
+
+// ValidateWithSchema checks doc against all rules, including the ones
+// that need to know the types in s.
+func ValidateWithSchema(s *schema.Schema, doc *ast.Document) []*Error {
+	c := newContext(doc)
+	c.schema = s
+	for _, r := range rules {
+		r(c)
+	}
+	for _, r := range schemaRules {
+		r(c)
+	}
+	return c.errors
+}
+
+type context struct {
+	doc    *ast.Document
+	schema *schema.Schema
+	// fragments maps names to fragment definitions, the first one
+	// wins when a name is used twice.
+	fragments map[ast.GraphQLName]*ast.Fragment
+	errors    []*Error
+}
+
+func newContext(doc *ast.Document) *context {
+	c := &context{
+		doc:       doc,
+		fragments: make(map[ast.GraphQLName]*ast.Fragment),
+	}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.Fragment); ok {
+			if _, ok := c.fragments[f.FragmentName]; !ok {
+				c.fragments[f.FragmentName] = f
+			}
+		}
+	}
+	return c
+}
+
+func (c *context) errorf(locs []ast.Position, format string, args ...interface{}) {
+	c.errors = append(c.errors, &Error{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: locs,
+	})
+}
+
+// at makes a list of locations for errors.
+func at(pos ...ast.Position) []ast.Position {
+	return pos
+}
+
+// isSpread reports whether f is a fragment spread, ...name.
+func isSpread(f *ast.Fragment) bool {
+	return f.FragmentName != "" && f.TypeCondition == ""
+}
+
+// walk calls fn for the operations and fragment definitions in doc
+// and every field, fragment spread and inline fragment in them,
+// parents before their children. Spreads are not followed.
+func walk(doc *ast.Document, fn func(n interface{})) {
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.Operation:
+			fn(d)
+			walkSelections(d.SelectionSet, fn)
+		case *ast.Fragment:
+			fn(d)
+			walkSelections(d.SelectionSet, fn)
+		}
+	}
+}
+
+func walkSelections(set ast.SelectionSet, fn func(n interface{})) {
+	for _, s := range set {
+		fn(s)
+		switch s := s.(type) {
+		case *ast.Field:
+			walkSelections(s.SelectionSet, fn)
+		case *ast.Fragment:
+			walkSelections(s.SelectionSet, fn)
+		}
+	}
+}
+
+// directivesOf returns the directives of a node walk visits and where
+// they are used.
+func directivesOf(n interface{}) (ast.Directives, ast.DirectiveLocation) {
+	switch n := n.(type) {
+	case *ast.Operation:
+		switch n.OperationType {
+		case ast.Mutation:
+			return n.Directives, ast.LocationMutation
+		case ast.Subscription:
+			return n.Directives, ast.LocationSubscription
+		default:
+			return n.Directives, ast.LocationQuery
+		}
+	case *ast.Field:
+		return n.Directives, ast.LocationField
+	case *ast.Fragment:
+		switch {
+		case isSpread(n):
+			return n.Directives, ast.LocationFragmentSpread
+		case n.FragmentName == "":
+			return n.Directives, ast.LocationInlineFragment
+		default:
+			return n.Directives, ast.LocationFragmentDefinition
+		}
+	}
+	return nil, ""
+}
+
+// argumentsOf calls fn with every list of arguments of a node walk
+// visits, the field's own and its directives'.
+func argumentsOf(n interface{}, fn func(ast.Arguments)) {
+	if f, ok := n.(*ast.Field); ok {
+		fn(f.Arguments)
+	}
+	dirs, _ := directivesOf(n)
+	for _, d := range dirs {
+		fn(d.Arguments)
+	}
+}
+
+// walkValue calls fn for v and every value nested in it.
+func walkValue(v ast.Value, fn func(ast.Value)) {
+	fn(v)
+	switch v := v.(type) {
+	case ast.ArrayValue:
+		for _, e := range v {
+			walkValue(e, fn)
+		}
+	case ast.ObjectValue:
+		for _, f := range v {
+			walkValue(f.Value, fn)
+		}
+	}
+}
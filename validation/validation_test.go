@@ -0,0 +1,492 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"sevki.org/graphql/ast"
+	"sevki.org/graphql/parser"
+	"sevki.org/graphql/schema"
+)
+
+// format turns errors into "line:col[,line:col]: message" lines.
+func format(errs []*Error) string {
+	var lines []string
+	for _, e := range errs {
+		var locs []string
+		for _, l := range e.Locations {
+			locs = append(locs, l.String())
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", strings.Join(locs, ","), e.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parse(t *testing.T, query string) *ast.Document {
+	doc, err := parser.NewQuery([]byte(query))
+	if err != nil {
+		t.Fatalf("parsing %q: %v", query, err)
+	}
+	return doc
+}
+
+var operationTests = []struct {
+	query string
+	errs  string
+}{
+	{`query a { x } query b { y }`, ``},
+	{
+		`query a { x }
+query a { y }`,
+		`1:1,2:1: There can be only one operation named "a".`,
+	},
+	{
+		`{ x }
+query b { y }`,
+		`1:1: This anonymous operation must be the only defined operation.`,
+	},
+	{`subscription s { a }`, ``},
+	{`subscription s { a a }`, ``},
+	{
+		`subscription s { a ...f }
+fragment f on Subscription { b c: a }`,
+		`2:30,2:32: Subscription "s" must select only one top level field.`,
+	},
+	{
+		`subscription { ... on Subscription { __typename } }`,
+		`1:38: Anonymous Subscription must not select an introspection top level field.`,
+	},
+	{
+		`{ a(x: 1, y: 2, x: 3) @include(if: true, if: false) }`,
+		`1:5,1:17: There can be only one argument named "x".
+1:32,1:42: There can be only one argument named "if".`,
+	},
+	{
+		`query q @dir @dir { a @skip(if: true) @skip(if: false) ... on T @x @x { b } }`,
+		`1:9,1:14: The directive "@dir" can only be used once at this location.
+1:23,1:39: The directive "@skip" can only be used once at this location.
+1:65,1:68: The directive "@x" can only be used once at this location.`,
+	},
+	{
+		`{ a(in: {x: 1, y: {z: 1, z: 2}, x: 2}) }`,
+		`1:10,1:33: There can be only one input field named "x".
+1:20,1:26: There can be only one input field named "z".`,
+	},
+	{
+		`query ($v: In = {a: 1, b: [{c: 1, c: 2}], a: 2}) { x(v: $v) }`,
+		`1:18,1:43: There can be only one input field named "a".
+1:29,1:35: There can be only one input field named "c".`,
+	},
+}
+
+func TestOperationRules(t *testing.T) {
+	for _, test := range operationTests {
+		got := format(Validate(parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+var fragmentTests = []struct {
+	query string
+	errs  string
+}{
+	{
+		`{ ...a }
+fragment a on T { x }
+fragment a on T { y }`,
+		`2:1,3:1: There can be only one fragment named "a".`,
+	},
+	{
+		`{ ...a ... on T { ...b } }
+fragment a on T { x }`,
+		`1:19: Unknown fragment "b".`,
+	},
+	{
+		`{ ...a }
+fragment a on T { ...b }
+fragment b on T { x }
+fragment c on T { ...d }
+fragment d on T { x }`,
+		`4:1: Fragment "c" is never used.
+5:1: Fragment "d" is never used.`,
+	},
+	{
+		`{ ...a }
+fragment a on T { x { ...b } }
+fragment b on T { ... on T { ...c } }
+fragment c on T { y ...a }`,
+		`2:23,3:30,4:21: Cannot spread fragment "a" within itself: a -> b -> c -> a.`,
+	},
+	{
+		`{ ...a }
+fragment a on T { ...a }`,
+		`2:19: Cannot spread fragment "a" within itself: a -> a.`,
+	},
+	{
+		`{ ...a ...b }
+fragment a on T { ...b ...c }
+fragment b on T { ...c }
+fragment c on T { ...b }`,
+		`3:19,4:19: Cannot spread fragment "b" within itself: b -> c -> b.`,
+	},
+}
+
+func TestFragmentRules(t *testing.T) {
+	for _, test := range fragmentTests {
+		got := format(Validate(parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+func BenchmarkFragmentCycles(b *testing.B) {
+	// every fragment spreads all the ones after it.
+	var q []string
+	q = append(q, "{ ...f0 }")
+	for i := 0; i < 100; i++ {
+		var spreads []string
+		for j := i + 1; j < 100; j++ {
+			spreads = append(spreads, fmt.Sprintf("...f%d", j))
+		}
+		q = append(q, fmt.Sprintf("fragment f%d on T { x %s }", i, strings.Join(spreads, " ")))
+	}
+	doc, err := parser.NewQuery([]byte(strings.Join(q, "\n")))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if errs := Validate(doc); len(errs) != 0 {
+			b.Fatal(format(errs))
+		}
+	}
+}
+
+var variableTests = []struct {
+	query string
+	errs  string
+}{
+	{`query q($a: Int, $b: Int) { x(a: $a) @include(if: $b) }`, ``},
+	{
+		`query q($a: Int, $b: String, $a: Int) { x(a: $a, b: $b) }`,
+		`1:9,1:30: There can be only one variable named "$a".`,
+	},
+	{
+		`query q($a: Int) { x(a: $a, b: [1, {c: $b}]) ...f }
+fragment f on T { y(d: $d) @skip(if: $a) }
+query p { ...f }`,
+		`1:40,1:1: Variable "$b" is not defined by operation "q".
+2:24,1:1: Variable "$d" is not defined by operation "q".
+2:24,3:1: Variable "$d" is not defined by operation "p".
+2:38,3:1: Variable "$a" is not defined by operation "p".`,
+	},
+	{
+		`query ($a: Int, $b: Int) @dir(b: $b) { ... on T { x } }`,
+		`1:8: Variable "$a" is never used.`,
+	},
+}
+
+func TestVariableRules(t *testing.T) {
+	for _, test := range variableTests {
+		got := format(Validate(parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+func loadSchema(t testing.TB) *schema.Schema {
+	f, err := os.Open("../tests/schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := schema.Parse("schema.graphql", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestVariablesAreInputTypes(t *testing.T) {
+	s := loadSchema(t)
+	doc := parse(t, `query q($a: ComplexType, $b: [Site!]!, $c: User, $d: [Node], $e: Nope) {
+  search(text: "x") { __typename }
+  a: search(text: "x", site: $b) { __typename }
+  node(id: $a) { id }
+  me { somepoo(after: $c, first: $d, cooco: $e) { id } }
+}`)
+	got := format(ValidateWithSchema(s, doc))
+	errs := `1:62: Unknown type "Nope".
+1:40: Variable "$c" cannot be non-input type "User".
+1:50: Variable "$d" cannot be non-input type "[Node]".
+1:26,3:30: Variable "$b" of type "[Site!]!" used in position expecting type "Site".
+1:9,4:12: Variable "$a" of type "ComplexType" used in position expecting type "[ID!]".`
+	if got != errs {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, errs)
+	}
+}
+
+var variablePositionTests = []struct {
+	query string
+	errs  string
+}{
+	{
+		`query ($i: ID!, $t: String = "a", $l: [String!]!, $b: Boolean!) { search(text: $t) { __typename } node(id: [$i]) { id } me @include(if: $b) { somepoo(after: {id: $i, tags: $l}) { id } } }`,
+		``,
+	},
+	{
+		`query ($s: String) { me { cropProfilePic(y: $s) { url } } }`,
+		`1:8,1:45: Variable "$s" of type "String" used in position expecting type "Int".`,
+	},
+	{
+		`query q($t: String, $ids: [ID], $b: Boolean) { search(text: $t) { __typename } node(id: $ids) { id } ...f }
+fragment f on Query { unnamed(truthy: $b) @include(if: $b) }`,
+		`1:9,1:61: Variable "$t" of type "String" used in position expecting type "String!".
+1:21,1:89: Variable "$ids" of type "[ID]" used in position expecting type "[ID!]".
+1:33,2:56: Variable "$b" of type "Boolean" used in position expecting type "Boolean!".`,
+	},
+}
+
+func TestVariablesInAllowedPosition(t *testing.T) {
+	s := loadSchema(t)
+	for _, test := range variablePositionTests {
+		got := format(ValidateWithSchema(s, parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+var schemaTests = []struct {
+	query string
+	errs  string
+}{
+	{`{ me { id name friends { id } } search(text: "x") { ... on User { id } ... on Picture { url } } }`, ``},
+	{`mutation { like(story: "1") { __typename } }`, ``},
+	{
+		`{ me { nope } search(text: "x") { id } }`,
+		`1:8: Cannot query field "nope" on type "User".
+1:35: Cannot query field "id" on type "SearchResult".`,
+	},
+	{
+		`{ me me { id { x } } }`,
+		`1:3: Field "me" of type "User" must have a selection of subfields. Did you mean "me { ... }"?
+1:11: Field "id" must not have a selection since type "ID!" has no subfields.`,
+	},
+	{
+		`{ search(txt: "x") { __typename } me { cropProfilePic(x: 1) { url } } }`,
+		`1:10: Unknown argument "txt" on field "Query.search".
+1:3: Field "search" argument "text" of type "String!" is required, but it was not provided.`,
+	},
+	{
+		`{ search(text: 1, site: WEB) { __typename } node(id: ["1", 2, 3.5]) { id } a: search(text: null) { __typename } }`,
+		`1:10: Expected value of type "String!", found 1.
+1:19: Value "WEB" does not exist in "Site" enum.
+1:50: Expected value of type "ID!", found 3.5.
+1:86: Expected value of type "String!", found null.`,
+	},
+	{
+		`query ($n: Int = "1", $s: Site = MOBILE, $l: [Int] = [1, 2.5]) { me { f: somepoo(first: $n) { id } somepoo(after: {id: 1, site: $s}, first: $l) { id } } }`,
+		`1:8: Expected value of type "Int", found "1".
+1:42: Expected value of type "Int", found 2.5.
+1:42,1:141: Variable "$l" of type "[Int]" used in position expecting type "Int".`,
+	},
+	{
+		`{ me { cropProfilePic(x: 3000000000, y: -2147483648) { url } a: cropProfilePic(x: 0x10, y: 1.5) { url } } }`,
+		`1:89: Expected value of type "Int", found 1.5.`,
+	},
+	{
+		`{ me { cropProfilePic(y: 3000000000) { url } friends(after: 12345678901234567890) { id } } }`,
+		`1:23: Expected value of type "Int", found 3000000000.`,
+	},
+	{
+		`{ me { somepoo(after: {site: DESKTOP, nope: 1}, first: "1") { id } } }`,
+		`1:39: Field "nope" is not defined by type "ComplexType".
+1:16: Field "ComplexType.id" of required type "ID!" was not provided.
+1:49: Expected value of type "Int", found "1".`,
+	},
+	{
+		`{ me { ...f ... on Picture { url } ...g ...h } }
+fragment f on Story { id }
+fragment g on Site { x }
+fragment h on Nope { x }`,
+		`4:1: Unknown type "Nope".
+3:1: Fragment "g" cannot condition on non composite type "Site".
+1:8: Fragment "f" cannot be spread here as objects of type "User" can never be of type "Story".
+1:13: Fragment cannot be spread here as objects of type "User" can never be of type "Picture".`,
+	},
+}
+
+var overlapTests = []struct {
+	query string
+	errs  string
+}{
+	{`{ me { id id name: name } search(text: "a") { ... on User { id n: name } ... on Friend { id n: foo(size: 1) } } }`, ``},
+	{
+		`{ me { poop: somepoo(first: 1) { id } ...f } }
+fragment f on User { poop: somepoo(first: 2) { id } }`,
+		`1:8,2:22: Fields "poop" conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.`,
+	},
+	{
+		`{ me { x: id x: name } }`,
+		`1:8,1:14: Fields "x" conflict because "id" and "name" are different fields. Use different aliases on the fields to fetch both if this was intentional.`,
+	},
+	{
+		`{ me { f: friends { x: id } } me { f: friends { x: name } } }`,
+		`1:3,1:8,1:21,1:31,1:36,1:49: Fields "me" conflict because subfields "f" conflict because subfields "x" conflict because "id" and "name" are different fields. Use different aliases on the fields to fetch both if this was intentional.`,
+	},
+	{
+		`{ search(text: "a") { ... on Friend { x: id } ... on Picture { x: url(size: 1) } } }`,
+		`1:39,1:64: Fields "x" conflict because they return conflicting types "ID!" and "String!". Use different aliases on the fields to fetch both if this was intentional.`,
+	},
+}
+
+func TestOverlappingFields(t *testing.T) {
+	s := loadSchema(t)
+	for _, test := range overlapTests {
+		got := format(ValidateWithSchema(s, parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+func TestOverlappingRepeatedFields(t *testing.T) {
+	s := loadSchema(t)
+	// identical fields are compared once, not with every copy.
+	q := "{ me { " + strings.Repeat("friends { id name } ", 5000) + "} }"
+	doc := parse(t, q)
+	if errs := ValidateWithSchema(s, doc); len(errs) != 0 {
+		t.Fatal(format(errs))
+	}
+	c := newContext(doc)
+	c.schema = s
+	m := newMerger(c)
+	me := doc.Definitions[0].(*ast.Operation).SelectionSet[0].(*ast.Field)
+	m.withinSet(s.Types["User"], me.SelectionSet)
+	if m.compares != 0 {
+		t.Errorf("compared %d pairs of fields for 5000 copies of a field", m.compares)
+	}
+	q = "{ me { " + strings.Repeat("friends { id name } ", 1000) + "friends(first: 1) { id } } }"
+	got := format(ValidateWithSchema(s, parse(t, q)))
+	errs := `1:8,1:20008: Fields "friends" conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.`
+	if got != errs {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, errs)
+	}
+}
+
+func TestOverlappingExclusive(t *testing.T) {
+	s, err := schema.Parse("exclusive.graphql", strings.NewReader(`
+type Query { u: U }
+union U = A | B
+type A { x: A y: String z: String }
+type B { x: A }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// F is compared to the fields of A.x as mutually exclusive first,
+	// as they are merged with those of B.x, then as they are not.
+	q := `{ u { ... on A { x { ...F y: z } } ... on B { x { ...F } } } } fragment F on A { y }`
+	got := format(ValidateWithSchema(s, parse(t, q)))
+	errs := `1:27,1:82: Fields "y" conflict because "z" and "y" are different fields. Use different aliases on the fields to fetch both if this was intentional.`
+	if got != errs {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, errs)
+	}
+}
+
+func BenchmarkOverlappingFields(b *testing.B) {
+	// many fragments selecting the same fields, spread side by side.
+	var q, spreads []string
+	for i := 0; i < 100; i++ {
+		spreads = append(spreads, fmt.Sprintf("...f%d", i))
+		q = append(q, fmt.Sprintf("fragment f%d on User { id name friends { id name } }", i))
+	}
+	q = append(q, fmt.Sprintf("{ me { %s } }", strings.Join(spreads, " ")))
+	doc, err := parser.NewQuery([]byte(strings.Join(q, "\n")))
+	if err != nil {
+		b.Fatal(err)
+	}
+	s := loadSchema(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if errs := ValidateWithSchema(s, doc); len(errs) != 0 {
+			b.Fatal(format(errs))
+		}
+	}
+}
+
+var directiveTests = []struct {
+	query string
+	errs  string
+}{
+	{
+		`query q @remote(addr: "x") @ginclude { me @skip(if: true) { id ...f @bullshit @bullshit(something: WAP) } }
+fragment f on User { id }`,
+		``,
+	},
+	{
+		`query q @nope @bugerking { me @bugerking { ... @include(if: true) @bullshit { id } } }
+mutation m @remote(addr: "x") @ginclude(please: false) { like(story: "1") { __typename } }`,
+		`1:9: Unknown directive "@nope".
+1:31: Directive "@bugerking" may not be used on FIELD.
+2:12: Directive "@remote" may not be used on MUTATION.`,
+	},
+	{
+		`{ me @skip @include(if: 1, unless: true) { id ... on User @bullshit(something: NOPE) { id } } }`,
+		`1:28: Unknown argument "unless" on directive "@include".
+1:6: Directive "@skip" argument "if" of type "Boolean!" is required, but it was not provided.
+1:21: Expected value of type "Boolean!", found 1.
+1:69: Value "NOPE" does not exist in "Site" enum.`,
+	},
+	{
+		`{ me @skip(if: true) @skip(if: false) { id } }`,
+		`1:6,1:22: The directive "@skip" can only be used once at this location.`,
+	},
+}
+
+func TestDirectives(t *testing.T) {
+	s := loadSchema(t)
+	for _, test := range directiveTests {
+		got := format(ValidateWithSchema(s, parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+func TestSchemaRules(t *testing.T) {
+	s := loadSchema(t)
+	for _, test := range schemaTests {
+		got := format(ValidateWithSchema(s, parse(t, test.query)))
+		if got != test.errs {
+			t.Errorf("validating %q\ngot:\n%s\nexpected:\n%s", test.query, got, test.errs)
+		}
+	}
+}
+
+func TestKitchenSink(t *testing.T) {
+	var doc ast.Document
+	ks, _ := os.Open("../tests/kitchen-sink.graphql")
+	if err := parser.New("kitchenSink", ks).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	got := format(Validate(&doc))
+	errs := `35:1: This anonymous operation must be the only defined operation.
+32:13,8:1: Variable "$size" is not defined by operation "queryName".
+32:25,8:1: Variable "$b" is not defined by operation "queryName".
+8:36: Variable "$site" is never used in operation "queryName".`
+	if got != errs {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, errs)
+	}
+}
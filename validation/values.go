@@ -0,0 +1,136 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import "sevki.org/graphql/ast"
+
+// checkValue reports literal values that can't be coerced to typ,
+// errors point at pos, or the object field they are found in.
+// Variables are checked when the request is executed.
+func (c *context) checkValue(v ast.Value, typ ast.Type, pos ast.Position) {
+	if _, ok := v.(ast.VariableValue); ok {
+		return
+	}
+	if _, ok := v.(ast.NullValue); ok {
+		if typ.NonNull() {
+			c.errorf(at(pos), "Expected value of type %q, found null.", typ)
+		}
+		return
+	}
+	nullable := typ.Nullable()
+	if nullable.List() {
+		if list, ok := v.(ast.ArrayValue); ok {
+			for _, e := range list {
+				c.checkValue(e, nullable.Elem(), pos)
+			}
+			return
+		}
+		// a single value is coerced to a list of one.
+		c.checkValue(v, nullable.Elem(), pos)
+		return
+	}
+
+	t := c.schema.Type(typ)
+	if t == nil {
+		return
+	}
+	switch t.Kind {
+	case ast.InputObjectKind:
+		obj, ok := v.(ast.ObjectValue)
+		if !ok {
+			c.errorf(at(pos), "Expected value of type %q, found %s.", typ, ast.FormatValue(v))
+			return
+		}
+		for _, f := range obj {
+			fd := t.Field(f.Name)
+			if fd == nil {
+				c.errorf(at(f.Pos), "Field %q is not defined by type %q.", f.Name, t.Name)
+				continue
+			}
+			c.checkValue(f.Value, fd.Type, f.Pos)
+		}
+		for _, fd := range t.Fields {
+			if !fd.Type.NonNull() || fd.DefaultValue != nil {
+				continue
+			}
+			if _, ok := obj.Get(string(fd.Name)); !ok {
+				c.errorf(at(pos), "Field \"%s.%s\" of required type %q was not provided.", t.Name, fd.Name, fd.Type)
+			}
+		}
+	case ast.EnumKind:
+		e, ok := v.(ast.EnumValue)
+		switch {
+		case !ok:
+			c.errorf(at(pos), "Enum %q cannot represent non-enum value: %s.", t.Name, ast.FormatValue(v))
+		case t.EnumValue(ast.GraphQLName(e)) == nil:
+			c.errorf(at(pos), "Value %q does not exist in %q enum.", e, t.Name)
+		}
+	case ast.ScalarKind:
+		if sc, ok := c.schema.Scalars[t.Name]; ok {
+			if hasVariables(v) {
+				return
+			}
+			if _, err := sc.ParseLiteral(v); err != nil {
+				c.errorf(at(pos), "Expected value of type %q, found %s; %s", typ, ast.FormatValue(v), err)
+			}
+			return
+		}
+		if !validScalar(t.Name, v) {
+			c.errorf(at(pos), "Expected value of type %q, found %s.", typ, ast.FormatValue(v))
+		}
+	}
+}
+
+// validScalar reports whether v is a valid literal for the scalar
+// named n. Custom scalars without an implementation accept any
+// literal.
+func validScalar(n ast.GraphQLName, v ast.Value) bool {
+	switch v.(type) {
+	case ast.GraphQLError:
+		return false
+	}
+	switch n {
+	case "Int":
+		switch v := v.(type) {
+		case ast.IntValue:
+			_, ok := v.Int32()
+			return ok
+		case ast.GraphQLInt:
+			return true
+		}
+		return false
+	case "Float":
+		switch v.(type) {
+		case ast.IntValue, ast.GraphQLInt, ast.GraphQLFloat:
+			return true
+		}
+		return false
+	case "String":
+		_, ok := v.(ast.GraphQLString)
+		return ok
+	case "Boolean":
+		_, ok := v.(ast.GraphQLBoolean)
+		return ok
+	case "ID":
+		switch v.(type) {
+		case ast.GraphQLString, ast.IntValue, ast.GraphQLInt:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// hasVariables reports whether variables are nested in v, their
+// values aren't known before the request is executed.
+func hasVariables(v ast.Value) bool {
+	found := false
+	walkValue(v, func(v ast.Value) {
+		if _, ok := v.(ast.VariableValue); ok {
+			found = true
+		}
+	})
+	return found
+}
@@ -0,0 +1,215 @@
+// Copyright 2015 Sevki <s@sevki.org>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package validation // import "sevki.org/graphql/validation"
+
+import "sevki.org/graphql/ast"
+
+// uniqueVariableNames as defined in
+// http://facebook.github.io/graphql/#sec-Variable-Uniqueness
+func uniqueVariableNames(c *context) {
+	for _, op := range c.operations() {
+		seen := make(map[ast.GraphQLName]*ast.Variable)
+		for _, v := range op.VariableDefinitions {
+			if first, ok := seen[v.Name]; ok {
+				c.errorf(at(first.Pos, v.Pos), "There can be only one variable named \"$%s\".", v.Name)
+				continue
+			}
+			seen[v.Name] = v
+		}
+	}
+}
+
+// noUndefinedVariables as defined in
+// http://facebook.github.io/graphql/#sec-All-Variable-Uses-Defined
+func noUndefinedVariables(c *context) {
+	for _, op := range c.operations() {
+		reported := make(map[ast.GraphQLName]bool)
+		for _, v := range c.variableUsages(op) {
+			if op.VariableDefinitions.Get(string(v.Name)) != nil || reported[v.Name] {
+				continue
+			}
+			reported[v.Name] = true
+			if op.Name == "" {
+				c.errorf(at(v.Pos, op.Pos), "Variable \"$%s\" is not defined.", v.Name)
+			} else {
+				c.errorf(at(v.Pos, op.Pos), "Variable \"$%s\" is not defined by operation %q.", v.Name, op.Name)
+			}
+		}
+	}
+}
+
+// noUnusedVariables as defined in
+// http://facebook.github.io/graphql/#sec-All-Variables-Used
+func noUnusedVariables(c *context) {
+	for _, op := range c.operations() {
+		used := make(map[ast.GraphQLName]bool)
+		for _, v := range c.variableUsages(op) {
+			used[v.Name] = true
+		}
+		for _, v := range op.VariableDefinitions {
+			if used[v.Name] {
+				continue
+			}
+			if op.Name == "" {
+				c.errorf(at(v.Pos), "Variable \"$%s\" is never used.", v.Name)
+			} else {
+				c.errorf(at(v.Pos), "Variable \"$%s\" is never used in operation %q.", v.Name, op.Name)
+			}
+		}
+	}
+}
+
+// variablesAreInputTypes as defined in
+// http://facebook.github.io/graphql/#sec-Variables-Are-Input-Types
+func variablesAreInputTypes(c *context) {
+	for _, op := range c.operations() {
+		for _, v := range op.VariableDefinitions {
+			// unknown types are reported by knownTypeNames.
+			if c.schema.Type(v.Type) != nil && !c.schema.IsInputType(v.Type) {
+				c.errorf(at(v.Pos), "Variable \"$%s\" cannot be non-input type %q.", v.Name, v.Type)
+			}
+		}
+	}
+}
+
+// variableUsages returns the variables used in the arguments and
+// directives of op and of the fragments it spreads, in document
+// order.
+func (c *context) variableUsages(op *ast.Operation) []ast.VariableValue {
+	var usages []ast.VariableValue
+	collect := func(n interface{}) {
+		argumentsOf(n, func(args ast.Arguments) {
+			for _, a := range args {
+				walkValue(a.Value, func(v ast.Value) {
+					if v, ok := v.(ast.VariableValue); ok {
+						usages = append(usages, v)
+					}
+				})
+			}
+		})
+	}
+	collect(op)
+	walkSelections(op.SelectionSet, collect)
+	for _, f := range c.reachableFragments(op.SelectionSet) {
+		collect(f)
+		walkSelections(f.SelectionSet, collect)
+	}
+	return usages
+}
+
+// variableUse is a variable used where a value of type typ is
+// expected, hasDefault is whether the location has a default value
+// to fall back on.
+type variableUse struct {
+	ast.VariableValue
+	typ        ast.Type
+	hasDefault bool
+}
+
+// variablesInAllowedPosition as defined in
+// http://facebook.github.io/graphql/#sec-All-Variable-Usages-are-Allowed
+func variablesInAllowedPosition(c *context) {
+	// uses maps operations and fragment definitions to the
+	// variables used directly in them.
+	uses := make(map[interface{}][]variableUse)
+	var in interface{}
+	c.walkTypes(func(n interface{}, parent *ast.TypeDefinition, def *ast.FieldDefinition) {
+		switch n := n.(type) {
+		case *ast.Operation:
+			in = n
+		case *ast.Fragment:
+			if n.FragmentName != "" && !isSpread(n) {
+				in = n
+			}
+		}
+		if f, ok := n.(*ast.Field); ok && def != nil {
+			for _, a := range f.Arguments {
+				if ad := def.Argument(a.Name); ad != nil {
+					uses[in] = c.variableUses(uses[in], a.Value, ad.Type, ad.DefaultValue != nil)
+				}
+			}
+		}
+		dirs, _ := directivesOf(n)
+		for _, d := range dirs {
+			dd, ok := c.schema.Directives[d.Name]
+			if !ok {
+				continue
+			}
+			for _, a := range d.Arguments {
+				if ad := dd.Argument(a.Name); ad != nil {
+					uses[in] = c.variableUses(uses[in], a.Value, ad.Type, ad.DefaultValue != nil)
+				}
+			}
+		}
+	})
+	for _, op := range c.operations() {
+		all := uses[op]
+		for _, f := range c.reachableFragments(op.SelectionSet) {
+			all = append(all, uses[f]...)
+		}
+		for _, u := range all {
+			v := op.VariableDefinitions.Get(string(u.Name))
+			// unknown and output types are reported by knownTypeNames
+			// and variablesAreInputTypes.
+			if v == nil || !c.schema.IsInputType(v.Type) {
+				continue
+			}
+			loc := u.typ
+			if loc.NonNull() && !v.Type.NonNull() {
+				// a default makes up for a nullable variable.
+				_, null := v.DefaultValue.(ast.NullValue)
+				if u.hasDefault || (v.DefaultValue != nil && !null) {
+					loc = loc.Nullable()
+				}
+			}
+			if !c.compatible(v.Type, loc) {
+				c.errorf(at(v.Pos, u.Pos), "Variable \"$%s\" of type %q used in position expecting type %q.", u.Name, v.Type, u.typ)
+			}
+		}
+	}
+}
+
+// variableUses appends the variables in v, a value of type typ, to
+// uses.
+func (c *context) variableUses(uses []variableUse, v ast.Value, typ ast.Type, hasDefault bool) []variableUse {
+	switch v := v.(type) {
+	case ast.VariableValue:
+		return append(uses, variableUse{v, typ, hasDefault})
+	case ast.ArrayValue:
+		if elem := typ.Elem(); elem != "" {
+			for _, e := range v {
+				uses = c.variableUses(uses, e, elem, false)
+			}
+		}
+	case ast.ObjectValue:
+		t := c.schema.Type(typ)
+		if t == nil || t.Kind != ast.InputObjectKind {
+			break
+		}
+		for _, f := range v {
+			if fd := t.Field(f.Name); fd != nil {
+				uses = c.variableUses(uses, f.Value, fd.Type, fd.DefaultValue != nil)
+			}
+		}
+	}
+	return uses
+}
+
+// compatible reports whether a variable of type v can be used where a
+// value of type loc is expected, as defined in
+// http://facebook.github.io/graphql/#AreTypesCompatible()
+func (c *context) compatible(v, loc ast.Type) bool {
+	switch {
+	case loc.NonNull():
+		return v.NonNull() && c.compatible(v.Nullable(), loc.Nullable())
+	case v.NonNull():
+		return c.compatible(v.Nullable(), loc)
+	case loc.List():
+		return v.List() && c.compatible(v.Elem(), loc.Elem())
+	case v.List():
+		return false
+	}
+	return v == loc
+}